@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/TiyaAnlite/FocotServicesCommon/echox"
+	"github.com/labstack/echo/v4"
+	"k8s.io/klog/v2"
+)
+
+type AdminAgent struct {
+	ID         string            `json:"id"`
+	Mask       uint16            `json:"mask"`
+	Condition  AgentCondition    `json:"condition"`
+	Status     string            `json:"status"`
+	UpdateTime time.Time         `json:"update_time"`
+	HitStatus  map[string]uint32 `json:"hit_status"`
+	Draining   bool              `json:"draining"`
+	Paused     bool              `json:"paused"`
+	Stopped    bool              `json:"stopped"`
+	Master     []uint64          `json:"master"`   // rooms of single stream followed
+	Watching   []uint64          `json:"watching"` // cached watching rooms
+	BufferUsed uint32            `json:"buffer_used"`
+	BufferSize uint32            `json:"buffer_size"`
+	Backlog    []uint32          `json:"backlog"`           // per event handler worker
+	Saturation float64           `json:"saturation"`        // of the busiest worker, 1 means full
+	Dropped    map[string]uint64 `json:"dropped,omitempty"` // buffer type or meta cache type:dropped events since agent start
+	// from agent info
+	Version      uint32   `json:"version"`
+	Build        string   `json:"build"`
+	MsgTypes     []string `json:"msg_types"`
+	MaxRooms     uint32   `json:"max_rooms"`
+	Incompatible string   `json:"incompatible,omitempty"`
+}
+
+type AdminRoom struct {
+	RoomID     uint64   `json:"room_id"`
+	Providers  []string `json:"providers"`
+	Credential string   `json:"credential,omitempty"`
+	Placement  []string `json:"placement,omitempty"` // only available when sharded
+	Agents     []string `json:"agents"`              // agents watching room
+	Master     string   `json:"master"`
+}
+
+type AdminHits struct {
+	Total  map[string]uint32             `json:"total"`  // category:released duplicate window
+	Agents map[string]map[string]float64 `json:"agents"` // agent:category:hit ratio
+}
+
+type AdminLeader struct {
+	Instance string `json:"instance"`
+	Leader   string `json:"leader"`
+	IsLeader bool   `json:"is_leader"`
+}
+
+type adminMasterInput struct {
+	AgentID string `json:"agent_id" validate:"required"`
+	RoomID  uint64 `json:"room_id"` // all rooms that agent watching if not set
+}
+
+// SetupAdmin register admin api of controller, mutating api is protected by auth
+func (c *DamakuController) SetupAdmin(g *echo.Group, auth echo.MiddlewareFunc) {
+	g.Use(c.adminReady)
+	g.GET("/agents", c.adminAgents)
+	g.GET("/agents/:agentId", c.adminAgent)
+	g.POST("/agents/:agentId/reinit", c.adminReinit, auth, c.adminLeaderOnly)
+	g.POST("/agents/:agentId/drain", c.adminDrain(true), auth, c.adminLeaderOnly)
+	g.DELETE("/agents/:agentId/drain", c.adminDrain(false), auth, c.adminLeaderOnly)
+	g.POST("/agents/:agentId/pause", c.adminPause(true), auth, c.adminLeaderOnly)
+	g.DELETE("/agents/:agentId/pause", c.adminPause(false), auth, c.adminLeaderOnly)
+	g.POST("/agents/:agentId/shutdown", c.adminShutdown, auth, c.adminLeaderOnly)
+	g.PUT("/master", c.adminMaster, auth, c.adminLeaderOnly)
+	g.GET("/rooms", c.adminRooms)
+	g.GET("/hits", c.adminHits)
+	g.GET("/leader", c.adminLeader)
+}
+
+// adminAuth use echox JWT if JWT_SECRET is set, otherwise the bearer token of admin config,
+// all requests are rejected if neither is configured
+func adminAuth(echoCfg *echox.EchoConfig, token string) echo.MiddlewareFunc {
+	if echox.JwtEnabled(echoCfg) {
+		klog.Info("[Admin]JWT enabled")
+		return echox.JwtMiddlewareWithDefaultConfig(echoCfg)
+	}
+	if token == "" {
+		klog.Warning("[Admin]neither JWT_SECRET nor admin token configured, mutating admin api disabled")
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			given, ok := strings.CutPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if token == "" || !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				return echox.NormalErrorResponse(ctx, http.StatusUnauthorized, http.StatusUnauthorized, "unauthorized")
+			}
+			return next(ctx)
+		}
+	}
+}
+
+func (c *DamakuController) adminReady(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if !c.started.Load() {
+			return echox.NormalErrorResponse(ctx, http.StatusServiceUnavailable, http.StatusServiceUnavailable, "controller not started")
+		}
+		return next(ctx)
+	}
+}
+
+// agent state changed by admin only take effect at leader
+func (c *DamakuController) adminLeaderOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if !c.leader.IsLeader() {
+			leader, _ := c.leader.Leader()
+			return echox.NormalErrorResponse(ctx, http.StatusMisdirectedRequest, http.StatusMisdirectedRequest, "not leader, current leader: "+leader)
+		}
+		return next(ctx)
+	}
+}
+
+func (c *DamakuController) adminAgentView(a *AgentStatus) *AdminAgent {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	view := &AdminAgent{
+		ID:         a.ID,
+		Mask:       a.Mask,
+		Condition:  a.Condition,
+		Status:     a.StatusString(),
+		UpdateTime: a.UpdateTime,
+		HitStatus:  make(map[string]uint32, len(a.HitStatus)),
+		Draining:   a.Draining,
+		Paused:     a.Paused,
+		Stopped:    a.Stopped,
+		Master:     c.agent.MasterRooms(a.ID),
+	}
+	for category, hits := range a.HitStatus {
+		view.HitStatus[category] = hits
+	}
+	if a.Info != nil {
+		view.Version = a.Info.Version
+		view.Build = a.Info.Build
+		view.MsgTypes = slices.Clone(a.Info.MsgTypes)
+		view.MaxRooms = a.Info.MaxRooms
+	}
+	view.Incompatible = a.Incompatible
+	if a.CachedStatus != nil {
+		view.Watching = slices.Clone(a.CachedStatus.Watching)
+		view.BufferUsed = a.CachedStatus.BufferUsed
+		view.BufferSize = a.CachedStatus.BufferSize
+		view.Backlog = slices.Clone(a.CachedStatus.WorkerBacklog)
+		view.Saturation = a.CachedStatus.Saturation()
+		view.Dropped = make(map[string]uint64)
+		for bufferType, dropped := range a.CachedStatus.BufferDropped {
+			if dropped > 0 {
+				view.Dropped[agent.AgentStatus_BufferType(bufferType).String()] = dropped
+			}
+		}
+		for cacheType, info := range a.CachedStatus.MetaCache {
+			if info.Dropped > 0 {
+				view.Dropped[agent.AgentStatus_MetaCacheType(cacheType).String()] = info.Dropped
+			}
+		}
+	}
+	return view
+}
+
+func (c *DamakuController) adminAgents(ctx echo.Context) error {
+	agents := make([]*AdminAgent, 0)
+	c.agent.managed.Range(func(_, value any) bool {
+		agents = append(agents, c.adminAgentView(value.(*AgentStatus)))
+		return true
+	})
+	slices.SortFunc(agents, func(a, b *AdminAgent) int { return int(a.Mask) - int(b.Mask) })
+	return echox.NormalResponse(ctx, agents)
+}
+
+func (c *DamakuController) adminAgent(ctx echo.Context) error {
+	v, ok := c.agent.managed.Load(ctx.Param("agentId"))
+	if !ok {
+		return echox.NormalErrorResponse(ctx, http.StatusNotFound, http.StatusNotFound, "agent not found")
+	}
+	return echox.NormalResponse(ctx, c.adminAgentView(v.(*AgentStatus)))
+}
+
+func (c *DamakuController) adminReinit(ctx echo.Context) error {
+	if err := c.agent.Reinit(ctx.Param("agentId")); err != nil {
+		return echox.NormalErrorResponse(ctx, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+	}
+	return echox.NormalEmptyResponse(ctx)
+}
+
+func (c *DamakuController) adminDrain(draining bool) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if err := c.agent.Drain(ctx.Param("agentId"), draining); err != nil {
+			return echox.NormalErrorResponse(ctx, http.StatusNotFound, http.StatusNotFound, err.Error())
+		}
+		return echox.NormalEmptyResponse(ctx)
+	}
+}
+
+func (c *DamakuController) adminPause(paused bool) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if err := c.agent.Pause(ctx.Param("agentId"), paused); err != nil {
+			return echox.NormalErrorResponse(ctx, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		}
+		return echox.NormalEmptyResponse(ctx)
+	}
+}
+
+func (c *DamakuController) adminShutdown(ctx echo.Context) error {
+	if err := c.agent.Shutdown(ctx.Param("agentId")); err != nil {
+		return echox.NormalErrorResponse(ctx, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+	}
+	return echox.NormalEmptyResponse(ctx)
+}
+
+func (c *DamakuController) adminMaster(ctx echo.Context) error {
+	input, err := echox.CheckInput[adminMasterInput](ctx)
+	if err != nil {
+		return echox.NormalErrorResponse(ctx, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+	}
+	if err := c.agent.SetMaster(input.AgentID, input.RoomID); err != nil {
+		return echox.NormalErrorResponse(ctx, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+	}
+	return echox.NormalEmptyResponse(ctx)
+}
+
+func (c *DamakuController) adminRooms(ctx echo.Context) error {
+	agents := c.agent.snapshot()
+	rooms := c.agent.rooms.Rooms()
+	slices.Sort(rooms)
+	views := make([]*AdminRoom, 0, len(rooms))
+	for _, room := range rooms {
+		view := &AdminRoom{
+			RoomID:     room,
+			Providers:  c.agent.rooms.Providers(room),
+			Credential: c.agent.rooms.Credential(room),
+			Placement:  c.agent.Placement(room),
+			Agents:     make([]string, 0),
+			Master:     c.agent.RoomMaster(room),
+		}
+		for _, a := range agents {
+			if slices.Contains(a.Watching, room) {
+				view.Agents = append(view.Agents, a.ID)
+			}
+		}
+		slices.Sort(view.Agents)
+		views = append(views, view)
+	}
+	return echox.NormalResponse(ctx, views)
+}
+
+func (c *DamakuController) adminHits(ctx echo.Context) error {
+	hits := &AdminHits{
+		Total:  c.agent.HitTotals(),
+		Agents: make(map[string]map[string]float64),
+	}
+	c.agent.managed.Range(func(_, value any) bool {
+		a := value.(*AgentStatus)
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+		ratio := make(map[string]float64, len(a.HitStatus))
+		for category, count := range a.HitStatus {
+			if total := hits.Total[category]; total > 0 {
+				ratio[category] = float64(count) / float64(total)
+			}
+		}
+		hits.Agents[a.ID] = ratio
+		return true
+	})
+	return echox.NormalResponse(ctx, hits)
+}
+
+func (c *DamakuController) adminLeader(ctx echo.Context) error {
+	leader, err := c.leader.Leader()
+	if err != nil {
+		return echox.NormalErrorResponse(ctx, http.StatusInternalServerError, http.StatusInternalServerError, err.Error())
+	}
+	return echox.NormalResponse(ctx, &AdminLeader{
+		Instance: c.leader.ID(),
+		Leader:   leader,
+		IsLeader: c.leader.IsLeader(),
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TiyaAnlite/FocotServicesCommon/echox"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAdminAuth(t *testing.T) {
+	cases := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+		{"missing", "secret", "", http.StatusUnauthorized},
+		{"wrong", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"not bearer", "secret", "secret", http.StatusUnauthorized},
+		{"valid", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := echo.New()
+			e.POST("/", func(ctx echo.Context) error { return ctx.NoContent(http.StatusOK) }, adminAuth(&echox.EchoConfig{}, c.token))
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if c.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, c.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != c.want {
+				t.Fatalf("unexpected status: %d, want %d", rec.Code, c.want)
+			}
+		})
+	}
+}
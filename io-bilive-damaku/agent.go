@@ -10,8 +10,8 @@ import (
 	"time"
 
 	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/duke-git/lancet/v2/condition"
 	"github.com/nats-io/nats.go"
-	"github.com/zoumo/goset"
 	"google.golang.org/protobuf/proto"
 	"k8s.io/klog/v2"
 )
@@ -21,22 +21,34 @@ type AgentManager struct {
 	managed     sync.Map // agentId:*AgentStatus
 	agentChan   chan *nats.Msg
 	roomProvide chan *ProvidedRoom
-	roomRevoke  chan uint64
-	watchedRoom goset.Set // thread safe, uint64
+	roomRevoke  chan *ProvidedRoom
+	rooms       *RoomStore     // watched rooms and providers, shared by controller replicas
+	leader      *LeaderElector // only leader init and sync agents
 	latestMask  uint16
-	master      *AgentStatus
+	masters     map[uint64]string   // room:agent, followed by single stream
+	placement   map[uint64][]string // room:agents, only available when sharded
+	gapHook     func(gap *agent.StreamGap)
+	hitTotal    map[string]uint32 // category:released duplicate window
 	mu          sync.RWMutex
 
 	// running flag
 	started atomic.Bool
 }
 
-func (m *AgentManager) Init(ctx *CenterContext) error {
+func (m *AgentManager) Init(ctx *CenterContext, leader *LeaderElector) error {
 	m.centerCtx = ctx
+	m.leader = leader
 	m.agentChan = make(chan *nats.Msg, 32)
 	m.roomProvide = make(chan *ProvidedRoom)
-	m.roomRevoke = make(chan uint64)
-	m.watchedRoom = goset.NewSet()
+	m.roomRevoke = make(chan *ProvidedRoom)
+	m.rooms = &RoomStore{}
+	m.rooms.Init(ctx)
+	if err := m.rooms.Load(); err != nil {
+		return fmt.Errorf("failed to load watched rooms: %s", err.Error())
+	}
+	klog.Infof("%d watched rooms loaded", len(m.rooms.Rooms()))
+	m.hitTotal = make(map[string]uint32)
+	m.masters = make(map[uint64]string)
 	sub, err := ctx.MQ.Nc.ChanSubscribe(fmt.Sprintf("%s.agent.*", ctx.Config.Global.Prefix), m.agentChan)
 	if err != nil {
 		return fmt.Errorf("failed to subscribe agent msg: %s", err.Error())
@@ -79,6 +91,9 @@ func (m *AgentManager) AddAgentHitMask(masks []byte, category string) {
 	for i := 0; i < len(masks); i += 2 {
 		hitAgent = append(hitAgent, binary.BigEndian.Uint16(masks[i:i+2]))
 	}
+	m.mu.Lock()
+	m.hitTotal[category] += 1
+	m.mu.Unlock()
 	m.managed.Range(func(_, value any) bool {
 		a := value.(*AgentStatus)
 		if !slices.Contains(hitAgent, a.Mask) {
@@ -95,19 +110,141 @@ func (m *AgentManager) AddAgentHitMask(masks []byte, category string) {
 	})
 }
 
-// MasterAgent return the first choice agent for single stream
-func (m *AgentManager) MasterAgent() string {
+// HitTotal return count of released duplicate window in category, the denominator of agent hit ratio
+func (m *AgentManager) HitTotal(category string) uint32 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hitTotal[category]
+}
+
+// HitTotals return a copy of released duplicate window count of all categories
+func (m *AgentManager) HitTotals() map[string]uint32 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	if m.master != nil {
-		return ""
+	totals := make(map[string]uint32, len(m.hitTotal))
+	for category, total := range m.hitTotal {
+		totals[category] = total
+	}
+	return totals
+}
+
+// Drain move all rooms off from agent, or cancel draining
+func (m *AgentManager) Drain(agentId string, draining bool) error {
+	v, ok := m.managed.Load(agentId)
+	if !ok {
+		return fmt.Errorf("agent(%s) not found", agentId)
 	}
-	id := m.master.ID
-	return id
+	a := v.(*AgentStatus)
+	a.mu.Lock()
+	a.Draining = draining
+	a.mu.Unlock()
+	klog.Infof("agent(%s) draining: %t", agentId, draining)
+	// agent rejects new rooms while draining, rooms are moved off by controller even if agent not support it
+	if err := m.control(&agent.AgentAction{Type: condition.TernaryOperator(draining, agent.AgentAction_Drain, agent.AgentAction_Resume)}, "action", agentId); err != nil {
+		klog.Warningf("notify agent(%s) draining failed: %s", agentId, err.Error())
+	}
+	return nil
+}
+
+// Pause let agent drop events but keep rooms connected, or resume it
+func (m *AgentManager) Pause(agentId string, paused bool) error {
+	v, ok := m.managed.Load(agentId)
+	if !ok {
+		return fmt.Errorf("agent(%s) not found", agentId)
+	}
+	if err := m.control(&agent.AgentAction{Type: condition.TernaryOperator(paused, agent.AgentAction_Pause, agent.AgentAction_Resume)}, "action", agentId); err != nil {
+		return err
+	}
+	a := v.(*AgentStatus)
+	a.mu.Lock()
+	a.Paused = paused
+	a.mu.Unlock()
+	klog.Infof("agent(%s) paused: %t", agentId, paused)
+	return nil
+}
+
+// Shutdown drain agent first, and let it exit after all its rooms are releasable or DrainTimeout,
+// canceled if agent is resumed or restarted meanwhile
+func (m *AgentManager) Shutdown(agentId string) error {
+	if err := m.Drain(agentId, true); err != nil {
+		return err
+	}
+	m.centerCtx.Worker.Go(func() {
+		timeout := time.After(m.centerCtx.Config.Controller.DrainTimeout)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+	wait:
+		for {
+			drained, err := m.drained(agentId)
+			if err != nil {
+				klog.Warningf("agent(%s) shutdown canceled: %s", agentId, err.Error())
+				return
+			}
+			if drained {
+				break
+			}
+			select {
+			case <-ticker.C:
+			case <-timeout:
+				klog.Warningf("agent(%s) not drained in %s, shutting down", agentId, m.centerCtx.Config.Controller.DrainTimeout)
+				break wait
+			case <-m.centerCtx.Context.Done():
+				return
+			}
+		}
+		if err := m.control(&agent.AgentAction{Type: agent.AgentAction_Shutdown}, "action", agentId); err != nil {
+			klog.Errorf("agent(%s) shutdown failed: %s", agentId, err.Error())
+			return
+		}
+		klog.Infof("agent(%s) shutting down", agentId)
+	})
+	return nil
+}
+
+// drained return true if all rooms of agent are releasable, error if agent is no longer draining
+func (m *AgentManager) drained(agentId string) (bool, error) {
+	v, ok := m.managed.Load(agentId)
+	if !ok {
+		return false, fmt.Errorf("agent(%s) not found", agentId)
+	}
+	a := v.(*AgentStatus)
+	a.mu.RLock()
+	draining, stopped := a.Draining, a.Stopped
+	var watching []uint64
+	if a.CachedStatus != nil {
+		watching = slices.Clone(a.CachedStatus.Watching)
+	}
+	a.mu.RUnlock()
+	if stopped {
+		return false, fmt.Errorf("agent(%s) already stopped", agentId)
+	}
+	if !draining {
+		return false, fmt.Errorf("agent(%s) not draining", agentId)
+	}
+	agents := m.snapshot()
+	for _, room := range watching {
+		if !m.releasable(room, agents) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Reinit send AgentInit to agent again, agent will reconnect all rooms with the new global account
+func (m *AgentManager) Reinit(agentId string) error {
+	v, ok := m.managed.Load(agentId)
+	if !ok {
+		return fmt.Errorf("agent(%s) not found", agentId)
+	}
+	a := v.(*AgentStatus)
+	a.mu.RLock()
+	initMsg := m.initMsg(a.Info)
+	a.mu.RUnlock()
+	return m.control(initMsg, "init", agentId)
 }
 
 // GetRoomChan get two channels for provide and revoke rooms
-func (m *AgentManager) GetRoomChan() (chan<- *ProvidedRoom, chan<- uint64) {
+func (m *AgentManager) GetRoomChan() (chan<- *ProvidedRoom, chan<- *ProvidedRoom) {
 	return m.roomProvide, m.roomRevoke
 }
 
@@ -118,6 +255,46 @@ func (m *AgentManager) manager() {
 	m.centerCtx.Worker.Go(m.initAgent)
 	m.centerCtx.Worker.Go(m.syncAgent)
 	m.centerCtx.Worker.Go(m.agentStatus)
+	m.centerCtx.Worker.Go(m.roomManager)
+}
+
+// receive rooms from providers and update provider flags of shared watched rooms, run on every replica
+func (m *AgentManager) roomManager() {
+	klog.Info("[Manager-room]handler start")
+	for {
+		select {
+		case room := <-m.roomProvide:
+			if room.Credential != "" && !isStaticProvider(room.ProviderName) {
+				// only rooms declared in config can be moved onto an account
+				klog.Errorf("provider(%s) is not allowed to attach credential to room(%d)", room.ProviderName, room.RoomID)
+				continue
+			}
+			if room.Credential != "" {
+				if _, ok := m.centerCtx.Config.Credentials[room.Credential]; !ok {
+					klog.Errorf("provider(%s) provide room(%d) with unknown credential: %s", room.ProviderName, room.RoomID, room.Credential)
+					continue
+				}
+			}
+			if err := m.rooms.Add(room.RoomID, room.ProviderName, room.Credential); err != nil {
+				klog.Errorf("provider(%s) provide room failed: %s", room.ProviderName, err.Error())
+				continue
+			}
+			klog.Infof("room(%d) provided by %s", room.RoomID, room.ProviderName)
+		case room := <-m.roomRevoke:
+			remaining, err := m.rooms.Revoke(room.RoomID, room.ProviderName)
+			if err != nil {
+				klog.Errorf("provider(%s) revoke room failed: %s", room.ProviderName, err.Error())
+				continue
+			}
+			if remaining > 0 {
+				klog.Infof("room(%d) revoked by %s, still provided by %v", room.RoomID, room.ProviderName, m.rooms.Providers(room.RoomID))
+				continue
+			}
+			klog.Infof("room(%d) revoked by %s, stop watching", room.RoomID, room.ProviderName)
+		case <-m.centerCtx.Context.Done():
+			return
+		}
+	}
 }
 
 // init no initialization agent
@@ -128,17 +305,16 @@ func (m *AgentManager) initAgent() {
 	for {
 		select {
 		case <-ticker.C:
+			if !m.leader.IsLeader() {
+				continue
+			}
 			m.managed.Range(func(_, value any) bool {
 				a := value.(*AgentStatus)
 				a.mu.RLock()
-				if a.Condition&AgentInitialization == 0 && time.Now().Sub(a.UpdateTime) <= time.Second*3 {
+				if a.Condition&AgentInitialization == 0 && a.Incompatible == "" && !a.Stopped && time.Now().Sub(a.UpdateTime) <= time.Second*3 {
 					// init agent async
+					initMsg := m.initMsg(a.Info)
 					a.mu.RUnlock()
-					initMsg := &agent.AgentInit{
-						BUVID:  m.centerCtx.Config.Global.BUVID,
-						UID:    m.centerCtx.Config.Global.UID,
-						Cookie: m.centerCtx.Config.Global.Cookie,
-					}
 					if err := m.control(initMsg, "init", a.ID); err != nil {
 						klog.Errorf("agent init failed: %s", err.Error())
 						return true
@@ -167,28 +343,52 @@ func (m *AgentManager) syncAgent() {
 	for {
 		select {
 		case <-ticker.C:
+			if !m.leader.IsLeader() {
+				continue
+			}
+			// reload rooms changed by other replicas, keep the cached rooms if failed
+			if err := m.rooms.Load(); err != nil {
+				klog.Errorf("failed to reload watched rooms: %s", err.Error())
+			}
+			watchedRooms := m.rooms.Rooms()
+			agents := m.snapshot()
+			m.place(watchedRooms, agents)
 			m.managed.Range(func(_, value any) bool {
 				status := value.(*AgentStatus)
 				var needAdd []uint64
 				var needDel []uint64
+				var needRenew []uint64 // credential changed, reconnected by DelRoom and AddRoom
 				status.mu.RLock()
-				m.watchedRoom.Range(func(_ int, elem interface{}) bool {
-					room := elem.(uint64)
-					if !slices.Contains(status.CachedStatus.Watching, room) {
+				if status.CachedStatus == nil {
+					// no status received yet
+					status.mu.RUnlock()
+					return true
+				}
+				for _, room := range watchedRooms {
+					if !slices.Contains(status.CachedStatus.Watching, room) && !status.Draining && m.wantRoom(status.ID, room) {
 						needAdd = append(needAdd, room)
 					}
-					return true
-				})
+				}
 				for _, r := range status.CachedStatus.Watching {
-					if !m.watchedRoom.Contains(r) {
+					if !slices.Contains(watchedRooms, r) {
+						needDel = append(needDel, r)
+					} else if (status.Draining || !m.wantRoom(status.ID, r)) && m.releasable(r, agents) {
+						// room moved to other agents
 						needDel = append(needDel, r)
+					} else if !status.Draining && m.rooms.Credential(r) != status.Credentials[r] {
+						needRenew = append(needRenew, r)
 					}
 				}
+				if status.Info != nil && status.Info.MaxRooms > 0 {
+					// keep rooms under capacity of agent
+					free := max(int(status.Info.MaxRooms)-len(status.CachedStatus.Watching)+len(needDel), 0)
+					needAdd = needAdd[:min(len(needAdd), free)]
+				}
 				canSync := true
 				if !status.IsReady() {
 					canSync = false // only sync ready agent
 				}
-				if status.Condition&AgentSync > 0 && (len(needAdd) > 0 || len(needDel) > 0) {
+				if status.Condition&AgentSync > 0 && (len(needAdd) > 0 || len(needDel) > 0 || len(needRenew) > 0) {
 					// update condition first
 					status.mu.RUnlock()
 					status.mu.Lock()
@@ -209,15 +409,42 @@ func (m *AgentManager) syncAgent() {
 					return true
 				}
 				// sync diff rooms
+				added := make(map[uint64]string, len(needAdd)+len(needRenew))
 				for _, room := range needAdd {
+					cred := m.rooms.Credential(room)
 					action := &agent.AgentAction{
-						Type:   agent.AgentAction_AddRoom,
-						RoomID: &room,
+						Type:       agent.AgentAction_AddRoom,
+						RoomID:     &room,
+						Credential: m.credential(room),
 					}
 					if err := m.control(action, "action", status.ID); err != nil {
 						klog.Errorf("agent add failed: %s", err.Error())
 						continue
 					}
+					added[room] = cred
+				}
+				for _, room := range needRenew {
+					cred := m.rooms.Credential(room)
+					klog.Infof("credential of room(%d) changed to %q, reconnect by agent(%s)", room, cred, status.ID)
+					del := &agent.AgentAction{
+						Type:   agent.AgentAction_DelRoom,
+						RoomID: &room,
+					}
+					if err := m.control(del, "action", status.ID); err != nil {
+						klog.Errorf("agent del failed: %s", err.Error())
+						continue
+					}
+					add := &agent.AgentAction{
+						Type:       agent.AgentAction_AddRoom,
+						RoomID:     &room,
+						Credential: m.credential(room),
+					}
+					if err := m.control(add, "action", status.ID); err != nil {
+						klog.Errorf("agent add failed: %s", err.Error())
+						needDel = append(needDel, room) // deleted already, add again at next sync
+						continue
+					}
+					added[room] = cred
 				}
 				for _, room := range needDel {
 					action := &agent.AgentAction{
@@ -240,17 +467,22 @@ func (m *AgentManager) syncAgent() {
 				for _, room := range needAdd {
 					status.CachedStatus.Watching = append(status.CachedStatus.Watching, room)
 				}
+				if status.Credentials == nil {
+					status.Credentials = make(map[uint64]string)
+				}
+				for _, room := range needDel {
+					delete(status.Credentials, room)
+				}
+				for room, cred := range added {
+					status.Credentials[room] = cred
+				}
 				status.Condition |= AgentSync
 				status.UpdateTime = time.Now()
 				klog.Infof("agent(%s) condition changed to %s ", status.ID, status.StatusString())
-				// select a master
-				if m.master == nil || m.master.Condition&AgentReady == 0 {
-					m.master = status
-					klog.Infof("master agent changed to: %s", status.ID)
-				}
-				m.mu.Unlock()
+				status.mu.Unlock()
 				return true
 			})
+			m.electMasters(watchedRooms, m.snapshot())
 		case <-m.centerCtx.Context.Done():
 			return
 		}
@@ -265,6 +497,7 @@ func (m *AgentManager) agentStatus() {
 	for {
 		select {
 		case <-ticker.C:
+			lost := false
 			m.managed.Range(func(_, value any) bool {
 				status := value.(*AgentStatus)
 				status.mu.RLock()
@@ -274,7 +507,8 @@ func (m *AgentManager) agentStatus() {
 					status.mu.Lock()
 					// check again
 					if status.IsReady() && time.Now().Sub(status.UpdateTime) > time.Second*3 {
-						status.Condition = ^AgentReady // unset ready
+						status.Condition &^= AgentReady // unset ready
+						lost = true
 					}
 					status.UpdateTime = time.Now()
 					klog.Infof("agent(%s) condition changed to %s ", status.ID, status.StatusString())
@@ -284,6 +518,9 @@ func (m *AgentManager) agentStatus() {
 				status.mu.RUnlock()
 				return true
 			})
+			if lost {
+				m.reelect() // failover at once instead of waiting for next sync
+			}
 		case msg := <-m.agentChan:
 			subject := strings.Split(msg.Subject, ".")
 			switch subject[len(subject)-1] {
@@ -296,47 +533,72 @@ func (m *AgentManager) agentStatus() {
 				v, ok := m.managed.Load(info.ID)
 				if !ok {
 					// auto register a new agent
-					// create new agent
-					m.mu.Lock()
-					newAgent := &AgentStatus{
-						ID:         info.ID,
-						Mask:       m.latestMask,
-						UpdateTime: time.Now(),
-						HitStatus:  make(map[string]uint32),
-					}
-					m.latestMask++
-					m.mu.Unlock()
-					m.managed.Store(newAgent.ID, newAgent)
-					klog.Infof("new managered agent: %s", newAgent.ID)
-					return
+					v = m.register(info.ID)
 				}
 				a := v.(*AgentStatus)
 				// info: set agent to not initialize
 				a.mu.Lock()
 				// reset all condition for restarted agent
+				restarted := a.IsReady()
 				a.Condition = 0
+				a.Draining = false
+				a.Paused = false
+				a.Stopped = false
+				a.Info = info
+				if err := m.compatible(info); err != nil {
+					if a.Incompatible != err.Error() {
+						klog.Warningf("agent(%s) incompatible: %s", a.ID, err.Error())
+					}
+					a.Incompatible = err.Error()
+				} else {
+					a.Incompatible = ""
+				}
 				a.UpdateTime = time.Now()
 				klog.Infof("agent(%s) condition changed to %s ", a.ID, a.StatusString())
 				a.mu.Unlock()
+				if restarted {
+					m.reelect()
+				}
 			case "status":
 				status := &agent.AgentStatus{}
 				if err := proto.Unmarshal(msg.Data, status); err != nil {
-					klog.Fatalf("failed to unmarshal agent status: %s", err.Error())
+					klog.Errorf("failed to unmarshal agent status: %s", err.Error())
 					continue
 				}
 				v, ok := m.managed.Load(status.Meta.Agent)
 				if !ok {
-					// no register agent will be ignored
-					return
+					// agent initialized by the previous leader or before controller restarted, adopt it
+					v = m.register(status.Meta.Agent)
 				}
 				a := v.(*AgentStatus)
+				if status.Meta.Version != agent.VERSION {
+					klog.Warningf("agent(%s) status version %d not supported, need %d", a.ID, status.Meta.Version, agent.VERSION)
+					continue
+				}
 				// status: set agent to ready
 				a.mu.Lock()
 				a.CachedStatus = status
-				a.Condition |= AgentInitialization | AgentReady // set initialized & ready
+				switch {
+				case status.State == agent.AgentStatus_Stopped:
+					// final status, rooms will be placed to other agents, agent is exiting and must not be initialized again
+					a.Condition = 0
+					a.Draining = false
+					a.Stopped = true
+				case a.Stopped:
+					// late status of exited agent, wait for its next info
+				case status.State == agent.AgentStatus_Draining:
+					a.Draining = true // drained by agent itself
+					a.Condition |= AgentInitialization | AgentReady
+				default:
+					a.Condition |= AgentInitialization | AgentReady // set initialized & ready
+				}
+				a.Paused = status.State == agent.AgentStatus_Paused
 				a.UpdateTime = time.Now()
 				klog.Infof("agent(%s) condition changed to %s ", a.ID, a.StatusString())
 				a.mu.Unlock()
+				if status.State == agent.AgentStatus_Stopped || status.State == agent.AgentStatus_Paused {
+					m.reelect()
+				}
 			}
 		case <-m.centerCtx.Context.Done():
 			return
@@ -344,6 +606,78 @@ func (m *AgentManager) agentStatus() {
 	}
 }
 
+// credential of room for AddRoom action, nil for using the global account that agent initialized with
+func (m *AgentManager) credential(room uint64) *agent.AgentCredential {
+	name := m.rooms.Credential(room)
+	if name == "" {
+		return nil
+	}
+	cred, ok := m.centerCtx.Config.Credentials[name]
+	if !ok {
+		klog.Warningf("unknown credential(%s) of room(%d), using global account", name, room)
+		return nil
+	}
+	return &agent.AgentCredential{
+		BUVID:  cred.BUVID,
+		UID:    cred.UID,
+		Cookie: cred.Cookie,
+		UA:     cred.UA,
+		Header: cred.Headers,
+	}
+}
+
+// global account and msg types for agent
+func (m *AgentManager) initMsg(info *agent.AgentInfo) *agent.AgentInit {
+	return &agent.AgentInit{
+		BUVID:    m.centerCtx.Config.Global.BUVID,
+		UID:      m.centerCtx.Config.Global.UID,
+		Cookie:   m.centerCtx.Config.Global.Cookie,
+		UA:       m.centerCtx.Config.Global.UA,
+		Header:   m.centerCtx.Config.Global.Headers,
+		MsgTypes: m.msgTypes(info),
+	}
+}
+
+// msg types asked from agent, only the types agent supported, empty for all
+func (m *AgentManager) msgTypes(info *agent.AgentInfo) []string {
+	wanted := m.centerCtx.Config.Controller.MsgTypes
+	if len(wanted) == 0 || info == nil {
+		return wanted
+	}
+	return slices.DeleteFunc(slices.Clone(wanted), func(msgType string) bool {
+		return !slices.Contains(info.MsgTypes, msgType)
+	})
+}
+
+// compatible check protocol version and msg types of agent
+func (m *AgentManager) compatible(info *agent.AgentInfo) error {
+	if info.Version != agent.VERSION {
+		return fmt.Errorf("protocol version %d not supported, need %d", info.Version, agent.VERSION)
+	}
+	if len(m.centerCtx.Config.Controller.MsgTypes) > 0 && len(m.msgTypes(info)) == 0 {
+		return fmt.Errorf("none of msg types %v supported", m.centerCtx.Config.Controller.MsgTypes)
+	}
+	return nil
+}
+
+// create a new managed agent with unique mask
+func (m *AgentManager) register(agentId string) *AgentStatus {
+	m.mu.Lock()
+	newAgent := &AgentStatus{
+		ID:         agentId,
+		Mask:       m.latestMask,
+		UpdateTime: time.Now(),
+		HitStatus:  make(map[string]uint32),
+	}
+	m.latestMask++
+	m.mu.Unlock()
+	actual, loaded := m.managed.LoadOrStore(newAgent.ID, newAgent)
+	if !loaded {
+		klog.Infof("new managered agent: %s", newAgent.ID)
+	}
+	return actual.(*AgentStatus)
+}
+
 func (m *AgentManager) control(msg proto.Message, action, agentId string) error {
 	payload, err := proto.Marshal(msg)
 	if err != nil {
@@ -3,6 +3,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -24,6 +26,17 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// bili msg cmd not defined in go-bili-chat events
+const (
+	CmdInteractWord              = "INTERACT_WORD"
+	CmdLikeInfoV3Update          = "LIKE_INFO_V3_UPDATE"
+	CmdRoomChange                = "ROOM_CHANGE"
+	CmdLive                      = "LIVE"
+	CmdPreparing                 = "PREPARING"
+	CmdWatchedChange             = "WATCHED_CHANGE"
+	CmdRoomRealTimeMessageUpdate = "ROOM_REAL_TIME_MESSAGE_UPDATE"
+)
+
 var (
 	SupportedMsgTypes = []string{
 		events.CmdDanmuMsg,
@@ -32,22 +45,43 @@ var (
 		events.CmdSuperChatMessage,
 		events.CmdOnlineRankCount,
 		events.CmdOnlineRankV2,
+		CmdInteractWord,
+		CmdLikeInfoV3Update,
+		CmdRoomChange,
+		CmdLive,
+		CmdPreparing,
+		CmdWatchedChange,
+		CmdRoomRealTimeMessageUpdate,
 	}
 	SupportedMsgTypesNames = map[string]string{
-		events.CmdDanmuMsg:         "Damaku",
-		events.CmdSendGift:         "Gift",
-		events.CmdGuardBuy:         "Guard",
-		events.CmdSuperChatMessage: "SuperChat",
-		events.CmdOnlineRankCount:  "OnlineRank",
-		events.CmdOnlineRankV2:     "OnlineRankV2",
+		events.CmdDanmuMsg:           "Damaku",
+		events.CmdSendGift:           "Gift",
+		events.CmdGuardBuy:           "Guard",
+		events.CmdSuperChatMessage:   "SuperChat",
+		events.CmdOnlineRankCount:    "OnlineRank",
+		events.CmdOnlineRankV2:       "OnlineRankV2",
+		CmdInteractWord:              "Interact",
+		CmdLikeInfoV3Update:          "Like",
+		CmdRoomChange:                "RoomChange",
+		CmdLive:                      "Live",
+		CmdPreparing:                 "Preparing",
+		CmdWatchedChange:             "Watched",
+		CmdRoomRealTimeMessageUpdate: "RoomRealTime",
 	}
 	SupportedMsgTypesProto = map[string]agent.AgentStatus_BufferType{
-		events.CmdDanmuMsg:         agent.AgentStatus_Damaku,
-		events.CmdSendGift:         agent.AgentStatus_Gift,
-		events.CmdGuardBuy:         agent.AgentStatus_Guard,
-		events.CmdSuperChatMessage: agent.AgentStatus_SuperChat,
-		events.CmdOnlineRankCount:  agent.AgentStatus_OnlineRank,
-		events.CmdOnlineRankV2:     agent.AgentStatus_OnlineRankV2,
+		events.CmdDanmuMsg:           agent.AgentStatus_Damaku,
+		events.CmdSendGift:           agent.AgentStatus_Gift,
+		events.CmdGuardBuy:           agent.AgentStatus_Guard,
+		events.CmdSuperChatMessage:   agent.AgentStatus_SuperChat,
+		events.CmdOnlineRankCount:    agent.AgentStatus_OnlineRank,
+		events.CmdOnlineRankV2:       agent.AgentStatus_OnlineRankV2,
+		CmdInteractWord:              agent.AgentStatus_Interact,
+		CmdLikeInfoV3Update:          agent.AgentStatus_Like,
+		CmdRoomChange:                agent.AgentStatus_RoomChange,
+		CmdLive:                      agent.AgentStatus_Live,
+		CmdPreparing:                 agent.AgentStatus_Preparing,
+		CmdWatchedChange:             agent.AgentStatus_Watched,
+		CmdRoomRealTimeMessageUpdate: agent.AgentStatus_RoomRealTime,
 	}
 	CacheConfig = bigcache.Config{
 		Shards:           1024,
@@ -61,14 +95,28 @@ var (
 type DamakuCenterAgent struct {
 	chatHandler   *biliChat.Handler
 	controlChan   chan *nats.Msg
-	eventChan     chan *BLiveEventHandlerMsg
+	initChan      chan *nats.Msg // re-init by controller
+	eventChan     []*eventQueue  // workerId:events, sharded by room
 	eventCounter  map[string]*atomic.Int32
-	watchingRooms sync.Map
+	eventDropped  map[string]*atomic.Uint64
+	dropPolicies  map[string]DropPolicy
+	watchingRooms sync.Map // roomId:*agent.AgentCredential
 	metaBuilder   agent.MetaBuilder
+	msgTypes      []string // asked by controller, all supported types if empty
+	started       atomic.Bool
+	state         atomic.Int32 // agent.AgentStatus_AgentState
+	stopOnce      sync.Once
+
+	// credential of rooms
+	defaultCredential *agent.AgentCredential
+	defaultHeader     http.Header
+	credentialMu      sync.Mutex
 
 	// runtime channel
-	userMetaChan  chan *agent.UserInfoMeta
-	medalMetaChan chan *agent.FansMedalMeta
+	userMetaChan     chan *agent.UserInfoMeta
+	medalMetaChan    chan *agent.FansMedalMeta
+	userMetaDropped  atomic.Uint64
+	medalMetaDropped atomic.Uint64
 
 	// runtime cache
 	userMetaCache  *bigcache.BigCache
@@ -79,11 +127,16 @@ func (a *DamakuCenterAgent) Init() {
 	var err error
 	a.chatHandler = biliChat.GetNewHandler()
 	a.controlChan = make(chan *nats.Msg, 4)
-	a.eventChan = make(chan *BLiveEventHandlerMsg, 100)
+	a.eventChan = make([]*eventQueue, max(cfg.EventWorkers, 1))
+	for i := range a.eventChan {
+		a.eventChan[i] = newEventQueue(cfg.EventBuffer)
+	}
 	a.eventCounter = make(map[string]*atomic.Int32)
+	a.eventDropped = make(map[string]*atomic.Uint64)
+	a.dropPolicies = loadDropPolicies()
 	a.metaBuilder = agent.NewMsgMetaBuilder(cfg.AgentId)
-	a.userMetaChan = make(chan *agent.UserInfoMeta, 32)
-	a.medalMetaChan = make(chan *agent.FansMedalMeta, 32)
+	a.userMetaChan = make(chan *agent.UserInfoMeta, cfg.MetaBuffer)
+	a.medalMetaChan = make(chan *agent.FansMedalMeta, cfg.MetaBuffer)
 	a.userMetaCache, err = bigcache.New(ctx, CacheConfig)
 	if err != nil {
 		klog.Fatalf("init cache failed: %s", err.Error())
@@ -92,14 +145,21 @@ func (a *DamakuCenterAgent) Init() {
 	if err != nil {
 		klog.Fatalf("init cache failed: %s", err.Error())
 	}
-	registerPacket := &agent.AgentInfo{ID: cfg.AgentId, Type: agent.AgentInfo_RealTimeAgent}
+	registerPacket := &agent.AgentInfo{
+		ID:       cfg.AgentId,
+		Type:     agent.AgentInfo_RealTimeAgent,
+		Version:  agent.VERSION,
+		MsgTypes: SupportedMsgTypes,
+		Build:    agent.BuildVersion(),
+		MaxRooms: cfg.MaxRooms,
+	}
 	registerData, err := proto.Marshal(registerPacket)
 	if err != nil {
 		klog.Fatalf("marshal register packet failed: %s", err.Error())
 	}
 	klog.Info("agent init started")
-	registerChan := make(chan *nats.Msg, 1)
-	registerSub, err := mq.Nc.ChanSubscribe(fmt.Sprintf("%s.agent.%s.init", cfg.SubjectPrefix, cfg.AgentId), registerChan)
+	a.initChan = make(chan *nats.Msg, 1)
+	registerSub, err := mq.Nc.ChanSubscribe(fmt.Sprintf("%s.agent.%s.init", cfg.SubjectPrefix, cfg.AgentId), a.initChan)
 	if err != nil {
 		klog.Fatalf("subscribe init subject failed: %s", err.Error())
 	}
@@ -111,25 +171,13 @@ initLoop:
 			if err := mq.Publish(fmt.Sprintf("%s.agent.info", cfg.SubjectPrefix), registerData); err != nil {
 				klog.Errorf("publish register msg failed: %s", err.Error())
 			}
-		case msg := <-registerChan:
+		case msg := <-a.initChan:
 			regMsg := &agent.AgentInit{}
 			if err := proto.Unmarshal(msg.Data, regMsg); err != nil {
 				klog.Errorf("unmarshal register msg failed: %s", err.Error())
 				continue
 			}
-			// setup bili client
-			biliChat.SetHeaderCookie(regMsg.Cookie)
-			biliChat.SetBuvid(regMsg.BUVID)
-			biliChat.SetUID(int64(regMsg.UID))
-			if regMsg.UA != nil {
-				biliChat.SetHeaderUA(regMsg.GetUA())
-			}
-			if regMsg.PriorityMode != nil {
-				biliChat.SetClientPriorityMode(int(regMsg.GetPriorityMode()))
-			}
-			for k, v := range regMsg.GetHeader() {
-				biliChatClient.Header.Set(k, v)
-			}
+			a.setup(regMsg)
 			controlSub, err := mq.Nc.ChanSubscribe(fmt.Sprintf("%s.agent.%s.action", cfg.SubjectPrefix, cfg.AgentId), a.controlChan)
 			if err != nil {
 				klog.Errorf("subscribe control subject failed: %s", err.Error())
@@ -141,21 +189,189 @@ initLoop:
 				klog.Errorf("response control msg failed: %s", err.Error()) // is error will be ignored
 			}
 			ticker.Stop()
-			_ = registerSub.Unsubscribe()
+			mq.AddSubscribe(registerSub) // keep for re-init
 			break initLoop
 		}
 	}
 	// start
 	klog.Infof("agent initialized, UID: %d", biliChatClient.UID)
 	go a.chatHandler.Run()
-	// all supported handler here
+	// all supported handler here, only the types that controller asked are forwarded
 	for _, msgType := range SupportedMsgTypes {
 		a.eventCounter[msgType] = &atomic.Int32{}
-		a.chatHandler.AddOption(0, &BLiveEventHandlerWrapper{Command: msgType, EventChan: a.eventChan, Counter: a.eventCounter[msgType]})
+		a.eventDropped[msgType] = &atomic.Uint64{}
+		if len(a.msgTypes) > 0 && !slices.Contains(a.msgTypes, msgType) {
+			continue
+		}
+		a.chatHandler.AddOption(0, &BLiveEventHandlerWrapper{
+			Command:   msgType,
+			EventChan: a.eventChan,
+			Counter:   a.eventCounter[msgType],
+			Policy:    a.dropPolicies[msgType],
+			OnDrop:    a.dropEvent,
+		})
 	}
+	klog.Infof("forwarding msg types: %v", condition.TernaryOperator(len(a.msgTypes) > 0, a.msgTypes, SupportedMsgTypes))
 	go a.controller()
-	go a.eventHandler()
+	for i := range a.eventChan {
+		go a.eventHandler(i)
+	}
 	go a.metaIndexer()
+	a.started.Store(true)
+}
+
+// drop policy of every bili msg cmd and meta type, DropPolicy of config if not set
+func loadDropPolicies() map[string]DropPolicy {
+	policies := make(map[string]DropPolicy)
+	for _, msgType := range append(slices.Clone(SupportedMsgTypes), "userInfoMeta", "fansMedal") {
+		policy := DropPolicy(cfg.DropPolicy)
+		if p, ok := cfg.DropPolicies[msgType]; ok {
+			policy = DropPolicy(p)
+		}
+		switch policy {
+		case DropBlock, DropNewest, DropOldest:
+		default:
+			klog.Fatalf("unknown drop policy of %s: %s", msgType, policy)
+		}
+		policies[msgType] = policy
+	}
+	for msgType := range cfg.DropPolicies {
+		if _, ok := policies[msgType]; !ok {
+			klog.Fatalf("unknown msg type of drop policy: %s", msgType)
+		}
+	}
+	return policies
+}
+
+// events waiting for all workers
+func (a *DamakuCenterAgent) eventBacklog() (backlog int) {
+	for _, shard := range a.eventChan {
+		backlog += shard.Len()
+	}
+	return
+}
+
+// dropEvent count event dropped or evicted from eventChan
+func (a *DamakuCenterAgent) dropEvent(msg *BLiveEventHandlerMsg) {
+	a.eventCounter[msg.event.Cmd].Add(-1)
+	a.eventDropped[msg.event.Cmd].Add(1)
+	klog.V(3).Infof("event buffer full, %s dropped", msg.event.Cmd)
+}
+
+func (a *DamakuCenterAgent) pushUserMeta(meta *agent.UserInfoMeta) {
+	sendWithPolicy(a.userMetaChan, meta, a.dropPolicies["userInfoMeta"], func(*agent.UserInfoMeta) { a.userMetaDropped.Add(1) })
+}
+
+func (a *DamakuCenterAgent) pushMedalMeta(meta *agent.FansMedalMeta) {
+	sendWithPolicy(a.medalMetaChan, meta, a.dropPolicies["fansMedal"], func(*agent.FansMedalMeta) { a.medalMetaDropped.Add(1) })
+}
+
+// setup bili client from AgentInit
+func (a *DamakuCenterAgent) setup(regMsg *agent.AgentInit) {
+	a.setDefaultCredential(&agent.AgentCredential{
+		BUVID:  regMsg.BUVID,
+		UID:    regMsg.UID,
+		Cookie: regMsg.Cookie,
+		UA:     regMsg.UA,
+		Header: regMsg.Header,
+	})
+	if regMsg.PriorityMode != nil {
+		biliChat.SetClientPriorityMode(int(regMsg.GetPriorityMode()))
+	}
+	if a.started.Load() {
+		return // handlers already registered, msg types can not be changed by re-init
+	}
+	a.msgTypes = regMsg.MsgTypes
+}
+
+// re-init bili client and reconnect all watching rooms with new identity
+func (a *DamakuCenterAgent) reinit(initMsg *nats.Msg) {
+	regMsg := &agent.AgentInit{}
+	if err := proto.Unmarshal(initMsg.Data, regMsg); err != nil {
+		klog.Errorf("unmarshal re-init msg failed: %s", err.Error())
+		_ = agent.ControlError(initMsg, err)
+		return
+	}
+	a.setup(regMsg)
+	a.watchingRooms.Range(func(key, value any) bool {
+		roomId := key.(uint64)
+		_ = a.chatHandler.DelRoom(int(roomId))
+		if err := a.addRoom(roomId, value.(*agent.AgentCredential)); err != nil {
+			klog.Errorf("reconnect room %d failed: %s", roomId, err.Error())
+			a.watchingRooms.Delete(roomId)
+		}
+		return true
+	})
+	klog.Infof("agent re-initialized, UID: %d", biliChatClient.UID)
+	if err := agent.ControlSuccess(initMsg); err != nil {
+		klog.Errorf("response control msg failed: %s", err.Error())
+	}
+}
+
+// collect agent status
+func (a *DamakuCenterAgent) status() *agent.AgentStatus {
+	status := &agent.AgentStatus{
+		Meta:             a.metaBuilder(),
+		BufferUsed:       uint32(a.eventBacklog()),
+		BufferSize:       uint32(len(a.eventChan) * cfg.EventBuffer),
+		BufferEventCount: make(map[int32]int32),
+		BufferDropped:    make(map[int32]uint64),
+		MetaCache:        make(map[int32]*agent.AgentStatus_MetaCacheInfo),
+		State:            a.State(),
+	}
+	a.watchingRooms.Range(func(key, _ any) bool {
+		uKey, ok := key.(uint64)
+		if !ok {
+			klog.Errorf("key: %+v not a uint64", key)
+			return true
+		}
+		status.Watching = append(status.Watching, uKey)
+		return true
+	})
+	for k, counter := range a.eventCounter {
+		status.BufferEventCount[int32(SupportedMsgTypesProto[k])] = counter.Load()
+	}
+	for _, shard := range a.eventChan {
+		status.WorkerBacklog = append(status.WorkerBacklog, uint32(shard.Len()))
+	}
+	for k, dropped := range a.eventDropped {
+		status.BufferDropped[int32(SupportedMsgTypesProto[k])] = dropped.Load()
+	}
+	userCacheStatus := a.userMetaCache.Stats()
+	medalCacheStatus := a.medalMetaCache.Stats()
+	status.MetaCache[int32(agent.AgentStatus_User)] = &agent.AgentStatus_MetaCacheInfo{
+		Buffer:     uint32(len(a.userMetaChan)),
+		Cached:     uint32(a.userMetaCache.Len()),
+		Hits:       userCacheStatus.Hits,
+		Misses:     userCacheStatus.Misses,
+		DelHits:    userCacheStatus.DelHits,
+		DelMisses:  userCacheStatus.DelMisses,
+		Collisions: userCacheStatus.Collisions,
+		Dropped:    a.userMetaDropped.Load(),
+	}
+	status.MetaCache[int32(agent.AgentStatus_Medal)] = &agent.AgentStatus_MetaCacheInfo{
+		Buffer:     uint32(len(a.medalMetaChan)),
+		Cached:     uint32(a.medalMetaCache.Len()),
+		Hits:       medalCacheStatus.Hits,
+		Misses:     medalCacheStatus.Misses,
+		DelHits:    medalCacheStatus.DelHits,
+		DelMisses:  medalCacheStatus.DelMisses,
+		Collisions: medalCacheStatus.Collisions,
+		Dropped:    a.medalMetaDropped.Load(),
+	}
+	return status
+}
+
+// publish agent status to controller
+func (a *DamakuCenterAgent) publishStatus(status *agent.AgentStatus) {
+	statusData, err := proto.Marshal(status)
+	if err != nil {
+		klog.Errorf("failed to marshal status data: %s", err.Error())
+		return
+	}
+	if err := mq.Publish(fmt.Sprintf("%s.agent.status", cfg.SubjectPrefix), statusData); err != nil {
+		klog.Errorf("publish status msg failed: %s", err.Error())
+	}
 }
 
 // collect status and receive control action
@@ -166,52 +382,9 @@ func (a *DamakuCenterAgent) controller() {
 	for {
 		select {
 		case <-collectTicker.C:
-			status := &agent.AgentStatus{
-				Meta:             a.metaBuilder(),
-				BufferUsed:       uint32(len(a.eventChan)),
-				BufferEventCount: make(map[int32]int32),
-				MetaCache:        make(map[int32]*agent.AgentStatus_MetaCacheInfo),
-			}
-			a.userMetaCache.Len()
-			a.watchingRooms.Range(func(key, _ any) bool {
-				uKey, ok := key.(uint64)
-				if !ok {
-					klog.Errorf("key: %+v not a uint64", key)
-					return true
-				}
-				status.Watching = append(status.Watching, uKey)
-				return true
-			})
-			for k, counter := range a.eventCounter {
-				status.BufferEventCount[int32(SupportedMsgTypesProto[k])] = counter.Load()
-			}
-			userCacheStatus := a.userMetaCache.Stats()
-			medalCacheStatus := a.medalMetaCache.Stats()
-			status.MetaCache[int32(agent.AgentStatus_User)] = &agent.AgentStatus_MetaCacheInfo{
-				Buffer:     uint32(len(a.userMetaChan)),
-				Cached:     uint32(a.userMetaCache.Len()),
-				Hits:       userCacheStatus.Hits,
-				Misses:     userCacheStatus.Misses,
-				DelHits:    userCacheStatus.DelHits,
-				DelMisses:  userCacheStatus.DelMisses,
-				Collisions: userCacheStatus.Collisions,
-			}
-			status.MetaCache[int32(agent.AgentStatus_Medal)] = &agent.AgentStatus_MetaCacheInfo{
-				Buffer:     uint32(len(a.medalMetaChan)),
-				Cached:     uint32(a.medalMetaCache.Len()),
-				Hits:       medalCacheStatus.Hits,
-				Misses:     medalCacheStatus.Misses,
-				DelHits:    medalCacheStatus.DelHits,
-				DelMisses:  medalCacheStatus.DelMisses,
-				Collisions: medalCacheStatus.Collisions,
-			}
-			statusData, err := proto.Marshal(status)
-			if err != nil {
-				klog.Errorf("failed to marshal status data: %s", err.Error())
-			}
-			if err := mq.Publish(fmt.Sprintf("%s.agent.status", cfg.SubjectPrefix), statusData); err != nil {
-				klog.Errorf("publish status msg failed: %s", err.Error())
-			}
+			a.publishStatus(a.status())
+		case initMsg := <-a.initChan:
+			a.reinit(initMsg)
 		case controlMsg := <-a.controlChan:
 			action := &agent.AgentAction{}
 			if err := proto.Unmarshal(controlMsg.Data, action); err != nil {
@@ -224,6 +397,7 @@ func (a *DamakuCenterAgent) controller() {
 					if err := agent.ControlError(controlMsg, errors.New("no room id")); err != nil {
 						klog.Errorf("response control msg failed: %s", err.Error())
 					}
+					continue
 				}
 				//if *action.RoomID < 10000 {
 				//	klog.Errorf("unsupported room id: %d", *action.RoomID)
@@ -231,8 +405,28 @@ func (a *DamakuCenterAgent) controller() {
 				//}
 				klog.V(3).Infof("action: %s %d", agent.AgentAction_AgentActionType_name[int32(action.Type)], action.RoomID)
 				if action.Type == agent.AgentAction_AddRoom {
-					_ = a.chatHandler.AddRoom(int(*action.RoomID))
-					a.watchingRooms.Store(*action.RoomID, struct{}{})
+					if state := a.State(); state == agent.AgentStatus_Draining || state == agent.AgentStatus_Stopped {
+						klog.Warningf("reject room %d: agent is %s", *action.RoomID, state)
+						if err := agent.ControlError(controlMsg, fmt.Errorf("agent is %s", state)); err != nil {
+							klog.Errorf("response control msg failed: %s", err.Error())
+						}
+						continue
+					}
+					if cfg.MaxRooms > 0 && a.watchingCount() >= int(cfg.MaxRooms) {
+						klog.Warningf("reject room %d: reached max rooms %d", *action.RoomID, cfg.MaxRooms)
+						if err := agent.ControlError(controlMsg, fmt.Errorf("reached max rooms: %d", cfg.MaxRooms)); err != nil {
+							klog.Errorf("response control msg failed: %s", err.Error())
+						}
+						continue
+					}
+					if err := a.addRoom(*action.RoomID, action.Credential); err != nil {
+						klog.Errorf("add room %d failed: %s", *action.RoomID, err.Error())
+						if err := agent.ControlError(controlMsg, err); err != nil {
+							klog.Errorf("response control msg failed: %s", err.Error())
+						}
+						continue
+					}
+					a.watchingRooms.Store(*action.RoomID, action.Credential)
 				} else if action.Type == agent.AgentAction_DelRoom {
 					_ = a.chatHandler.DelRoom(int(*action.RoomID))
 					a.watchingRooms.Delete(*action.RoomID)
@@ -240,6 +434,21 @@ func (a *DamakuCenterAgent) controller() {
 				if err := agent.ControlSuccess(controlMsg); err != nil {
 					klog.Errorf("response control msg failed: %s", err.Error())
 				}
+			case agent.AgentAction_Drain, agent.AgentAction_Pause, agent.AgentAction_Resume, agent.AgentAction_Shutdown:
+				klog.Infof("action: %s", agent.AgentAction_AgentActionType_name[int32(action.Type)])
+				if err := a.lifecycle(action.Type); err != nil {
+					klog.Errorf("%s failed: %s", action.Type, err.Error())
+					if err := agent.ControlError(controlMsg, err); err != nil {
+						klog.Errorf("response control msg failed: %s", err.Error())
+					}
+					continue
+				}
+				if err := agent.ControlSuccess(controlMsg); err != nil {
+					klog.Errorf("response control msg failed: %s", err.Error())
+				}
+				if action.Type == agent.AgentAction_Shutdown {
+					go a.Shutdown() // responded before rooms are released
+				}
 			}
 		case <-ctx.Done():
 			klog.Infof("agent controller stopped")
@@ -249,14 +458,22 @@ func (a *DamakuCenterAgent) controller() {
 	}
 }
 
-// can be parallelization
-func (a *DamakuCenterAgent) eventHandler() {
+// parse and publish events of rooms sharded to this worker
+func (a *DamakuCenterAgent) eventHandler(workerId int) {
 	worker.Add(1)
-	klog.Info("event handler started")
+	klog.Infof("event handler %d started", workerId)
+	queue := a.eventChan[workerId]
 	for {
 		select {
-		case msg := <-a.eventChan:
+		case <-queue.Ready():
+			msg, ok := queue.Pop()
+			if !ok {
+				continue
+			}
 			a.eventCounter[msg.event.Cmd].Add(-1) // counter
+			if a.State() == agent.AgentStatus_Paused {
+				continue
+			}
 			msg.processTime = time.Now()
 			roomId := uint64(msg.event.RoomId)
 			switch msg.event.Cmd {
@@ -270,10 +487,11 @@ func (a *DamakuCenterAgent) eventHandler() {
 				meta.TimeStamp = data.Get("info.0.4").Uint()
 				userMeta.UID = data.Get("info.2.0").Uint()
 				userMeta.UserName = data.Get("info.2.1").String()
+				userMeta.TimeStamp = meta.TimeStamp
 				if userFace := data.Get("info.0.15.user.base.face").String(); userFace != "" {
 					userMeta.Face = &userFace
 				}
-				a.userMetaChan <- userMeta
+				a.pushUserMeta(userMeta)
 
 				medal := &agent.FansMedalMeta{
 					UID:        userMeta.UID,
@@ -282,8 +500,9 @@ func (a *DamakuCenterAgent) eventHandler() {
 					Level:      uint32(data.Get("info.3.0").Uint()),
 					Light:      data.Get("info.3.11").Bool(),
 					GuardLevel: agent.GuardLevelType(data.Get("info.3.10").Uint()),
+					TimeStamp:  meta.TimeStamp,
 				}
-				a.medalMetaChan <- medal
+				a.pushMedalMeta(medal)
 
 				danmaku := &agent.Damaku{
 					Meta:    meta,
@@ -299,7 +518,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					klog.Errorf("failed to marshal danmaku: %s", err.Error())
 					continue
 				}
-				if err := mq.Publish(fmt.Sprintf("%s.stream.damaku", cfg.SubjectPrefix), sendData); err != nil {
+				if err := publishStream("damaku", sendData); err != nil {
 					klog.Errorf("publish danmaku message failed: %s", err.Error())
 				}
 				klog.V(5).Infof("danmaku push")
@@ -315,8 +534,9 @@ func (a *DamakuCenterAgent) eventHandler() {
 					continue
 				}
 				userMeta := &agent.UserInfoMeta{
-					UID:      uint64(giftData.Data.UID),
-					UserName: giftData.Data.Name,
+					UID:       uint64(giftData.Data.UID),
+					UserName:  giftData.Data.Name,
+					TimeStamp: uint64(giftData.Data.Timestamp * 1000),
 				}
 				if giftData.Data.Face != "" {
 					userMeta.Face = &giftData.Data.Face
@@ -324,10 +544,11 @@ func (a *DamakuCenterAgent) eventHandler() {
 				if extraGiftData.Data.WealthLevel != 0 {
 					userMeta.WealthLevel = &extraGiftData.Data.WealthLevel
 				}
-				a.userMetaChan <- userMeta
+				a.pushUserMeta(userMeta)
 
 				medal := &agent.FansMedalMeta{
-					UID: userMeta.UID,
+					UID:       userMeta.UID,
+					TimeStamp: userMeta.TimeStamp,
 				}
 				if giftData.Data.FansMedal != nil {
 					medal.RoomUID = uint64(giftData.Data.FansMedal.TargetId)
@@ -336,16 +557,17 @@ func (a *DamakuCenterAgent) eventHandler() {
 					medal.Light = condition.TernaryOperator(giftData.Data.FansMedal.IsLighted, true, false)
 					medal.GuardLevel = agent.GuardLevelType(giftData.Data.FansMedal.GuardLevel)
 				}
-				a.medalMetaChan <- medal
+				a.pushMedalMeta(medal)
 
 				gift := &agent.Gift{
 					Meta:  a.metaBuilder(),
 					UID:   userMeta.UID,
 					Count: uint32(giftData.Data.Num),
 					Medal: medal.RoomUID,
+					Coin:  agent.CoinTypeOf(gjson.GetBytes(msg.event.RawMessage, "data.coin_type").String()),
 				}
 				gift.Meta.RoomID = &roomId
-				gift.Meta.TimeStamp = uint64(giftData.Data.Timestamp * 1000)
+				gift.Meta.TimeStamp = userMeta.TimeStamp
 				giftId, err := strconv.ParseInt(giftData.Data.Tid, 10, 64)
 				if err != nil {
 					klog.Errorf("failed to parse gift id(%s): %s", giftData.Data.Rnd, err.Error())
@@ -374,7 +596,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					klog.Errorf("failed to marshal gift: %s", err.Error())
 					continue
 				}
-				if err := mq.Publish(fmt.Sprintf("%s.stream.gift", cfg.SubjectPrefix), sendData); err != nil {
+				if err := publishStream("gift", sendData); err != nil {
 					klog.Errorf("publish gift message failed: %s", err.Error())
 				}
 				klog.V(5).Infof("gift push")
@@ -385,10 +607,11 @@ func (a *DamakuCenterAgent) eventHandler() {
 					continue
 				}
 				userMeta := &agent.UserInfoMeta{
-					UID:      uint64(guardData.Data.UID),
-					UserName: guardData.Data.Username,
+					UID:       uint64(guardData.Data.UID),
+					UserName:  guardData.Data.Username,
+					TimeStamp: uint64(guardData.Data.StartTime * 1000),
 				}
-				a.userMetaChan <- userMeta
+				a.pushUserMeta(userMeta)
 
 				guard := &agent.Guard{
 					Meta:     a.metaBuilder(),
@@ -397,7 +620,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					GiftType: agent.Guard_GuardGiftType(guardData.Data.GiftID),
 				}
 				guard.Meta.RoomID = &roomId
-				guard.Meta.TimeStamp = uint64(guardData.Data.StartTime * 1000)
+				guard.Meta.TimeStamp = userMeta.TimeStamp
 				// trace
 				guard.Meta.Trace[int32(agent.BasicMsgMeta_Wait)] = uint64(msg.processTime.Sub(msg.startTime).Microseconds())
 				guard.Meta.Trace[int32(agent.BasicMsgMeta_Process)] = uint64(time.Now().Sub(msg.processTime).Microseconds())
@@ -406,7 +629,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					klog.Errorf("failed to marshal guard: %s", err.Error())
 					continue
 				}
-				if err := mq.Publish(fmt.Sprintf("%s.stream.guard", cfg.SubjectPrefix), sendData); err != nil {
+				if err := publishStream("guard", sendData); err != nil {
 					klog.Errorf("publish guard message failed: %s", err.Error())
 				}
 				klog.V(5).Infof("guard push")
@@ -421,16 +644,18 @@ func (a *DamakuCenterAgent) eventHandler() {
 					}
 				}
 				userMeta := &agent.UserInfoMeta{
-					UID:      uint64(scData.Data.Uid),
-					UserName: scData.Data.UInfo.Base.Name,
-					Face:     &scData.Data.UInfo.Base.Face,
+					UID:       uint64(scData.Data.Uid),
+					UserName:  scData.Data.UInfo.Base.Name,
+					Face:      &scData.Data.UInfo.Base.Face,
+					TimeStamp: uint64(scData.Data.Ts) * 1000,
 				}
 				uLevel := uint32(scData.Data.UserInfo.UserLevel)
 				userMeta.Level = &uLevel
-				a.userMetaChan <- userMeta
+				a.pushUserMeta(userMeta)
 
 				medal := &agent.FansMedalMeta{
-					UID: userMeta.UID,
+					UID:       userMeta.UID,
+					TimeStamp: userMeta.TimeStamp,
 				}
 				if scData.Data.UInfo.Medal.Ruid != 0 {
 					medal.RoomUID = uint64(scData.Data.UInfo.Medal.Ruid)
@@ -439,7 +664,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					medal.Light = condition.TernaryOperator(scData.Data.UInfo.Medal.IsLight, true, false)
 					medal.GuardLevel = agent.GuardLevelType(uint32(scData.Data.UInfo.Medal.GuardLevel))
 				}
-				a.medalMetaChan <- medal
+				a.pushMedalMeta(medal)
 
 				sc := &agent.SuperChat{
 					Meta:         a.metaBuilder(),
@@ -451,7 +676,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					Medal:        medal.RoomUID,
 				}
 				sc.Meta.RoomID = &roomId
-				sc.Meta.TimeStamp = uint64(scData.Data.Ts)
+				sc.Meta.TimeStamp = userMeta.TimeStamp
 				// trace
 				sc.Meta.Trace[int32(agent.BasicMsgMeta_Wait)] = uint64(msg.processTime.Sub(msg.startTime).Microseconds())
 				sc.Meta.Trace[int32(agent.BasicMsgMeta_Process)] = uint64(time.Now().Sub(msg.processTime).Microseconds())
@@ -460,7 +685,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					klog.Errorf("failed to marshal superChat: %s", err.Error())
 					continue
 				}
-				if err := mq.Publish(fmt.Sprintf("%s.stream.superChat", cfg.SubjectPrefix), sendData); err != nil {
+				if err := publishStream("superChat", sendData); err != nil {
 					klog.Errorf("publish superChat message failed: %s", err.Error())
 				}
 				klog.V(5).Infof("superChat push")
@@ -485,7 +710,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 					klog.Errorf("failed to marshal onlineRankCount: %s", err.Error())
 					continue
 				}
-				if err := mq.Publish(fmt.Sprintf("%s.stream.online", cfg.SubjectPrefix), sendData); err != nil {
+				if err := publishStream("online", sendData); err != nil {
 					klog.Errorf("publish onlineRankCount message failed: %s", err.Error())
 				}
 				klog.V(5).Infof("onlineRankCount push")
@@ -503,7 +728,7 @@ func (a *DamakuCenterAgent) eventHandler() {
 						UserName: rank.Name,
 						Face:     &rank.Face,
 					}
-					a.userMetaChan <- userMeta
+					a.pushUserMeta(userMeta)
 
 					rankScore, err := strconv.ParseUint(rank.Score, 10, 32)
 					if err != nil {
@@ -530,22 +755,150 @@ func (a *DamakuCenterAgent) eventHandler() {
 					klog.Errorf("failed to marshal onlineRankV2: %s", err.Error())
 					continue
 				}
-				if err := mq.Publish(fmt.Sprintf("%s.stream.onlineV2", cfg.SubjectPrefix), sendData); err != nil {
+				if err := publishStream("onlineV2", sendData); err != nil {
 					klog.Errorf("publish onlineRankV2 message failed: %s", err.Error())
 				}
 				klog.V(5).Infof("onlineRankV2 push")
+			case CmdInteractWord:
+				var interactData InteractWord
+				if err := sonic.Unmarshal(msg.event.RawMessage, &interactData); err != nil {
+					klog.Errorf("failed to unmarshal interact word: %s", err.Error())
+					continue
+				}
+				interact := &agent.Interact{
+					Meta: a.metaBuilder(),
+					UID:  interactData.Data.UID,
+					Type: agent.Interact_InteractType(interactData.Data.MsgType),
+				}
+				interact.Meta.RoomID = &roomId
+				if interactData.Data.TriggerTime > 0 {
+					interact.Meta.TimeStamp = uint64(interactData.Data.TriggerTime / int64(time.Millisecond))
+				} else {
+					interact.Meta.TimeStamp = uint64(interactData.Data.Timestamp * 1000)
+				}
+				userMeta := &agent.UserInfoMeta{
+					UID:       interactData.Data.UID,
+					UserName:  interactData.Data.UName,
+					TimeStamp: interact.Meta.TimeStamp,
+				}
+				if face := interactData.Data.UInfo.Base.Face; face != "" {
+					userMeta.Face = &face
+				}
+				a.pushUserMeta(userMeta)
+
+				if medalData := interactData.Data.FansMedal; medalData != nil && medalData.TargetID != 0 {
+					a.pushMedalMeta(&agent.FansMedalMeta{
+						UID:        userMeta.UID,
+						RoomUID:    medalData.TargetID,
+						Name:       medalData.MedalName,
+						Level:      medalData.MedalLevel,
+						Light:      condition.TernaryOperator(medalData.IsLighted, true, false),
+						GuardLevel: agent.GuardLevelType(medalData.GuardLevel),
+						TimeStamp:  interact.Meta.TimeStamp,
+					})
+					interact.Medal = medalData.TargetID
+				}
+				a.publishEvent("interact", interact, msg)
+			case CmdLikeInfoV3Update:
+				var likeData LikeInfoV3Update
+				if err := sonic.Unmarshal(msg.event.RawMessage, &likeData); err != nil {
+					klog.Errorf("failed to unmarshal like info: %s", err.Error())
+					continue
+				}
+				like := &agent.LikeCount{
+					Meta:  a.metaBuilder(),
+					Count: likeData.Data.ClickCount,
+				}
+				like.Meta.RoomID = &roomId
+				a.publishEvent("like", like, msg)
+			case CmdRoomChange:
+				var changeData RoomChange
+				if err := sonic.Unmarshal(msg.event.RawMessage, &changeData); err != nil {
+					klog.Errorf("failed to unmarshal room change: %s", err.Error())
+					continue
+				}
+				change := &agent.RoomChange{
+					Meta:           a.metaBuilder(),
+					Title:          changeData.Data.Title,
+					AreaID:         changeData.Data.AreaID,
+					AreaName:       changeData.Data.AreaName,
+					ParentAreaID:   changeData.Data.ParentAreaID,
+					ParentAreaName: changeData.Data.ParentAreaName,
+				}
+				change.Meta.RoomID = &roomId
+				a.publishEvent("roomChange", change, msg)
+			case CmdLive, CmdPreparing:
+				status := &agent.LiveStatus{
+					Meta: a.metaBuilder(),
+					Live: msg.event.Cmd == CmdLive,
+				}
+				if status.Live {
+					var liveData LiveStart
+					if err := sonic.Unmarshal(msg.event.RawMessage, &liveData); err != nil {
+						klog.Errorf("failed to unmarshal live start: %s", err.Error())
+						continue
+					}
+					status.LiveTime = uint64(liveData.LiveTime * 1000)
+					status.LiveKey = liveData.LiveKey
+				}
+				status.Meta.RoomID = &roomId
+				a.publishEvent("liveStatus", status, msg)
+			case CmdWatchedChange:
+				var watchedData WatchedChange
+				if err := sonic.Unmarshal(msg.event.RawMessage, &watchedData); err != nil {
+					klog.Errorf("failed to unmarshal watched change: %s", err.Error())
+					continue
+				}
+				watched := &agent.WatchedCount{
+					Meta:  a.metaBuilder(),
+					Count: watchedData.Data.Num,
+				}
+				watched.Meta.RoomID = &roomId
+				a.publishEvent("watched", watched, msg)
+			case CmdRoomRealTimeMessageUpdate:
+				var fansData RoomRealTimeMessageUpdate
+				if err := sonic.Unmarshal(msg.event.RawMessage, &fansData); err != nil {
+					klog.Errorf("failed to unmarshal room real time message: %s", err.Error())
+					continue
+				}
+				fans := &agent.FansCount{
+					Meta:     a.metaBuilder(),
+					Fans:     fansData.Data.Fans,
+					FansClub: fansData.Data.FansClub,
+				}
+				fans.Meta.RoomID = &roomId
+				a.publishEvent("fans", fans, msg)
 			default:
 				klog.Warningf("unsupported command: %s", msg.event.Cmd)
 				continue
 			}
 		case <-ctx.Done():
-			klog.Infof("agent event handler stopped")
+			klog.Infof("agent event handler %d stopped", workerId)
 			worker.Done()
 			return
 		}
 	}
 }
 
+// trace event and publish it to stream
+func (a *DamakuCenterAgent) publishEvent(streamType string, event interface {
+	proto.Message
+	GetMeta() *agent.BasicMsgMeta
+}, msg *BLiveEventHandlerMsg) {
+	meta := event.GetMeta()
+	meta.Trace[int32(agent.BasicMsgMeta_Wait)] = uint64(msg.processTime.Sub(msg.startTime).Microseconds())
+	meta.Trace[int32(agent.BasicMsgMeta_Process)] = uint64(time.Now().Sub(msg.processTime).Microseconds())
+	sendData, err := proto.Marshal(event)
+	if err != nil {
+		klog.Errorf("failed to marshal %s: %s", streamType, err.Error())
+		return
+	}
+	if err := publishStream(streamType, sendData); err != nil {
+		klog.Errorf("publish %s message failed: %s", streamType, err.Error())
+	}
+	klog.V(5).Infof("%s push", streamType)
+}
+
 // update & sync user/medal meta cache
 func (a *DamakuCenterAgent) metaIndexer() {
 	worker.Add(1)
@@ -558,7 +911,7 @@ func (a *DamakuCenterAgent) metaIndexer() {
 			return
 		}
 		klog.V(5).Infof("meta(%s) push: %s", syncSubject, cacheKey)
-		if err := mq.Publish(fmt.Sprintf("%s.stream.%s", cfg.SubjectPrefix, syncSubject), data); err != nil {
+		if err := publishStream(syncSubject, data); err != nil {
 			klog.Errorf("publish %s meta message failed: %s", syncSubject, err.Error())
 		}
 		if err := cache.Set(cacheKey, data); err != nil {
@@ -575,7 +928,7 @@ func (a *DamakuCenterAgent) metaIndexer() {
 			if err != nil {
 				if errors.Is(err, bigcache.ErrEntryNotFound) {
 					// no cache sync
-					syncMeta(a.userMetaCache, userKey, "userInfo", meta)
+					syncMeta(a.userMetaCache, userKey, "userInfoMeta", meta)
 					continue
 				}
 				klog.Errorf("failed to get cached user meta: %s", err.Error())
@@ -25,6 +25,7 @@ var (
 )
 
 func TestAgentFunc(t *testing.T) {
+	envx.MustLoadEnv(cfg)
 	testCfg := &TestConfig{}
 	envx.MustLoadEnv(testCfg)
 	t.Log("Waiting agent...")
@@ -111,4 +112,10 @@ func BindEvent(mq *nats.Conn, prefix, agentId string) {
 	_, _ = mq.Subscribe(prefix+".stream.superChat", EventShow(&agent.SuperChat{}))
 	_, _ = mq.Subscribe(prefix+".stream.online", EventShow(&agent.OnlineRankCount{}))
 	_, _ = mq.Subscribe(prefix+".stream.onlineV2", EventShow(&agent.OnlineRankV2{}))
+	_, _ = mq.Subscribe(prefix+".stream.interact", EventShow(&agent.Interact{}))
+	_, _ = mq.Subscribe(prefix+".stream.like", EventShow(&agent.LikeCount{}))
+	_, _ = mq.Subscribe(prefix+".stream.roomChange", EventShow(&agent.RoomChange{}))
+	_, _ = mq.Subscribe(prefix+".stream.liveStatus", EventShow(&agent.LiveStatus{}))
+	_, _ = mq.Subscribe(prefix+".stream.watched", EventShow(&agent.WatchedCount{}))
+	_, _ = mq.Subscribe(prefix+".stream.fans", EventShow(&agent.FansCount{}))
 }
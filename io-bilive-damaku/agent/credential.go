@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	biliChat "github.com/FishZe/go-bili-chat/v2"
+	biliChatClient "github.com/FishZe/go-bili-chat/v2/client"
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+)
+
+// applyCredential set account identity of bili client, it is global at go-bili-chat
+func applyCredential(cred *agent.AgentCredential) {
+	biliChat.SetHeaderCookie(cred.Cookie)
+	biliChat.SetBuvid(cred.BUVID)
+	biliChat.SetUID(int64(cred.UID))
+	if cred.UA != nil {
+		biliChat.SetHeaderUA(cred.GetUA())
+	}
+	for k, v := range cred.GetHeader() {
+		biliChatClient.Header.Set(k, v)
+	}
+}
+
+// setDefaultCredential apply the identity from AgentInit and keep it for restoring
+func (a *DamakuCenterAgent) setDefaultCredential(cred *agent.AgentCredential) {
+	a.credentialMu.Lock()
+	defer a.credentialMu.Unlock()
+	applyCredential(cred)
+	a.defaultCredential = cred
+	a.defaultHeader = biliChatClient.Header.Clone()
+}
+
+// addRoom connect room with custom credential, the identity is read when connecting,
+// so rooms are added one by one and the default identity restored after that
+func (a *DamakuCenterAgent) addRoom(roomId uint64, cred *agent.AgentCredential) error {
+	a.credentialMu.Lock()
+	defer a.credentialMu.Unlock()
+	if cred == nil {
+		return a.chatHandler.AddRoom(int(roomId))
+	}
+	applyCredential(cred)
+	defer a.restoreCredential()
+	return a.chatHandler.AddRoom(int(roomId))
+}
+
+func (a *DamakuCenterAgent) restoreCredential() {
+	restoreHeader(biliChatClient.Header, a.defaultHeader)
+	applyCredential(a.defaultCredential)
+}
+
+// restore header in place, client may hold the reference
+func restoreHeader(header, from http.Header) {
+	for k := range header {
+		if _, ok := from[k]; !ok {
+			header.Del(k)
+		}
+	}
+	for k, v := range from {
+		header[k] = slices.Clone(v)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"k8s.io/klog/v2"
+)
+
+var stopped = make(chan struct{}) // closed after final status published
+
+func (a *DamakuCenterAgent) State() agent.AgentStatus_AgentState {
+	return agent.AgentStatus_AgentState(a.state.Load())
+}
+
+// switch state from one of current states
+func (a *DamakuCenterAgent) switchState(to agent.AgentStatus_AgentState, from ...agent.AgentStatus_AgentState) error {
+	for _, state := range from {
+		if a.state.CompareAndSwap(int32(state), int32(to)) {
+			klog.Infof("agent state changed: %s -> %s", state, to)
+			return nil
+		}
+	}
+	return fmt.Errorf("can not switch to %s from %s", to, a.State())
+}
+
+// lifecycle handle state control action, Shutdown is started after responded
+func (a *DamakuCenterAgent) lifecycle(action agent.AgentAction_AgentActionType) error {
+	switch action {
+	case agent.AgentAction_Drain:
+		return a.switchState(agent.AgentStatus_Draining, agent.AgentStatus_Running, agent.AgentStatus_Paused)
+	case agent.AgentAction_Pause:
+		return a.switchState(agent.AgentStatus_Paused, agent.AgentStatus_Running)
+	case agent.AgentAction_Resume:
+		return a.switchState(agent.AgentStatus_Running, agent.AgentStatus_Paused, agent.AgentStatus_Draining)
+	case agent.AgentAction_Shutdown:
+		if a.State() == agent.AgentStatus_Stopped {
+			return errors.New("agent already stopped")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported action: %s", action)
+	}
+}
+
+// Drain stop accepting rooms and wait controller moving rooms to other agents, then shutdown
+func (a *DamakuCenterAgent) Drain(timeout time.Duration) {
+	_ = a.switchState(agent.AgentStatus_Draining, agent.AgentStatus_Running, agent.AgentStatus_Paused)
+	a.publishStatus(a.status()) // notify controller at once
+	klog.Infof("draining, wait rooms moved in %s", timeout)
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(time.Millisecond * 500)
+	defer ticker.Stop()
+waitLoop:
+	for a.watchingCount() > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			klog.Warningf("drain timeout, %d rooms still watching", a.watchingCount())
+			break waitLoop
+		case <-stopped:
+			return
+		}
+	}
+	a.Shutdown()
+}
+
+// Shutdown release all rooms, flush buffered events and publish the final status
+func (a *DamakuCenterAgent) Shutdown() {
+	a.stopOnce.Do(func() {
+		a.state.Store(int32(agent.AgentStatus_Stopped))
+		klog.Info("agent shutting down")
+		a.watchingRooms.Range(func(key, _ any) bool {
+			roomId := key.(uint64)
+			_ = a.chatHandler.DelRoom(int(roomId))
+			a.watchingRooms.Delete(roomId)
+			return true
+		})
+		a.flush(cfg.FlushTimeout)
+		a.publishStatus(a.status())
+		klog.Info("final status published")
+		close(stopped)
+	})
+}
+
+// wait for all buffered events and meta published
+func (a *DamakuCenterAgent) flush(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for a.eventBacklog() > 0 || len(a.userMetaChan) > 0 || len(a.medalMetaChan) > 0 {
+		if time.Now().After(deadline) {
+			klog.Warningf("flush timeout, dropped events: %d, user meta: %d, medal meta: %d",
+				a.eventBacklog(), len(a.userMetaChan), len(a.medalMetaChan))
+			return
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+}
+
+func (a *DamakuCenterAgent) watchingCount() (count int) {
+	a.watchingRooms.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	return
+}
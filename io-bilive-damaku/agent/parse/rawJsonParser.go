@@ -48,6 +48,7 @@ func Gift(rawData string, user *agent.UserInfoMeta, medal *agent.FansMedalMeta,
 	gift.Count = uint32(data.Get("num").Uint())
 	gift.Medal = medal.RoomUID
 	gift.TID = data.Get("tid").Uint()
+	gift.Coin = agent.CoinTypeOf(data.Get("coin_type").String())
 	if gift.Info == nil {
 		gift.Info = &agent.Gift_GiftInfo{}
 	}
@@ -78,7 +79,7 @@ func Guard(rawData string, user *agent.UserInfoMeta, guard *agent.Guard) {
 
 func SuperChat(rawData string, user *agent.UserInfoMeta, medal *agent.FansMedalMeta, sc *agent.SuperChat) {
 	data := gjson.Parse(rawData)
-	sc.Meta.TimeStamp = data.Get("ts").Uint()
+	sc.Meta.TimeStamp = data.Get("ts").Uint() * 1000
 	user.UID = data.Get("uid").Uint()
 	user.UserName = data.Get("uinfo.base.name").String()
 	face := data.Get("uinfo.base.face").String()
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// eventQueue is the bounded FIFO of an event handler worker, a full queue is handled by DropPolicy of the pushed msg.
+// DropOldest only evicts the oldest msg of the same cmd, so that policies of other msg types always hold
+type eventQueue struct {
+	mu    sync.Mutex
+	space *sync.Cond // signalled on pop, for blocked push
+	items []*BLiveEventHandlerMsg
+	size  int
+	ready chan struct{} // signalled while queue is not empty
+}
+
+func newEventQueue(size int) *eventQueue {
+	q := &eventQueue{
+		items: make([]*BLiveEventHandlerMsg, 0, size),
+		size:  max(size, 1),
+		ready: make(chan struct{}, 1),
+	}
+	q.space = sync.NewCond(&q.mu)
+	return q
+}
+
+// Push msg by policy, onDrop is called for every dropped or evicted msg, return false if msg itself is dropped.
+// With DropOldest msg is dropped if no msg of the same cmd can be evicted
+func (q *eventQueue) Push(msg *BLiveEventHandlerMsg, policy DropPolicy, onDrop func(*BLiveEventHandlerMsg)) bool {
+	var evicted *BLiveEventHandlerMsg
+	q.mu.Lock()
+	for len(q.items) >= q.size {
+		switch policy {
+		case DropNewest:
+			q.mu.Unlock()
+			onDrop(msg)
+			return false
+		case DropOldest:
+			i := slices.IndexFunc(q.items, func(m *BLiveEventHandlerMsg) bool { return m.event.Cmd == msg.event.Cmd })
+			if i < 0 {
+				q.mu.Unlock()
+				onDrop(msg)
+				return false
+			}
+			evicted = q.items[i]
+			q.items = slices.Delete(q.items, i, i+1)
+		default:
+			q.space.Wait()
+		}
+	}
+	q.items = append(q.items, msg)
+	q.mu.Unlock()
+	q.signal()
+	if evicted != nil {
+		onDrop(evicted)
+	}
+	return true
+}
+
+// Pop the head msg, false if queue is empty
+func (q *eventQueue) Pop() (*BLiveEventHandlerMsg, bool) {
+	q.mu.Lock()
+	if len(q.items) == 0 {
+		q.mu.Unlock()
+		return nil, false
+	}
+	msg := q.items[0]
+	q.items = slices.Delete(q.items, 0, 1)
+	remaining := len(q.items)
+	q.mu.Unlock()
+	q.space.Signal()
+	if remaining > 0 {
+		q.signal() // keep ready for the next pop
+	}
+	return msg, true
+}
+
+// Ready is signalled when msg may be popped
+func (q *eventQueue) Ready() <-chan struct{} {
+	return q.ready
+}
+
+func (q *eventQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FishZe/go-bili-chat/v2/events"
+)
+
+func newTestMsg(cmd string, roomId int) *BLiveEventHandlerMsg {
+	return &BLiveEventHandlerMsg{event: &events.BLiveEvent{Cmd: cmd, RoomId: roomId}}
+}
+
+func popAll(q *eventQueue) (msgs []*BLiveEventHandlerMsg) {
+	for {
+		msg, ok := q.Pop()
+		if !ok {
+			return
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestSendWithPolicy(t *testing.T) {
+	cases := []struct {
+		policy  DropPolicy
+		sent    bool
+		dropped int
+		want    []int
+	}{
+		{DropNewest, false, 3, []int{1, 2}},
+		{DropOldest, true, 1, []int{2, 3}},
+	}
+	for _, c := range cases {
+		t.Run(string(c.policy), func(t *testing.T) {
+			ch := make(chan int, 2)
+			ch <- 1
+			ch <- 2
+			var dropped int
+			if sent := sendWithPolicy(ch, 3, c.policy, func(msg int) { dropped = msg }); sent != c.sent {
+				t.Fatalf("unexpected sent: %v", sent)
+			}
+			if dropped != c.dropped {
+				t.Fatalf("unexpected dropped: %d, want %d", dropped, c.dropped)
+			}
+			close(ch)
+			var got []int
+			for msg := range ch {
+				got = append(got, msg)
+			}
+			if len(got) != len(c.want) || got[0] != c.want[0] || got[1] != c.want[1] {
+				t.Fatalf("unexpected buffer: %v, want %v", got, c.want)
+			}
+		})
+	}
+	t.Run(string(DropBlock), func(t *testing.T) {
+		ch := make(chan int, 1)
+		ch <- 1
+		done := make(chan bool)
+		go func() { done <- sendWithPolicy(ch, 2, DropBlock, func(int) { t.Error("block policy dropped msg") }) }()
+		select {
+		case <-done:
+			t.Fatal("send not blocked on full buffer")
+		case <-time.After(50 * time.Millisecond):
+		}
+		if msg := <-ch; msg != 1 {
+			t.Fatalf("unexpected head: %d", msg)
+		}
+		if !<-done {
+			t.Fatal("blocked msg not sent")
+		}
+		if msg := <-ch; msg != 2 {
+			t.Fatalf("unexpected msg: %d", msg)
+		}
+	})
+}
+
+func TestEventQueue(t *testing.T) {
+	damaku := newTestMsg(events.CmdDanmuMsg, 1)
+	gift := newTestMsg(events.CmdSendGift, 1)
+	cases := []struct {
+		name    string
+		queued  []*BLiveEventHandlerMsg
+		push    *BLiveEventHandlerMsg
+		policy  DropPolicy
+		sent    bool
+		dropped []string // cmd of dropped msgs
+		want    []string // cmd of queued msgs
+	}{
+		{"newest", []*BLiveEventHandlerMsg{damaku, gift}, newTestMsg(events.CmdDanmuMsg, 2), DropNewest, false,
+			[]string{events.CmdDanmuMsg}, []string{events.CmdDanmuMsg, events.CmdSendGift}},
+		{"oldest evicts same cmd", []*BLiveEventHandlerMsg{gift, damaku}, newTestMsg(events.CmdDanmuMsg, 2), DropOldest, true,
+			[]string{events.CmdDanmuMsg}, []string{events.CmdSendGift, events.CmdDanmuMsg}},
+		{"oldest keeps other cmd", []*BLiveEventHandlerMsg{gift, gift}, damaku, DropOldest, false,
+			[]string{events.CmdDanmuMsg}, []string{events.CmdSendGift, events.CmdSendGift}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q := newEventQueue(len(c.queued))
+			for _, msg := range c.queued {
+				q.Push(msg, DropBlock, nil)
+			}
+			var dropped []string
+			if sent := q.Push(c.push, c.policy, func(msg *BLiveEventHandlerMsg) { dropped = append(dropped, msg.event.Cmd) }); sent != c.sent {
+				t.Fatalf("unexpected sent: %v", sent)
+			}
+			if len(dropped) != len(c.dropped) || (len(dropped) > 0 && dropped[0] != c.dropped[0]) {
+				t.Fatalf("unexpected dropped: %v, want %v", dropped, c.dropped)
+			}
+			msgs := popAll(q)
+			if len(msgs) != len(c.want) {
+				t.Fatalf("unexpected queue length: %d, want %d", len(msgs), len(c.want))
+			}
+			for i, msg := range msgs {
+				if msg.event.Cmd != c.want[i] {
+					t.Fatalf("unexpected msg %d: %s, want %s", i, msg.event.Cmd, c.want[i])
+				}
+			}
+			if c.policy == DropOldest && c.sent && msgs[len(msgs)-1] != c.push {
+				t.Fatal("pushed msg not at tail")
+			}
+		})
+	}
+	t.Run("block", func(t *testing.T) {
+		q := newEventQueue(1)
+		q.Push(damaku, DropBlock, nil)
+		done := make(chan bool)
+		go func() {
+			done <- q.Push(gift, DropBlock, func(*BLiveEventHandlerMsg) { t.Error("block policy dropped msg") })
+		}()
+		select {
+		case <-done:
+			t.Fatal("push not blocked on full queue")
+		case <-time.After(50 * time.Millisecond):
+		}
+		if msg, _ := q.Pop(); msg != damaku {
+			t.Fatal("unexpected head")
+		}
+		if !<-done {
+			t.Fatal("blocked msg not pushed")
+		}
+		select {
+		case <-q.Ready():
+		default:
+			t.Fatal("queue not ready after push")
+		}
+		if msg, _ := q.Pop(); msg != gift {
+			t.Fatal("unexpected msg")
+		}
+	})
+}
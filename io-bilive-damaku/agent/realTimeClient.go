@@ -10,12 +10,23 @@ import (
 	"k8s.io/klog/v2"
 	"sync"
 	"testing"
+	"time"
 )
 
 type config struct {
 	natsx.NatsConfig
-	AgentId       string `json:"agent_id" yaml:"agentId" env:"AGENT_ID,required"`
-	SubjectPrefix string `json:"subject_prefix" yaml:"subject_prefix" env:"SUBJECT_PREFIX,required" envDefault:"dmCenter"`
+	AgentId       string        `json:"agent_id" yaml:"agentId" env:"AGENT_ID,required"`
+	SubjectPrefix string        `json:"subject_prefix" yaml:"subject_prefix" env:"SUBJECT_PREFIX,required" envDefault:"dmCenter"`
+	JetStream     bool          `json:"jetstream" yaml:"jetstream" env:"JETSTREAM"`                              // publish stream msg to JetStream, controller must enable it too
+	DrainTimeout  time.Duration `json:"drain_timeout" yaml:"drain_timeout" env:"DRAIN_TIMEOUT" envDefault:"30s"` // wait rooms moved to other agents before exit
+	FlushTimeout  time.Duration `json:"flush_timeout" yaml:"flush_timeout" env:"FLUSH_TIMEOUT" envDefault:"10s"` // wait buffered events published before exit
+	MaxRooms      uint32        `json:"max_rooms" yaml:"max_rooms" env:"MAX_ROOMS"`                              // room capacity, 0 for unlimited
+	EventWorkers  int           `json:"event_workers" yaml:"event_workers" env:"EVENT_WORKERS" envDefault:"1"`   // events of the same room always go to the same worker
+	EventBuffer   int           `json:"event_buffer" yaml:"event_buffer" env:"EVENT_BUFFER" envDefault:"100"`    // raw events waiting for each eventHandler worker
+	MetaBuffer    int           `json:"meta_buffer" yaml:"meta_buffer" env:"META_BUFFER" envDefault:"32"`        // user and medal meta each, waiting for metaIndexer
+	DropPolicy    string        `json:"drop_policy" yaml:"drop_policy" env:"DROP_POLICY" envDefault:"block"`     // block, newest or oldest when buffer is full
+	// per msg type, bili msg cmd or userInfoMeta/fansMedal, e.g. DANMU_MSG:block,ONLINE_RANK_V2:oldest
+	DropPolicies map[string]string `json:"drop_policies" yaml:"drop_policies" env:"DROP_POLICIES"`
 }
 
 var (
@@ -31,11 +42,11 @@ func init() {
 	testing.Init()
 	klog.InitFlags(nil)
 	flag.Parse()
-	envx.MustLoadEnv(cfg)
-	traceHelper.SetupTrace()
 }
 
 func main() {
+	envx.MustLoadEnv(cfg)
+	traceHelper.SetupTrace()
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithCancel(context.Background())
 	if err := mq.Open(cfg.NatsConfig); err != nil {
@@ -51,7 +62,13 @@ func main() {
 }
 
 func waiter(cancel context.CancelFunc) {
-	utils.Wait4CtrlC()
+	select {
+	case <-utils.CtrlCChannel():
+		if client.started.Load() {
+			client.Drain(cfg.DrainTimeout)
+		}
+	case <-stopped: // shutdown by controller
+	}
 	worker.Done()
 	klog.Info("exiting...")
 	cancel()
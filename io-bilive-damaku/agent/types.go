@@ -28,3 +28,66 @@ type OnlineRankV2 struct {
 		RankType string `json:"rank_type"`
 	} `json:"data"`
 }
+
+type InteractWord struct {
+	Cmd  string `json:"cmd"`
+	Data struct {
+		UID         uint64 `json:"uid"`
+		UName       string `json:"uname"`
+		MsgType     int32  `json:"msg_type"`     // agent.Interact_InteractType
+		Timestamp   int64  `json:"timestamp"`    // second
+		TriggerTime int64  `json:"trigger_time"` // nanosecond
+		FansMedal   *struct {
+			TargetID   uint64 `json:"target_id"`
+			MedalName  string `json:"medal_name"`
+			MedalLevel uint32 `json:"medal_level"`
+			IsLighted  int    `json:"is_lighted"`
+			GuardLevel int32  `json:"guard_level"`
+		} `json:"fans_medal"`
+		UInfo struct {
+			Base struct {
+				Face string `json:"face"`
+			} `json:"base"`
+		} `json:"uinfo"`
+	} `json:"data"`
+}
+
+type LikeInfoV3Update struct {
+	Cmd  string `json:"cmd"`
+	Data struct {
+		ClickCount uint32 `json:"click_count"`
+	} `json:"data"`
+}
+
+type RoomChange struct {
+	Cmd  string `json:"cmd"`
+	Data struct {
+		Title          string `json:"title"`
+		AreaID         uint32 `json:"area_id"`
+		ParentAreaID   uint32 `json:"parent_area_id"`
+		AreaName       string `json:"area_name"`
+		ParentAreaName string `json:"parent_area_name"`
+	} `json:"data"`
+}
+
+// LiveStart is LIVE, sent twice at live start, live_time is only in one of them
+type LiveStart struct {
+	Cmd      string `json:"cmd"`
+	LiveKey  string `json:"live_key"`
+	LiveTime int64  `json:"live_time"` // second
+}
+
+type WatchedChange struct {
+	Cmd  string `json:"cmd"`
+	Data struct {
+		Num uint32 `json:"num"`
+	} `json:"data"`
+}
+
+type RoomRealTimeMessageUpdate struct {
+	Cmd  string `json:"cmd"`
+	Data struct {
+		Fans     uint32 `json:"fans"`
+		FansClub uint32 `json:"fans_club"`
+	} `json:"data"`
+}
@@ -1,17 +1,29 @@
 package main
 
 import (
+	"fmt"
 	"github.com/FishZe/go-bili-chat/v2/events"
 	"k8s.io/klog/v2"
 	"sync/atomic"
 	"time"
 )
 
+// DropPolicy decide what to do when a buffer is full
+type DropPolicy string
+
+const (
+	DropBlock  DropPolicy = "block"  // wait for a free slot, the sender is blocked
+	DropNewest DropPolicy = "newest" // drop the msg being sent
+	DropOldest DropPolicy = "oldest" // evict the oldest msg of the same type
+)
+
 // BLiveEventHandlerWrapper wrap handler to chan
 type BLiveEventHandlerWrapper struct {
-	Command   string `json:"cmd"`
-	EventChan chan<- *BLiveEventHandlerMsg
+	Command   string        `json:"cmd"`
+	EventChan []*eventQueue // workerId:events
 	Counter   *atomic.Int32
+	Policy    DropPolicy
+	OnDrop    func(msg *BLiveEventHandlerMsg) // for dropped or evicted msg
 }
 
 func (h *BLiveEventHandlerWrapper) Cmd() string {
@@ -24,9 +36,41 @@ func (h *BLiveEventHandlerWrapper) On(event *events.BLiveEvent) {
 	if h.Counter != nil {
 		h.Counter.Add(1)
 	}
-	h.EventChan <- &BLiveEventHandlerMsg{
+	// keep events of a room in order by a single worker
+	shard := h.EventChan[uint64(event.RoomId)%uint64(len(h.EventChan))]
+	shard.Push(&BLiveEventHandlerMsg{
 		event:     event,
 		startTime: time.Now(),
+	}, h.Policy, h.OnDrop)
+}
+
+// sendWithPolicy send msg to ch of a single msg type, return false if msg itself is dropped, onDrop is called for every dropped msg
+func sendWithPolicy[T any](ch chan T, msg T, policy DropPolicy, onDrop func(T)) bool {
+	switch policy {
+	case DropNewest:
+		select {
+		case ch <- msg:
+			return true
+		default:
+			onDrop(msg)
+			return false
+		}
+	case DropOldest:
+		for {
+			select {
+			case ch <- msg:
+				return true
+			default:
+			}
+			select {
+			case old := <-ch:
+				onDrop(old)
+			default: // taken by receiver meanwhile
+			}
+		}
+	default:
+		ch <- msg
+		return true
 	}
 }
 
@@ -35,3 +79,14 @@ type BLiveEventHandlerMsg struct {
 	startTime   time.Time
 	processTime time.Time
 }
+
+// publishStream publish msg to stream subject,
+// with JetStream the msg is persisted until controller acked it
+func publishStream(streamType string, data []byte) error {
+	subject := fmt.Sprintf("%s.stream.%s", cfg.SubjectPrefix, streamType)
+	if cfg.JetStream {
+		_, err := mq.Js.Publish(subject, data)
+		return err
+	}
+	return mq.Publish(subject, data)
+}
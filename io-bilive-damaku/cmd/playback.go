@@ -0,0 +1,339 @@
+package main
+
+import (
+	"cmp"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/FishZe/go-bili-chat/v2/events"
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/TiyaAnlite/FocotServicesCommon/natsx"
+	"github.com/bytedance/sonic"
+	"github.com/nats-io/nats.go"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+var PlaybackApp = &PlaybackCommand{}
+
+// msg types that recorded files contain, bili msg cmd:stream type
+var playbackMsgTypes = map[string]string{
+	events.CmdDanmuMsg:         "damaku",
+	events.CmdSendGift:         "gift",
+	events.CmdGuardBuy:         "guard",
+	events.CmdSuperChatMessage: "superChat",
+}
+
+type PlaybackCommand struct {
+	mq       *natsx.NatsHelper
+	prefix   string
+	agentId  string
+	js       bool
+	msgTypes []string // asked by controller, all types if empty
+}
+
+type playbackEvent struct {
+	ts         uint64 // MilliTimestamp for scheduling
+	streamType string
+	msg        proto.Message
+}
+
+func (p *PlaybackCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "playback",
+		Usage: "replay recorded damaku files to controller as a playback agent",
+		Description: "Reading BililiveRecorder raw xml or washed json(.gz) files, events of all files are merged by time,\n" +
+			"the agent registers to controller like a real-time agent, but never watches live rooms",
+		HideHelpCommand: true,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Usage:    "files to be replayed, .xml, .json or .json.gz",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "nats",
+				Usage:   "NATS url",
+				Value:   "127.0.0.1",
+				EnvVars: []string{"NATS_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "nkey",
+				Usage:   "NATS nkey seed",
+				EnvVars: []string{"NATS_NKEY"},
+			},
+			&cli.StringFlag{
+				Name:    "prefix",
+				Usage:   "subject prefix, same as controller",
+				Value:   "dmCenter",
+				EnvVars: []string{"SUBJECT_PREFIX"},
+			},
+			&cli.StringFlag{
+				Name:    "agent-id",
+				Usage:   "agent id registered to controller",
+				Value:   "playback",
+				EnvVars: []string{"AGENT_ID"},
+			},
+			&cli.Float64Flag{
+				Name:    "speed",
+				Aliases: []string{"s"},
+				Usage:   "1 for real-time, 10 for 10x accelerated, 0 for as fast as possible, which requires --jetstream",
+				Value:   1,
+			},
+			&cli.Uint64Flag{
+				Name:  "room",
+				Usage: "override room id of all files",
+			},
+			&cli.BoolFlag{
+				Name:    "jetstream",
+				Usage:   "publish stream msg to JetStream, controller must enable it too",
+				EnvVars: []string{"JETSTREAM"},
+			},
+		},
+		Action: p.action,
+	}
+}
+
+func (p *PlaybackCommand) action(c *cli.Context) error {
+	if c.Float64("speed") < 0 {
+		return errors.New("speed must not be negative")
+	}
+	if c.Float64("speed") == 0 && !c.Bool("jetstream") {
+		// core NATS drops msgs of slow consumer, controller can not keep up with unpaced playback
+		return errors.New("speed 0 requires --jetstream")
+	}
+	p.prefix = c.String("prefix")
+	p.agentId = c.String("agent-id")
+	p.js = c.Bool("jetstream")
+	var queue []*playbackEvent
+	var users []*agent.UserInfoMeta
+	var medals []*agent.FansMedalMeta
+	for _, f := range c.StringSlice("file") {
+		klog.Infof("loading file: %s", f)
+		data, err := p.load(f)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %s", f, err.Error())
+		}
+		room := uint64(data.Meta.RoomID)
+		if c.Uint64("room") != 0 {
+			room = c.Uint64("room")
+		}
+		if room == 0 {
+			return fmt.Errorf("room id of %s unknown, set it by --room", f)
+		}
+		queue = append(queue, p.events(data, room)...)
+		users = append(users, data.User...)
+		medals = append(medals, data.FansMedal...)
+	}
+	sortEvents(queue)
+	klog.Infof("%d events, %d users, %d fans medals loaded", len(queue), len(users), len(medals))
+
+	p.mq = &natsx.NatsHelper{}
+	if err := p.mq.Open(natsx.NatsConfig{NatsUrl: c.String("nats"), NatsName: p.agentId, NatsNkey: c.String("nkey")}); err != nil {
+		return fmt.Errorf("cannot connect to NATS: %s", err.Error())
+	}
+	defer p.mq.Close()
+	if err := p.register(c); err != nil {
+		return err
+	}
+
+	// meta first, so that controller knows users before their events
+	for _, user := range users {
+		p.publish("userInfoMeta", user)
+	}
+	for _, medal := range medals {
+		p.publish("fansMedal", medal)
+	}
+	p.replay(c, queue, c.Float64("speed"))
+	if err := p.mq.Nc.Flush(); err != nil {
+		return fmt.Errorf("failed to flush: %s", err.Error())
+	}
+	klog.Info("playback finished")
+	return nil
+}
+
+// load raw xml or washed json
+func (p *PlaybackCommand) load(filename string) (*BliveData, error) {
+	if strings.HasSuffix(filename, ".xml") {
+		return WashApp.parse(filename)
+	}
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	var r io.Reader = fp
+	if strings.HasSuffix(filename, ".gz") {
+		gr, err := gzip.NewReader(fp)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	data := &BliveData{}
+	if err := sonic.ConfigDefault.NewDecoder(r).Decode(data); err != nil {
+		return nil, fmt.Errorf("decode json error: %s", err.Error())
+	}
+	return data, nil
+}
+
+// events of file with meta of this agent
+func (p *PlaybackCommand) events(data *BliveData, room uint64) []*playbackEvent {
+	var result []*playbackEvent
+	add := func(streamType string, meta *agent.BasicMsgMeta, msg proto.Message) {
+		meta.Version = agent.VERSION
+		meta.Agent = p.agentId
+		meta.RoomID = &room
+		result = append(result, &playbackEvent{ts: millis(meta.TimeStamp), streamType: streamType, msg: msg})
+	}
+	for _, d := range data.Damaku {
+		add("damaku", d.Meta, d)
+	}
+	for _, g := range data.Gift {
+		add("gift", g.Meta, g)
+	}
+	for _, g := range data.Guard {
+		add("guard", g.Meta, g)
+	}
+	for _, sc := range data.SuperChat {
+		add("superChat", sc.Meta, sc)
+	}
+	return result
+}
+
+// sortEvents merge events of all files by time, events at the same time keep their order
+func sortEvents(queue []*playbackEvent) {
+	slices.SortStableFunc(queue, func(a, b *playbackEvent) int {
+		return cmp.Compare(a.ts, b.ts)
+	})
+}
+
+// millis normalize timestamp for scheduling only, SuperChat ts is recorded in seconds
+func millis(ts uint64) uint64 {
+	if ts < 1e12 {
+		return ts * 1000
+	}
+	return ts
+}
+
+// register to controller as playback agent and wait for init
+func (p *PlaybackCommand) register(c *cli.Context) error {
+	var msgTypes []string
+	for msgType := range playbackMsgTypes {
+		msgTypes = append(msgTypes, msgType)
+	}
+	slices.Sort(msgTypes)
+	registerData, err := proto.Marshal(&agent.AgentInfo{
+		ID:       p.agentId,
+		Type:     agent.AgentInfo_PlaybackAgent,
+		Version:  agent.VERSION,
+		MsgTypes: msgTypes,
+		Build:    agent.BuildVersion(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal register packet failed: %s", err.Error())
+	}
+	initChan := make(chan *nats.Msg, 1)
+	initSub, err := p.mq.Nc.ChanSubscribe(fmt.Sprintf("%s.agent.%s.init", p.prefix, p.agentId), initChan)
+	if err != nil {
+		return fmt.Errorf("subscribe init subject failed: %s", err.Error())
+	}
+	defer initSub.Unsubscribe()
+	// live rooms are never placed to playback agent, actions are refused
+	actionSub, err := p.mq.Nc.Subscribe(fmt.Sprintf("%s.agent.%s.action", p.prefix, p.agentId), func(msg *nats.Msg) {
+		_ = agent.ControlError(msg, errors.New("action not supported by playback agent"))
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe action subject failed: %s", err.Error())
+	}
+	p.mq.AddSubscribe(actionSub)
+	klog.Infof("registering playback agent: %s", p.agentId)
+	ticker := time.NewTicker(time.Second * 3)
+	defer ticker.Stop()
+	for {
+		if err := p.mq.Publish(fmt.Sprintf("%s.agent.info", p.prefix), registerData); err != nil {
+			klog.Errorf("publish register msg failed: %s", err.Error())
+		}
+		select {
+		case msg := <-initChan:
+			initMsg := &agent.AgentInit{}
+			if err := proto.Unmarshal(msg.Data, initMsg); err != nil {
+				klog.Errorf("unmarshal register msg failed: %s", err.Error())
+				continue
+			}
+			p.msgTypes = initMsg.MsgTypes
+			if err := agent.ControlSuccess(msg); err != nil {
+				klog.Errorf("response control msg failed: %s", err.Error())
+			}
+			klog.Infof("agent initialized, msg types: %v", p.msgTypes)
+			return nil
+		case <-ticker.C:
+		case <-c.Done():
+			return c.Err()
+		}
+	}
+}
+
+// replay events by their time, scaled by speed, as fast as possible if speed is 0
+func (p *PlaybackCommand) replay(c *cli.Context, queue []*playbackEvent, speed float64) {
+	if len(queue) == 0 {
+		return
+	}
+	var wanted []string // stream types asked by controller
+	for _, msgType := range p.msgTypes {
+		wanted = append(wanted, playbackMsgTypes[msgType])
+	}
+	start := time.Now()
+	first := queue[0].ts
+	lastReport := start
+	published := 0
+	for i, e := range queue {
+		if len(wanted) > 0 && !slices.Contains(wanted, e.streamType) {
+			continue
+		}
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(time.Duration(e.ts-first)*time.Millisecond) / speed))
+			if wait := time.Until(at); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-c.Done():
+					klog.Warningf("playback interrupted, %d/%d events published", published, len(queue))
+					return
+				}
+			}
+		}
+		p.publish(e.streamType, e.msg)
+		published++
+		if time.Since(lastReport) > time.Second*10 {
+			lastReport = time.Now()
+			klog.Infof("playback progress: %d/%d, at %s", i+1, len(queue), time.UnixMilli(int64(e.ts)).Format(time.DateTime))
+		}
+	}
+	klog.Infof("%d events published in %s", published, time.Since(start))
+}
+
+func (p *PlaybackCommand) publish(streamType string, msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		klog.Errorf("marshal %s failed: %s", streamType, err.Error())
+		return
+	}
+	subject := fmt.Sprintf("%s.stream.%s", p.prefix, streamType)
+	if p.js {
+		_, err = p.mq.Js.Publish(subject, data)
+	} else {
+		err = p.mq.Publish(subject, data)
+	}
+	if err != nil {
+		klog.Errorf("publish %s failed: %s", streamType, err.Error())
+	}
+}
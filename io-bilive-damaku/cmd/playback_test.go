@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+)
+
+func TestMillis(t *testing.T) {
+	ts := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name string
+		ts   uint64
+		want uint64
+	}{
+		{"millis", uint64(ts.UnixMilli()), uint64(ts.UnixMilli())},
+		{"seconds", uint64(ts.Unix()), uint64(ts.UnixMilli())},
+		{"zero", 0, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := millis(c.ts); got != c.want {
+				t.Fatalf("unexpected millis: %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPlaybackEvents(t *testing.T) {
+	p := &PlaybackCommand{agentId: "playback"}
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) uint64 { return uint64(start.Add(d).UnixMilli()) }
+	data := &BliveData{
+		Damaku: []*agent.Damaku{
+			{Meta: &agent.BasicMsgMeta{TimeStamp: at(3 * time.Second)}},
+			{Meta: &agent.BasicMsgMeta{TimeStamp: at(time.Second)}},
+		},
+		Gift: []*agent.Gift{{Meta: &agent.BasicMsgMeta{TimeStamp: at(2 * time.Second)}}},
+		// SuperChat recorded in seconds
+		SuperChat: []*agent.SuperChat{{Meta: &agent.BasicMsgMeta{TimeStamp: uint64(start.Add(2 * time.Second).Unix())}}},
+	}
+	other := &BliveData{Guard: []*agent.Guard{{Meta: &agent.BasicMsgMeta{TimeStamp: at(0)}}}}
+	queue := append(p.events(data, 1), p.events(other, 2)...)
+	sortEvents(queue)
+	want := []string{"guard", "damaku", "gift", "superChat", "damaku"}
+	if len(queue) != len(want) {
+		t.Fatalf("unexpected events: %d, want %d", len(queue), len(want))
+	}
+	for i, e := range queue {
+		if e.streamType != want[i] {
+			t.Fatalf("unexpected event %d: %s, want %s", i, e.streamType, want[i])
+		}
+		if i > 0 && e.ts < queue[i-1].ts {
+			t.Fatalf("event %d out of order", i)
+		}
+	}
+	meta := queue[1].msg.(*agent.Damaku).Meta
+	if meta.Agent != "playback" || meta.Version != agent.VERSION || meta.GetRoomID() != 1 {
+		t.Fatalf("unexpected meta: %+v", meta)
+	}
+	if queue[0].msg.(*agent.Guard).Meta.GetRoomID() != 2 {
+		t.Fatal("unexpected room of the other file")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"compress/gzip"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -124,16 +125,44 @@ func (w *WashCommand) outputFileName(sourceNameWithExt string) string {
 
 func (w *WashCommand) washer(filename string, compress bool) {
 	klog.Infof("processing file: %s", filename)
-	srcFp, err := os.Open(filename)
+	data, err := w.parse(filename)
 	if err != nil {
-		klog.Errorf("open file error: %s", err.Error())
+		klog.Errorf("parse file error: %s", err.Error())
 		return
 	}
+
+	var rw io.WriteCloser
+	if compress {
+		dstFp, err := os.Create(w.outputFileName(filename) + ".json.gz")
+		if err != nil {
+			klog.Errorf("create file error: %s", err.Error())
+		}
+		defer dstFp.Close()
+		rw = gzip.NewWriter(dstFp)
+	} else {
+		rw, err = os.Create(w.outputFileName(filename) + ".json")
+		if err != nil {
+			klog.Errorf("create file error: %s", err.Error())
+		}
+	}
+	defer rw.Close()
+	err = sonic.ConfigDefault.NewEncoder(rw).Encode(data)
+	if err != nil {
+		klog.Errorf("encode json error: %s", err.Error())
+	}
+}
+
+// parse BililiveRecorder raw xml to structured data
+func (w *WashCommand) parse(filename string) (*BliveData, error) {
+	srcFp, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
 	defer srcFp.Close()
 	srcBuf := bufio.NewReader(srcFp)
 
 	decoder := xml.NewDecoder(srcBuf)
-	var data BliveData
+	data := &BliveData{}
 	data.mapUser = make(map[uint64]*agent.UserInfoMeta)
 	data.mapMedal = make(map[uint64]map[uint64]*agent.FansMedalMeta)
 	for {
@@ -142,10 +171,9 @@ func (w *WashCommand) washer(filename string, compress bool) {
 			break
 		}
 		if err != nil {
-			klog.Errorf("decode xml error: %s", err.Error())
-			return
+			return nil, fmt.Errorf("decode xml error: %s", err.Error())
 		}
-		w.attrParse(&data, token)
+		w.attrParse(data, token)
 	}
 	// final map listing
 	for _, u := range data.mapUser {
@@ -156,26 +184,7 @@ func (w *WashCommand) washer(filename string, compress bool) {
 			data.FansMedal = append(data.FansMedal, rm)
 		}
 	}
-
-	var rw io.WriteCloser
-	if compress {
-		dstFp, err := os.Create(w.outputFileName(filename) + ".json.gz")
-		if err != nil {
-			klog.Errorf("create file error: %s", err.Error())
-		}
-		defer dstFp.Close()
-		rw = gzip.NewWriter(dstFp)
-	} else {
-		rw, err = os.Create(w.outputFileName(filename) + ".json")
-		if err != nil {
-			klog.Errorf("create file error: %s", err.Error())
-		}
-	}
-	defer rw.Close()
-	err = sonic.ConfigDefault.NewEncoder(rw).Encode(data)
-	if err != nil {
-		klog.Errorf("encode json error: %s", err.Error())
-	}
+	return data, nil
 }
 
 func (w *WashCommand) attrParse(data *BliveData, token xml.Token) {
@@ -3,6 +3,9 @@ package main
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/search"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
@@ -14,23 +17,192 @@ type Config struct {
 		UA      *string           `json:"ua" yaml:"ua"`
 		Headers map[string]string `json:"headers" yaml:"headers"`
 	} `json:"global" yaml:"global"` // Global account config
-	Provider   []*RoomProviderConfig `json:"provider" yaml:"provider"`
-	Controller ControllerConfig      `json:"controller" yaml:"controller"`
+	Credentials map[string]*CredentialConfig `json:"credentials" yaml:"credentials"` // name:credential profile for rooms
+	Provider    []*RoomProviderConfig        `json:"provider" yaml:"provider"`
+	Controller  ControllerConfig             `json:"controller" yaml:"controller"`
+	Storage     StorageConfig                `json:"storage" yaml:"storage"`
+	WebSocket   WebSocketConfig              `json:"websocket" yaml:"websocket"`
+	Admin       AdminConfig                  `json:"admin" yaml:"admin"`
+	Search      search.Config                `json:"search" yaml:"search"`
 }
 
 type RoomProviderConfig struct {
 	Type string `json:"type" yaml:"type"`
 	json.RawMessage
+	node *yaml.Node
+}
+
+// UnmarshalYAML keep the whole node, provider config will be decoded by its type later
+func (c *RoomProviderConfig) UnmarshalYAML(value *yaml.Node) error {
+	var t struct {
+		Type string `yaml:"type"`
+	}
+	if err := value.Decode(&t); err != nil {
+		return err
+	}
+	c.Type = t.Type
+	c.node = value
+	return nil
+}
+
+// Decode provider config into provider, from yaml node or raw json
+func (c *RoomProviderConfig) Decode(provider RoomProvider) error {
+	if c.node != nil {
+		return c.node.Decode(provider)
+	}
+	return json.Unmarshal(c.RawMessage, provider)
+}
+
+// AdminConfig of admin api, JWT of echox is used instead if JWT_SECRET is set
+type AdminConfig struct {
+	Token string `json:"token" yaml:"token"` // bearer token for mutating admin api, disabled if empty
+}
+
+// CredentialConfig is an account profile that provider can attach to rooms
+type CredentialConfig struct {
+	BUVID   string            `json:"buvid" yaml:"BUVID"`
+	UID     uint64            `json:"uid" yaml:"UID"`
+	Cookie  string            `json:"cookie" yaml:"Cookie"`
+	UA      *string           `json:"ua" yaml:"ua"`
+	Headers map[string]string `json:"headers" yaml:"headers"`
 }
 
 type ControllerConfig struct {
-	DuplicateWindow time.Duration `json:"duplicate_window" yaml:"duplicate_window"`
+	DuplicateWindow   time.Duration   `json:"duplicate_window" yaml:"duplicate_window"`
+	AggregateWorkers  int             `json:"aggregate_workers" yaml:"aggregate_workers"`   // msg of the same room always go to the same worker
+	StreamBuffer      int             `json:"stream_buffer" yaml:"stream_buffer"`           // for stream subscription and each worker
+	EventBuffer       int             `json:"event_buffer" yaml:"event_buffer"`             // for processor and recycler
+	ReplicationFactor int             `json:"replication_factor" yaml:"replication_factor"` // agents watching a room, 0 means every agent watch every room
+	RebalanceMoves    int             `json:"rebalance_moves" yaml:"rebalance_moves"`       // max rooms moved per sync
+	MsgTypes          []string        `json:"msg_types" yaml:"msg_types"`                   // bili msg cmd asked from agents, all types agent supported if empty
+	Dedup             DedupConfig     `json:"dedup" yaml:"dedup"`
+	Republish         RepublishConfig `json:"republish" yaml:"republish"`
+	RoomStats         RoomStatsConfig `json:"room_stats" yaml:"room_stats"`
+	Session           SessionConfig   `json:"session" yaml:"session"`
+	JetStream         JetStreamConfig `json:"jetstream" yaml:"jetstream"`
+	Leader            LeaderConfig    `json:"leader" yaml:"leader"`
+	ShutdownTimeout   time.Duration   `json:"shutdown_timeout" yaml:"shutdown_timeout"` // wait for pending data flushed before exit
+	DrainTimeout      time.Duration   `json:"drain_timeout" yaml:"drain_timeout"`       // wait for rooms moved off before shutting down an agent
+}
+
+type DedupConfig struct {
+	Store      string                   `json:"store" yaml:"store"`           // memory or redis, redis is shared by controller replicas
+	Strategies map[string]DedupStrategy `json:"strategies" yaml:"strategies"` // event type:strategy, DefaultDedupStrategies if not set
+}
+
+// RepublishConfig publish deduplicated events to [prefix].[Prefix].[room].[type]
+type RepublishConfig struct {
+	Enable bool   `json:"enable" yaml:"enable"`
+	Prefix string `json:"prefix" yaml:"prefix"`
+}
+
+// RoomStatsConfig per room stats in tumbling windows, published to [prefix].stats.[window].[room]
+type RoomStatsConfig struct {
+	Enable   bool            `json:"enable" yaml:"enable"`
+	Windows  []time.Duration `json:"windows" yaml:"windows"`
+	Lateness time.Duration   `json:"lateness" yaml:"lateness"` // window closed after its end plus lateness
+}
+
+// SessionConfig live sessions of rooms from LIVE to PREPARING, stored at bilive_live_session
+type SessionConfig struct {
+	Enable        bool          `json:"enable" yaml:"enable"`
+	Timeout       time.Duration `json:"timeout" yaml:"timeout"` // session ended if no event of room within it
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// WebSocketConfig of live fan-out of processed events
+type WebSocketConfig struct {
+	Enable        bool          `json:"enable" yaml:"enable"`
+	Path          string        `json:"path" yaml:"path"`
+	MaxClients    int           `json:"max_clients" yaml:"max_clients"`     // 0 for unlimited
+	ClientBuffer  int           `json:"client_buffer" yaml:"client_buffer"` // events dropped for client if its buffer is full
+	BatchSize     int           `json:"batch_size" yaml:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+	WriteTimeout  time.Duration `json:"write_timeout" yaml:"write_timeout"` // client disconnected if write timeout
+	Token         string        `json:"token" yaml:"token"`                 // query token or bearer token of clients, no auth if empty
+}
+
+// LeaderConfig enable it for running multiple controller replicas
+type LeaderConfig struct {
+	Enable   bool          `json:"enable" yaml:"enable"`
+	LeaseTTL time.Duration `json:"lease_ttl" yaml:"lease_ttl"` // leader renew the lease every 1/3 ttl
+}
+
+// JetStreamConfig must be enabled together with JETSTREAM of agents
+type JetStreamConfig struct {
+	Enable        bool          `json:"enable" yaml:"enable"`
+	Stream        string        `json:"stream" yaml:"stream"` // default: [prefix]_stream
+	Durable       string        `json:"durable" yaml:"durable"`
+	MaxAge        time.Duration `json:"max_age" yaml:"max_age"`
+	AckWait       time.Duration `json:"ack_wait" yaml:"ack_wait"`
+	MaxAckPending int           `json:"max_ack_pending" yaml:"max_ack_pending"`
+	MaxDeliver    int           `json:"max_deliver" yaml:"max_deliver"` // msg of unknown agent will be redelivered until max deliver
+}
+
+type StorageConfig struct {
+	Migrate       bool          `json:"migrate" yaml:"migrate"` // create tables and hypertables at startup
+	BatchSize     int           `json:"batch_size" yaml:"batch_size"`
+	Buffer        int           `json:"buffer" yaml:"buffer"` // records waiting for writer, processor is blocked if full
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+	ChunkInterval time.Duration `json:"chunk_interval" yaml:"chunk_interval"` // hypertable chunk_time_interval
 }
 
 func NewConfig() *Config {
 	return &Config{
 		Controller: ControllerConfig{
-			DuplicateWindow: time.Minute * 10,
+			DuplicateWindow:  time.Minute * 10,
+			AggregateWorkers: 4,
+			StreamBuffer:     100,
+			EventBuffer:      200,
+			ShutdownTimeout:  time.Second * 30,
+			DrainTimeout:     time.Minute,
+			JetStream: JetStreamConfig{
+				Durable:       "controller",
+				MaxAge:        time.Hour * 24,
+				AckWait:       time.Second * 30,
+				MaxAckPending: 1000,
+				MaxDeliver:    10,
+			},
+			Leader: LeaderConfig{
+				LeaseTTL: time.Second * 10,
+			},
+			RebalanceMoves: 4,
+			Dedup: DedupConfig{
+				Store: "memory",
+			},
+			Republish: RepublishConfig{
+				Prefix: "clean",
+			},
+			RoomStats: RoomStatsConfig{
+				Windows:  []time.Duration{time.Minute, time.Minute * 5, time.Hour},
+				Lateness: time.Second * 5,
+			},
+			Session: SessionConfig{
+				Timeout:       time.Minute * 30,
+				FlushInterval: time.Second * 10,
+			},
+		},
+		Storage: StorageConfig{
+			BatchSize:     500,
+			Buffer:        2000,
+			FlushInterval: time.Second * 5,
+			ChunkInterval: time.Hour * 24,
+		},
+		Search: search.Config{
+			Index:         "damaku",
+			BatchSize:     200,
+			FlushInterval: time.Second * 2,
+			Buffer:        2000,
+			MaxRetries:    5,
+			RetryBackoff:  time.Millisecond * 500,
+			Timeout:       time.Second * 10,
+		},
+		WebSocket: WebSocketConfig{
+			Path:          "/ws",
+			ClientBuffer:  1024,
+			BatchSize:     64,
+			FlushInterval: time.Millisecond * 200,
+			WriteTimeout:  time.Second * 5,
 		},
 	}
 }
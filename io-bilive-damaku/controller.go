@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
@@ -18,19 +19,29 @@ import (
 
 type DamakuController struct {
 	agent       *AgentManager
+	leader      *LeaderElector
 	processor   *MessageProcessor
 	storage     *StorageController
 	metrics     *MetricsService
+	hub         *WebSocketHub
+	search      *SearchIndexer
+	roomStats   *RoomStatsAggregator
+	sessions    *SessionTracker
 	centerCtx   *CenterContext
 	providers   []RoomProvider
 	streamChan  chan *nats.Msg
+	shardChan   []chan *nats.Msg   // aggregate window workerId:stream msg
+	streamSub   *nats.Subscription // only subscribed by leader
+	streamMu    sync.Mutex
 	eventChan   chan any
 	recycleChan chan any
+	aggregating sync.WaitGroup // aggregate window workers, senders of eventChan
 
 	// cache
-	dupCache       *bigcache.BigCache // [msgType]:[msgUniqueKey]
-	userMetaCache  *bigcache.BigCache // UserInfoMeta: uid
-	medalMetaCache *bigcache.BigCache // FansMedalMeta: user:rid
+	dedup          DedupStore               // [msgType]:[msgUniqueKey]
+	dedupStrategy  map[string]DedupStrategy // msgType:strategy
+	userMetaCache  *bigcache.BigCache       // UserInfoMeta: uid
+	medalMetaCache *bigcache.BigCache       // FansMedalMeta: user:rid
 
 	// pool
 	fansMedalPool    *sync.Pool
@@ -41,30 +52,49 @@ type DamakuController struct {
 	superChatPool    *sync.Pool
 	onlinePool       *sync.Pool
 	onlineV2Pool     *sync.Pool
+	interactPool     *sync.Pool
+	likePool         *sync.Pool
+	roomChangePool   *sync.Pool
+	liveStatusPool   *sync.Pool
+	watchedPool      *sync.Pool
+	fansPool         *sync.Pool
+
+	// running flag
+	started atomic.Bool
 }
 
 func (c *DamakuController) Init(ctx *CenterContext, providers []RoomProvider) error {
 	var err error
 	c.agent = &AgentManager{}
+	c.leader = &LeaderElector{}
 	c.processor = &MessageProcessor{}
 	c.storage = &StorageController{}
 	c.metrics = &MetricsService{}
 	c.centerCtx = ctx
 	c.providers = providers
-	c.streamChan = make(chan *nats.Msg, 100)
-	c.eventChan = make(chan any, 200)
-	c.recycleChan = make(chan any, 200)
+	c.streamChan = make(chan *nats.Msg, ctx.Config.Controller.StreamBuffer)
+	c.shardChan = make([]chan *nats.Msg, max(ctx.Config.Controller.AggregateWorkers, 1))
+	for i := range c.shardChan {
+		c.shardChan[i] = make(chan *nats.Msg, ctx.Config.Controller.StreamBuffer)
+	}
+	c.eventChan = make(chan any, ctx.Config.Controller.EventBuffer)
+	c.recycleChan = make(chan any, ctx.Config.Controller.EventBuffer)
 
 	// cache init
-	c.dupCache, err = bigcache.New(ctx.Context, bigcache.Config{
-		Shards:      1024,
-		LifeWindow:  ctx.Config.Controller.DuplicateWindow,
-		CleanWindow: time.Minute,
-		OnRemove:    c.dupCacheRelease,
-		Logger:      klog.NewStandardLogger("INFO"),
-	})
+	c.dedupStrategy, err = dedupStrategies(ctx.Config.Controller.Dedup.Strategies)
 	if err != nil {
-		return fmt.Errorf("failed to init dupCache: %s", err.Error())
+		return fmt.Errorf("failed to load dedup strategies: %s", err.Error())
+	}
+	switch ctx.Config.Controller.Dedup.Store {
+	case "", "memory":
+		c.dedup = &MemoryDedupStore{}
+	case "redis":
+		c.dedup = &RedisDedupStore{}
+	default:
+		return fmt.Errorf("unknown dedup store: %s", ctx.Config.Controller.Dedup.Store)
+	}
+	if err := c.dedup.Init(ctx, c.dupCacheRelease); err != nil {
+		return fmt.Errorf("failed to init dedup store: %s", err.Error())
 	}
 	c.userMetaCache, err = bigcache.New(ctx.Context, bigcache.Config{
 		Shards:           1024,
@@ -98,263 +128,385 @@ func (c *DamakuController) Init(ctx *CenterContext, providers []RoomProvider) er
 	c.superChatPool = &sync.Pool{New: func() interface{} { return &agent.SuperChat{} }}
 	c.onlinePool = &sync.Pool{New: func() interface{} { return &agent.OnlineRankCount{} }}
 	c.onlineV2Pool = &sync.Pool{New: func() interface{} { return &agent.OnlineRankV2{} }}
+	c.interactPool = &sync.Pool{New: func() interface{} { return &agent.Interact{} }}
+	c.likePool = &sync.Pool{New: func() interface{} { return &agent.LikeCount{} }}
+	c.roomChangePool = &sync.Pool{New: func() interface{} { return &agent.RoomChange{} }}
+	c.liveStatusPool = &sync.Pool{New: func() interface{} { return &agent.LiveStatus{} }}
+	c.watchedPool = &sync.Pool{New: func() interface{} { return &agent.WatchedCount{} }}
+	c.fansPool = &sync.Pool{New: func() interface{} { return &agent.FansCount{} }}
 
-	if err := c.agent.Init(c.centerCtx); err != nil {
+	c.leader.Init(c.centerCtx)
+	if err := c.agent.Init(c.centerCtx, c.leader); err != nil {
 		return fmt.Errorf("failed to init agent manager: %s", err.Error())
 	}
+	c.metrics.Init(c.centerCtx, c.agent)
+	c.processor.Init(c.centerCtx, c.eventChan, c.recycleChan, &c.aggregating)
+	if err := c.storage.Init(c.centerCtx, c.metrics, c.processor.Stopped()); err != nil {
+		return fmt.Errorf("failed to init storage: %s", err.Error())
+	}
+	c.metrics.WatchChannel("stream", func() (int, int) { return len(c.streamChan), cap(c.streamChan) })
+	for i, shard := range c.shardChan {
+		c.metrics.WatchChannel(fmt.Sprintf("shard-%d", i), func() (int, int) { return len(shard), cap(shard) })
+	}
+	c.metrics.WatchChannel("event", func() (int, int) { return len(c.eventChan), cap(c.eventChan) })
+	c.metrics.WatchChannel("recycle", func() (int, int) { return len(c.recycleChan), cap(c.recycleChan) })
+	if ctx.Config.Controller.Session.Enable {
+		c.sessions = &SessionTracker{}
+		c.sessions.Init(c.centerCtx, c.leader)
+		c.processor.Register("session", StageEnricher, c.sessions, StreamEventTypes...)
+	}
+	c.processor.Register("metrics", StageSink, c.metrics)
+	c.processor.Register("storage", StageSink, c.storage)
+	if ctx.Config.Controller.Republish.Enable {
+		republisher := &Republisher{}
+		republisher.Init(c.centerCtx)
+		c.processor.Register("republish", StageSink, republisher)
+	}
+	if ctx.Config.Controller.RoomStats.Enable {
+		c.roomStats = &RoomStatsAggregator{}
+		if err := c.roomStats.Init(c.centerCtx); err != nil {
+			return fmt.Errorf("failed to init room stats: %s", err.Error())
+		}
+		c.processor.Register("roomStats", StageSink, c.roomStats, EventDamaku, EventGift, EventGuard, EventSuperChat)
+	}
+	if ctx.Config.Search.Enable {
+		c.search = &SearchIndexer{}
+		c.search.Init(c.centerCtx, c.userMetaCache)
+		c.processor.Register("search", StageSink, c.search, EventDamaku, EventSuperChat)
+	}
+	if ctx.Config.WebSocket.Enable {
+		c.hub = &WebSocketHub{}
+		c.hub.Init(c.centerCtx)
+		c.processor.Register("websocket", StageSink, c.hub)
+	}
 	chanProvide, chanRevoke := c.agent.GetRoomChan()
 	for _, provider := range providers {
 		provider.Provide(chanProvide)
 		provider.Revoke(chanRevoke)
 	}
-	sub, err := c.centerCtx.MQ.Nc.ChanSubscribe(fmt.Sprintf("%s.stream.*", c.centerCtx.Config.Global.Prefix), c.streamChan)
-	if err != nil {
-		return fmt.Errorf("failed to subscribe stream msg: %s", err.Error())
+	if err := c.setupStream(); err != nil {
+		return fmt.Errorf("failed to setup stream: %s", err.Error())
 	}
-	c.centerCtx.MQ.AddSubscribe(sub)
+	c.leader.OnChanged(c.followStream)
+	c.agent.OnStreamGap(func(gap *agent.StreamGap) { c.pushEvent(gap) })
 	return nil
 }
 
 func (c *DamakuController) Start() {
 	klog.Infof("starting damaku controller")
-	c.centerCtx.Worker.Go(func() {
-		c.aggregateWindow(0)
-	})
+	c.agent.Start()
+	c.storage.Start()
+	c.metrics.Start()
+	if c.search != nil {
+		c.search.Start()
+	}
+	if c.roomStats != nil {
+		c.roomStats.Start()
+	}
+	if c.sessions != nil {
+		c.sessions.Start()
+	}
+	c.processor.Start()
+	for i := range c.shardChan {
+		c.aggregating.Add(1)
+		c.centerCtx.Worker.Go(func() {
+			defer c.aggregating.Done()
+			c.aggregateWindow(i)
+		})
+	}
+	c.centerCtx.Worker.Go(c.dispatcher)
 	c.centerCtx.Worker.Go(c.recycler)
+	c.centerCtx.Worker.Go(c.keepStream)
+	c.leader.Start()
+	c.started.Store(true)
 }
 
 // RecycleEvent recycle event that provided from eventChan
 func (c *DamakuController) RecycleEvent(event any) {
-	c.recycleChan <- event
+	select {
+	case c.recycleChan <- event:
+	case <-c.processor.Stopped():
+		// recycler stopped, left to gc
+	}
 }
 
-// receive agent message and process duplicate, msg subject should be *.agent.*, can be parallelization
+// receive agent message and process duplicate, msg subject should be *.stream.*,
+// msg of the same room always dispatched to the same worker
 func (c *DamakuController) aggregateWindow(workerId int) {
 	klog.InfoS("aggregate window start", "workerId", workerId)
 	for {
 		select {
-		case msg := <-c.streamChan:
-			subject := strings.Split(msg.Subject, ".")
-			switch subject[len(subject)-1] {
-			// meta msg will unmarshal first, then compare diff from cache
-			case "fansMedal":
-				meta := c.fansMedalPool.Get().(*agent.FansMedalMeta)
-				if err := proto.Unmarshal(msg.Data, meta); err != nil {
-					klog.Errorf("failed to unmarshal agent fans medal: %s", err.Error())
-					_ = agent.ControlError(msg, err)
-					c.fansMedalPool.Put(meta)
-					continue
-				}
-				if meta.RoomUID == 0 {
-					klog.Warning("agent fans medal room uid is zero")
-					_ = agent.ControlError(msg, errors.New("agent fans medal room uid is zero"))
-					c.fansMedalPool.Put(meta)
-					continue
-				}
-				medalKey := fmt.Sprintf("%d:%d", meta.UID, meta.RoomUID)
-				cached, err := c.medalMetaCache.Get(medalKey)
-				if err != nil {
-					if errors.Is(err, bigcache.ErrEntryNotFound) {
-						if err := c.medalMetaCache.Set(medalKey, msg.Data); err != nil {
-							klog.Errorf("failed to set fans medal cache: %s", err.Error())
-							_ = agent.ControlSuccess(msg) // raise controller cache
-						}
-						c.eventChan <- meta
-						continue
-					}
-					klog.Errorf("failed to get cached fans medal: %s", err.Error())
-					_ = agent.ControlError(msg, err)
-					c.fansMedalPool.Put(meta)
-					continue
-				}
-				var cachedMeta agent.FansMedalMeta
-				if err := proto.Unmarshal(cached, &cachedMeta); err != nil {
-					klog.Errorf("failed to unmarshal cached medal meta: %s", err.Error())
-					_ = agent.ControlError(msg, err)
-					c.fansMedalPool.Put(meta)
-					continue
-				}
-				// same as agent/agent.go:633
-				if meta.RoomUID == cachedMeta.RoomUID &&
-					meta.Name == cachedMeta.Name &&
-					meta.Level == cachedMeta.Level &&
-					meta.Light == cachedMeta.Light &&
-					meta.GuardLevel == cachedMeta.GuardLevel {
-					c.fansMedalPool.Put(meta)
-					continue
-				}
+		case msg := <-c.shardChan[workerId]:
+			c.ackStream(msg, c.aggregate(msg))
+		case <-c.centerCtx.Context.Done():
+			klog.InfoS("aggregate window stopped", "workerId", workerId)
+			return
+		}
+	}
+}
+
+// aggregate a stream msg, return false if msg cannot be handled for now, e.g. agent not registered yet
+func (c *DamakuController) aggregate(msg *nats.Msg) bool {
+	subject := strings.Split(msg.Subject, ".")
+	switch subject[len(subject)-1] {
+	// meta msg will unmarshal first, then compare diff from cache
+	case "fansMedal":
+		meta := c.fansMedalPool.Get().(*agent.FansMedalMeta)
+		if err := proto.Unmarshal(msg.Data, meta); err != nil {
+			klog.Errorf("failed to unmarshal agent fans medal: %s", err.Error())
+			_ = agent.ControlError(msg, err)
+			c.fansMedalPool.Put(meta)
+			return true
+		}
+		c.metrics.Received(EventFansMedal, 0)
+		if meta.RoomUID == 0 {
+			klog.Warning("agent fans medal room uid is zero")
+			_ = agent.ControlError(msg, errors.New("agent fans medal room uid is zero"))
+			c.fansMedalPool.Put(meta)
+			return true
+		}
+		medalKey := fmt.Sprintf("%d:%d", meta.UID, meta.RoomUID)
+		cached, err := c.medalMetaCache.Get(medalKey)
+		if err != nil {
+			if errors.Is(err, bigcache.ErrEntryNotFound) {
 				if err := c.medalMetaCache.Set(medalKey, msg.Data); err != nil {
-					klog.Errorf("failed to update fans medal cache: %s", err.Error())
-					// raise controller cache
-				}
-				_ = agent.ControlSuccess(msg)
-				c.eventChan <- meta
-			case "userInfoMeta":
-				meta := c.userInfoMetaPool.Get().(*agent.UserInfoMeta)
-				if err := proto.Unmarshal(msg.Data, meta); err != nil {
-					klog.Errorf("failed to unmarshal agent user meta: %s", err.Error())
-					_ = agent.ControlError(msg, err)
-					c.userInfoMetaPool.Put(meta)
-					continue
-				}
-				userKey := strconv.FormatUint(meta.UID, 10)
-				cached, err := c.userMetaCache.Get(userKey)
-				if err != nil {
-					if errors.Is(err, bigcache.ErrEntryNotFound) {
-						if err := c.userMetaCache.Set(userKey, msg.Data); err != nil {
-							klog.Errorf("failed to set user meta cache: %s", err.Error())
-							_ = agent.ControlSuccess(msg)
-						}
-						c.eventChan <- meta
-						continue
-					}
-					klog.Errorf("failed to get cached user meta: %s", err.Error())
-					_ = agent.ControlError(msg, err)
-					c.userInfoMetaPool.Put(meta)
-					continue
-				}
-				var cachedMeta agent.UserInfoMeta
-				if err := proto.Unmarshal(cached, &cachedMeta); err != nil {
-					klog.Errorf("failed to unmarshal cached user meta: %s", err.Error())
-					_ = agent.ControlError(msg, err)
-					c.userInfoMetaPool.Put(meta)
-					continue
-				}
-				// same as agent/agent.go:595
-				if meta.UserName == cachedMeta.UserName &&
-					compare.Equal(meta.Face, cachedMeta.Face) &&
-					compare.Equal(meta.Level, cachedMeta.Level) &&
-					compare.Equal(meta.WealthLevel, cachedMeta.WealthLevel) {
-					c.userInfoMetaPool.Put(meta)
-					continue
-				}
-				// diff compare
-				if meta.Face == nil && cachedMeta.Face != nil {
-					meta.Face = cachedMeta.Face
-				}
-				if meta.Level == nil && cachedMeta.Level != nil {
-					meta.Level = cachedMeta.Level
-				}
-				if meta.WealthLevel == nil && cachedMeta.WealthLevel != nil {
-					meta.WealthLevel = cachedMeta.WealthLevel
+					klog.Errorf("failed to set fans medal cache: %s", err.Error())
+					_ = agent.ControlSuccess(msg) // raise controller cache
 				}
+				c.pushEvent(meta)
+				return true
+			}
+			klog.Errorf("failed to get cached fans medal: %s", err.Error())
+			_ = agent.ControlError(msg, err)
+			c.fansMedalPool.Put(meta)
+			return true
+		}
+		var cachedMeta agent.FansMedalMeta
+		if err := proto.Unmarshal(cached, &cachedMeta); err != nil {
+			klog.Errorf("failed to unmarshal cached medal meta: %s", err.Error())
+			_ = agent.ControlError(msg, err)
+			c.fansMedalPool.Put(meta)
+			return true
+		}
+		// same as agent/agent.go:633
+		if meta.RoomUID == cachedMeta.RoomUID &&
+			meta.Name == cachedMeta.Name &&
+			meta.Level == cachedMeta.Level &&
+			meta.Light == cachedMeta.Light &&
+			meta.GuardLevel == cachedMeta.GuardLevel {
+			c.fansMedalPool.Put(meta)
+			return true
+		}
+		if err := c.medalMetaCache.Set(medalKey, msg.Data); err != nil {
+			klog.Errorf("failed to update fans medal cache: %s", err.Error())
+			// raise controller cache
+		}
+		_ = agent.ControlSuccess(msg)
+		c.pushEvent(meta)
+	case "userInfoMeta":
+		meta := c.userInfoMetaPool.Get().(*agent.UserInfoMeta)
+		if err := proto.Unmarshal(msg.Data, meta); err != nil {
+			klog.Errorf("failed to unmarshal agent user meta: %s", err.Error())
+			_ = agent.ControlError(msg, err)
+			c.userInfoMetaPool.Put(meta)
+			return true
+		}
+		c.metrics.Received(EventUserInfo, 0)
+		userKey := strconv.FormatUint(meta.UID, 10)
+		cached, err := c.userMetaCache.Get(userKey)
+		if err != nil {
+			if errors.Is(err, bigcache.ErrEntryNotFound) {
 				if err := c.userMetaCache.Set(userKey, msg.Data); err != nil {
-					klog.Errorf("failed to update user meta: %s", err.Error())
-				}
-				_ = agent.ControlSuccess(msg)
-				c.eventChan <- meta
-			// standard msg will unmarshal first, then aggregate the message
-			case "damaku":
-				damaku := c.damakuPool.Get().(*agent.Damaku) // obj will be release at msgDuplicateFilter
-				if err := proto.Unmarshal(msg.Data, damaku); err != nil {
-					klog.Errorf("failed to unmarshal damaku: %s", err.Error())
-					c.damakuPool.Put(damaku)
-					continue
-				}
-				mask := c.agent.AgentMask(damaku.Meta.Agent)
-				if mask == nil {
-					c.damakuPool.Put(damaku)
-					continue
-				}
-				if damaku.Meta.RoomID == nil {
-					klog.Warningf("damaku meta room uid is zero")
-					c.damakuPool.Put(damaku)
-					continue
-				}
-				// unique key: damaku roomID:uid:timestamp
-				key := fmt.Sprintf("%s:%d:%d:%d", "damaku", *damaku.Meta.RoomID, damaku.UID, damaku.Meta.TimeStamp)
-				if err := c.msgDuplicateFilter("damaku", key, damaku, mask, c.damakuPool); err != nil {
-					klog.Errorf("failed to passthrough duplicate filter with damaku: %s", err.Error())
-					continue
-				}
-			case "gift":
-				gift := c.giftPool.Get().(*agent.Gift)
-				if err := proto.Unmarshal(msg.Data, gift); err != nil {
-					klog.Errorf("failed to unmarshal gift: %s", err.Error())
-					c.giftPool.Put(gift)
-					continue
-				}
-				mask := c.agent.AgentMask(gift.Meta.Agent)
-				if mask == nil {
-					c.giftPool.Put(gift)
-					continue
-				}
-				// unique key: TID
-				key := fmt.Sprintf("%s:%d", "gift", gift.TID)
-				if err := c.msgDuplicateFilter("gift", key, gift, mask, c.giftPool); err != nil {
-					klog.Errorf("failed to passthrough duplicate filter with gift: %s", err.Error())
-					continue
+					klog.Errorf("failed to set user meta cache: %s", err.Error())
+					_ = agent.ControlSuccess(msg)
 				}
-			case "guard":
-				guard := c.guardPool.Get().(*agent.Guard)
-				if err := proto.Unmarshal(msg.Data, guard); err != nil {
-					klog.Errorf("failed to unmarshal guard: %s", err.Error())
-					c.guardPool.Put(guard)
-					continue
-				}
-				mask := c.agent.AgentMask(guard.Meta.Agent)
-				if mask == nil {
-					c.guardPool.Put(guard)
-					continue
-				}
-				// unique key: UID:timestamp
-				key := fmt.Sprintf("%s:%d:%d", "guard", guard.UID, guard.Meta.TimeStamp)
-				if err := c.msgDuplicateFilter("guard", key, guard, mask, c.guardPool); err != nil {
-					klog.Errorf("failed to passthrough duplicate filter with guard: %s", err.Error())
-					continue
-				}
-			case "superChat":
-				sc := c.superChatPool.Get().(*agent.SuperChat)
-				if err := proto.Unmarshal(msg.Data, sc); err != nil {
-					klog.Errorf("failed to unmarshal superChat: %s", err.Error())
-					c.superChatPool.Put(sc)
-					continue
-				}
-				mask := c.agent.AgentMask(sc.Meta.Agent)
-				if mask == nil {
-					c.superChatPool.Put(sc)
-					continue
-				}
-				// unique key: UID:timestamp
-				key := fmt.Sprintf("%s:%d:%d", "superChat", sc.UID, sc.Meta.TimeStamp)
-				if err := c.msgDuplicateFilter("superChat", key, sc, mask, c.superChatPool); err != nil {
-					klog.Errorf("failed to passthrough duplicate filter with superChat: %s", err.Error())
-					continue
-				}
-			// single stream only follow one agent at time, no duplicate check
-			case "online":
-				o := c.onlinePool.Get().(*agent.OnlineRankCount)
-				if err := proto.Unmarshal(msg.Data, o); err != nil {
-					klog.Errorf("failed to unmarshal online: %s", err.Error())
-					c.onlinePool.Put(o)
-					continue
-				}
-				if o.Meta.Agent != c.agent.MasterAgent() {
-					c.onlinePool.Put(o)
-					continue
-				}
-				c.eventChan <- o
-			case "onlineV2":
-				o := c.onlineV2Pool.Get().(*agent.OnlineRankV2)
-				if err := proto.Unmarshal(msg.Data, o); err != nil {
-					klog.Errorf("failed to unmarshal online: %s", err.Error())
-					c.onlineV2Pool.Put(o)
-					continue
-				}
-				if o.Meta.Agent != c.agent.MasterAgent() {
-					c.onlineV2Pool.Put(o)
-					continue
-				}
-				c.eventChan <- o
+				c.pushEvent(meta)
+				return true
 			}
-		case <-c.centerCtx.Context.Done():
-			klog.InfoS("aggregate window stopped", "workerId", workerId)
-			return
+			klog.Errorf("failed to get cached user meta: %s", err.Error())
+			_ = agent.ControlError(msg, err)
+			c.userInfoMetaPool.Put(meta)
+			return true
+		}
+		var cachedMeta agent.UserInfoMeta
+		if err := proto.Unmarshal(cached, &cachedMeta); err != nil {
+			klog.Errorf("failed to unmarshal cached user meta: %s", err.Error())
+			_ = agent.ControlError(msg, err)
+			c.userInfoMetaPool.Put(meta)
+			return true
+		}
+		// same as agent/agent.go:595
+		if meta.UserName == cachedMeta.UserName &&
+			compare.Equal(meta.Face, cachedMeta.Face) &&
+			compare.Equal(meta.Level, cachedMeta.Level) &&
+			compare.Equal(meta.WealthLevel, cachedMeta.WealthLevel) {
+			c.userInfoMetaPool.Put(meta)
+			return true
+		}
+		// diff compare
+		if meta.Face == nil && cachedMeta.Face != nil {
+			meta.Face = cachedMeta.Face
+		}
+		if meta.Level == nil && cachedMeta.Level != nil {
+			meta.Level = cachedMeta.Level
+		}
+		if meta.WealthLevel == nil && cachedMeta.WealthLevel != nil {
+			meta.WealthLevel = cachedMeta.WealthLevel
+		}
+		if err := c.userMetaCache.Set(userKey, msg.Data); err != nil {
+			klog.Errorf("failed to update user meta: %s", err.Error())
+		}
+		_ = agent.ControlSuccess(msg)
+		c.pushEvent(meta)
+	// standard msg will unmarshal first, then aggregate the message
+	case "damaku":
+		damaku := c.damakuPool.Get().(*agent.Damaku) // obj will be release at msgDuplicateFilter
+		if err := proto.Unmarshal(msg.Data, damaku); err != nil {
+			klog.Errorf("failed to unmarshal damaku: %s", err.Error())
+			c.damakuPool.Put(damaku)
+			return true
+		}
+		c.metrics.Received(EventDamaku, damaku.Meta.GetRoomID())
+		mask := c.agent.AgentMask(damaku.Meta.Agent)
+		if mask == nil {
+			c.damakuPool.Put(damaku)
+			return false // agent not registered yet
+		}
+		if damaku.Meta.RoomID == nil {
+			klog.Warningf("damaku meta room uid is zero")
+			c.damakuPool.Put(damaku)
+			return true
+		}
+		if err := c.msgDuplicateFilter("damaku", damaku, mask, c.damakuPool); err != nil {
+			klog.Errorf("failed to passthrough duplicate filter with damaku: %s", err.Error())
+			return true
+		}
+	case "gift":
+		gift := c.giftPool.Get().(*agent.Gift)
+		if err := proto.Unmarshal(msg.Data, gift); err != nil {
+			klog.Errorf("failed to unmarshal gift: %s", err.Error())
+			c.giftPool.Put(gift)
+			return true
+		}
+		c.metrics.Received(EventGift, gift.Meta.GetRoomID())
+		mask := c.agent.AgentMask(gift.Meta.Agent)
+		if mask == nil {
+			c.giftPool.Put(gift)
+			return false // agent not registered yet
+		}
+		if err := c.msgDuplicateFilter("gift", gift, mask, c.giftPool); err != nil {
+			klog.Errorf("failed to passthrough duplicate filter with gift: %s", err.Error())
+			return true
+		}
+	case "guard":
+		guard := c.guardPool.Get().(*agent.Guard)
+		if err := proto.Unmarshal(msg.Data, guard); err != nil {
+			klog.Errorf("failed to unmarshal guard: %s", err.Error())
+			c.guardPool.Put(guard)
+			return true
 		}
+		c.metrics.Received(EventGuard, guard.Meta.GetRoomID())
+		mask := c.agent.AgentMask(guard.Meta.Agent)
+		if mask == nil {
+			c.guardPool.Put(guard)
+			return false // agent not registered yet
+		}
+		if err := c.msgDuplicateFilter("guard", guard, mask, c.guardPool); err != nil {
+			klog.Errorf("failed to passthrough duplicate filter with guard: %s", err.Error())
+			return true
+		}
+	case "superChat":
+		sc := c.superChatPool.Get().(*agent.SuperChat)
+		if err := proto.Unmarshal(msg.Data, sc); err != nil {
+			klog.Errorf("failed to unmarshal superChat: %s", err.Error())
+			c.superChatPool.Put(sc)
+			return true
+		}
+		c.metrics.Received(EventSuperChat, sc.Meta.GetRoomID())
+		mask := c.agent.AgentMask(sc.Meta.Agent)
+		if mask == nil {
+			c.superChatPool.Put(sc)
+			return false // agent not registered yet
+		}
+		if err := c.msgDuplicateFilter("superChat", sc, mask, c.superChatPool); err != nil {
+			klog.Errorf("failed to passthrough duplicate filter with superChat: %s", err.Error())
+			return true
+		}
+	// single stream only follow one agent at time, no duplicate check
+	case "online":
+		o := c.onlinePool.Get().(*agent.OnlineRankCount)
+		if err := proto.Unmarshal(msg.Data, o); err != nil {
+			klog.Errorf("failed to unmarshal online: %s", err.Error())
+			c.onlinePool.Put(o)
+			return true
+		}
+		c.metrics.Received(EventOnline, o.Meta.GetRoomID())
+		if o.Meta.Agent != c.agent.RoomMaster(o.Meta.GetRoomID()) {
+			c.onlinePool.Put(o)
+			return true
+		}
+		c.pushEvent(o)
+	case "onlineV2":
+		o := c.onlineV2Pool.Get().(*agent.OnlineRankV2)
+		if err := proto.Unmarshal(msg.Data, o); err != nil {
+			klog.Errorf("failed to unmarshal online: %s", err.Error())
+			c.onlineV2Pool.Put(o)
+			return true
+		}
+		c.metrics.Received(EventOnlineV2, o.Meta.GetRoomID())
+		if o.Meta.Agent != c.agent.RoomMaster(o.Meta.GetRoomID()) {
+			c.onlineV2Pool.Put(o)
+			return true
+		}
+		c.pushEvent(o)
+	// room events, deduplicated by their strategies
+	case "interact":
+		return c.aggregateStream(msg, EventInteract, c.interactPool)
+	case "like":
+		return c.aggregateStream(msg, EventLike, c.likePool)
+	case "roomChange":
+		return c.aggregateStream(msg, EventRoomChange, c.roomChangePool)
+	case "liveStatus":
+		return c.aggregateStream(msg, EventLiveStatus, c.liveStatusPool)
+	case "watched":
+		return c.aggregateStream(msg, EventWatched, c.watchedPool)
+	case "fans":
+		return c.aggregateStream(msg, EventFans, c.fansPool)
 	}
+	return true
 }
 
-// recycle event from recycleChan, no need to parallelization
+// aggregateStream unmarshal stream msg into pooled event and pass it through duplicate filter,
+// return false if agent not registered yet
+func (c *DamakuController) aggregateStream(msg *nats.Msg, eventType string, pool *sync.Pool) bool {
+	event := pool.Get().(interface {
+		proto.Message
+		GetMeta() *agent.BasicMsgMeta
+	})
+	if err := proto.Unmarshal(msg.Data, event); err != nil {
+		klog.Errorf("failed to unmarshal %s: %s", eventType, err.Error())
+		pool.Put(event)
+		return true
+	}
+	c.metrics.Received(eventType, event.GetMeta().GetRoomID())
+	mask := c.agent.AgentMask(event.GetMeta().GetAgent())
+	if mask == nil {
+		pool.Put(event)
+		return false // agent not registered yet
+	}
+	if event.GetMeta().RoomID == nil {
+		klog.Warningf("%s meta room uid is zero", eventType)
+		pool.Put(event)
+		return true
+	}
+	if err := c.msgDuplicateFilter(eventType, event, mask, pool); err != nil {
+		klog.Errorf("failed to passthrough duplicate filter with %s: %s", eventType, err.Error())
+	}
+	return true
+}
+
+// recycle event from recycleChan, no need to parallelization.
+// Stop after processor stopped, so processor never blocks on recycleChan at shutdown
 func (c *DamakuController) recycler() {
 	klog.Info("recycler start")
 	for {
@@ -378,41 +530,61 @@ func (c *DamakuController) recycler() {
 				c.onlinePool.Put(msg)
 			case *agent.OnlineRankV2:
 				c.onlineV2Pool.Put(msg)
+			case *agent.Interact:
+				c.interactPool.Put(msg)
+			case *agent.LikeCount:
+				c.likePool.Put(msg)
+			case *agent.RoomChange:
+				c.roomChangePool.Put(msg)
+			case *agent.LiveStatus:
+				c.liveStatusPool.Put(msg)
+			case *agent.WatchedCount:
+				c.watchedPool.Put(msg)
+			case *agent.FansCount:
+				c.fansPool.Put(msg)
+			case *agent.StreamGap:
+				// not pooled
 			default:
 				klog.Warningf("unknown event type that cannot be recycled: %T", msg)
 			}
-		case <-c.centerCtx.Context.Done():
+		case <-c.processor.Stopped():
 			klog.Info("recycler stopped")
 			return
 		}
 	}
 }
 
-func (c *DamakuController) msgDuplicateFilter(msgType, key string, msg proto.Message, mask []byte, pool *sync.Pool) error {
-	filtered := false
-	if _, err := c.dupCache.Get(key); err == nil {
-		if errors.Is(err, bigcache.ErrEntryNotFound) {
-			// cache miss, add it and push
-			c.eventChan <- msg
-		} else {
-			klog.Errorf("failed to get cached %s: %s", msgType, err.Error())
-			c.eventChan <- msg // raise controller cache
-			return err
-		}
-	} else {
-		filtered = true
-	}
-	if filtered {
-		pool.Put(msg) // filtered
-	}
-	// add flag
-	if err := c.dupCache.Append(key, mask); err != nil {
-		klog.Errorf("failed to append cache for msg: %s", err.Error())
+// msgDuplicateFilter push msg if its unique key not seen in duplicate window, key is built by dedup strategy of msg type
+func (c *DamakuController) msgDuplicateFilter(msgType string, msg proto.Message, mask []byte, pool *sync.Pool) error {
+	seen, err := c.msgSeen(dedupKeys(msgType, c.dedupStrategy[msgType], msg), mask)
+	if err != nil {
+		klog.Errorf("failed to check duplicate %s: %s", msgType, err.Error())
+		c.pushEvent(msg) // raise dedup store
 		return err
 	}
+	if seen {
+		c.metrics.Duplicated(msgType, eventMeta(msg).GetRoomID())
+		pool.Put(msg) // filtered
+		return nil
+	}
+	c.pushEvent(msg)
 	return nil
 }
 
+// msgSeen check the adjacent fuzzy window first, then add agent flag to the primary key
+func (c *DamakuController) msgSeen(keys []string, mask []byte) (bool, error) {
+	for _, key := range keys[1:] {
+		exists, err := c.dedup.Exists(key)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return c.dedup.Seen(key, mask)
+		}
+	}
+	return c.dedup.Seen(keys[0], mask)
+}
+
 // pop cached msg info and push msg hit metrics
 func (c *DamakuController) dupCacheRelease(key string, entry []byte) {
 	m := strings.Split(key, ":")
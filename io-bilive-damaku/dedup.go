@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/allegro/bigcache/v3"
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+)
+
+// dedup key strategies
+const (
+	DedupKeyTime    = "time"    // room, uid and timestamp, room and live flag for liveStatus
+	DedupKeyContent = "content" // room, uid, hash of content and timestamp, damaku and roomChange only
+	DedupKeyID      = "id"      // msg id, gift TID or superChat ID
+	DedupKeyValue   = "value"   // room, reported value and timestamp, counters only
+)
+
+var (
+	// DefaultDedupStrategies used for event types not configured
+	DefaultDedupStrategies = map[string]DedupStrategy{
+		EventDamaku:    {Key: DedupKeyContent, Window: time.Second * 2}, // agents may receive the same damaku with different timestamp
+		EventGift:      {Key: DedupKeyID},
+		EventGuard:     {Key: DedupKeyTime},
+		EventSuperChat: {Key: DedupKeyID},
+		EventInteract:  {Key: DedupKeyTime},
+		// timestamp of following events is the time agent received them
+		EventLike:       {Key: DedupKeyValue, Window: time.Second * 5},
+		EventRoomChange: {Key: DedupKeyContent, Window: time.Second * 10},
+		EventLiveStatus: {Key: DedupKeyTime, Window: time.Second * 10},
+		EventWatched:    {Key: DedupKeyValue, Window: time.Second * 5},
+		EventFans:       {Key: DedupKeyValue, Window: time.Second * 5},
+	}
+	dedupSupportedKeys = map[string][]string{
+		EventDamaku:     {DedupKeyTime, DedupKeyContent},
+		EventGift:       {DedupKeyID},
+		EventGuard:      {DedupKeyTime},
+		EventSuperChat:  {DedupKeyTime, DedupKeyID},
+		EventInteract:   {DedupKeyTime},
+		EventLike:       {DedupKeyValue},
+		EventRoomChange: {DedupKeyContent},
+		EventLiveStatus: {DedupKeyTime},
+		EventWatched:    {DedupKeyValue},
+		EventFans:       {DedupKeyValue},
+	}
+)
+
+// set key if absent and append mask, return 1 if key existed
+var dedupSeenScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 0
+end
+redis.call("APPEND", KEYS[1], ARGV[1])
+return 1`)
+
+// DedupStrategy decide the unique key of an event type
+type DedupStrategy struct {
+	Key    string        `json:"key" yaml:"key"`
+	Window time.Duration `json:"window" yaml:"window"` // fuzzy time window of timestamp, exact timestamp if 0
+}
+
+// dedupStrategies merge configured strategies with defaults and check them
+func dedupStrategies(config map[string]DedupStrategy) (map[string]DedupStrategy, error) {
+	strategies := make(map[string]DedupStrategy, len(DefaultDedupStrategies))
+	for eventType, strategy := range DefaultDedupStrategies {
+		strategies[eventType] = strategy
+	}
+	for eventType, strategy := range config {
+		supported, ok := dedupSupportedKeys[eventType]
+		if !ok {
+			return nil, fmt.Errorf("event type %s not support dedup", eventType)
+		}
+		if !slices.Contains(supported, strategy.Key) {
+			return nil, fmt.Errorf("dedup key %q not supported by %s, supported: %v", strategy.Key, eventType, supported)
+		}
+		strategies[eventType] = strategy
+	}
+	return strategies, nil
+}
+
+// dedupKeys build unique keys of event, the first one is the primary key,
+// the following is the adjacent time bucket that fuzzy window may overlap
+func dedupKeys(eventType string, strategy DedupStrategy, event any) []string {
+	var prefix string
+	var ts uint64
+	switch e := event.(type) {
+	case *agent.Damaku:
+		ts = e.Meta.TimeStamp
+		if strategy.Key == DedupKeyContent {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(e.Content))
+			prefix = fmt.Sprintf("%s:%d:%d:%x", eventType, e.Meta.GetRoomID(), e.UID, h.Sum64())
+		} else {
+			prefix = fmt.Sprintf("%s:%d:%d", eventType, e.Meta.GetRoomID(), e.UID)
+		}
+	case *agent.Gift:
+		return []string{fmt.Sprintf("%s:%d", eventType, e.TID)}
+	case *agent.Guard:
+		ts = e.Meta.TimeStamp
+		prefix = fmt.Sprintf("%s:%d", eventType, e.UID)
+	case *agent.SuperChat:
+		if strategy.Key == DedupKeyID {
+			return []string{fmt.Sprintf("%s:%d", eventType, e.ID)}
+		}
+		ts = uint64(eventTime(e.Meta).UnixMilli()) // in seconds from old agents
+		prefix = fmt.Sprintf("%s:%d", eventType, e.UID)
+	case *agent.Interact:
+		ts = e.Meta.TimeStamp
+		prefix = fmt.Sprintf("%s:%d:%d:%d", eventType, e.Meta.GetRoomID(), e.UID, e.Type)
+	case *agent.LikeCount:
+		ts = e.Meta.TimeStamp
+		prefix = fmt.Sprintf("%s:%d:%d", eventType, e.Meta.GetRoomID(), e.Count)
+	case *agent.RoomChange:
+		ts = e.Meta.TimeStamp
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(e.Title))
+		prefix = fmt.Sprintf("%s:%d:%d:%x", eventType, e.Meta.GetRoomID(), e.AreaID, h.Sum64())
+	case *agent.LiveStatus:
+		ts = e.Meta.TimeStamp
+		prefix = fmt.Sprintf("%s:%d:%t", eventType, e.Meta.GetRoomID(), e.Live)
+	case *agent.WatchedCount:
+		ts = e.Meta.TimeStamp
+		prefix = fmt.Sprintf("%s:%d:%d", eventType, e.Meta.GetRoomID(), e.Count)
+	case *agent.FansCount:
+		ts = e.Meta.TimeStamp
+		prefix = fmt.Sprintf("%s:%d:%d:%d", eventType, e.Meta.GetRoomID(), e.Fans, e.FansClub)
+	default:
+		return nil
+	}
+	window := uint64(strategy.Window.Milliseconds())
+	if window == 0 {
+		return []string{fmt.Sprintf("%s:%d", prefix, ts)}
+	}
+	bucket := ts / window
+	adjacent := bucket + 1
+	if ts%window < window/2 {
+		if bucket == 0 {
+			return []string{fmt.Sprintf("%s:w%d", prefix, bucket)}
+		}
+		adjacent = bucket - 1
+	}
+	return []string{fmt.Sprintf("%s:w%d", prefix, bucket), fmt.Sprintf("%s:w%d", prefix, adjacent)}
+}
+
+// MemoryDedupStore keep keys in local bigcache, only for single controller
+type MemoryDedupStore struct {
+	cache *bigcache.BigCache // [msgType]:[msgUniqueKey]
+}
+
+func (s *MemoryDedupStore) Init(ctx *CenterContext, release func(key string, entry []byte)) (err error) {
+	s.cache, err = bigcache.New(ctx.Context, bigcache.Config{
+		Shards:      1024,
+		LifeWindow:  ctx.Config.Controller.DuplicateWindow,
+		CleanWindow: time.Minute,
+		OnRemove:    release,
+		Logger:      klog.NewStandardLogger("INFO"),
+	})
+	return
+}
+
+func (s *MemoryDedupStore) Seen(key string, mask []byte) (bool, error) {
+	seen := true
+	if _, err := s.cache.Get(key); err != nil {
+		if !errors.Is(err, bigcache.ErrEntryNotFound) {
+			return false, err
+		}
+		seen = false
+	}
+	return seen, s.cache.Append(key, mask)
+}
+
+func (s *MemoryDedupStore) Exists(key string) (bool, error) {
+	if _, err := s.cache.Get(key); err != nil {
+		if errors.Is(err, bigcache.ErrEntryNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RedisDedupStore share keys between controller replicas, so msg redelivered after leader changed is still deduplicated.
+// Masks are also appended to local cache for hit statistics of this replica
+type RedisDedupStore struct {
+	centerCtx *CenterContext
+	prefix    string
+	window    time.Duration
+	local     *MemoryDedupStore
+}
+
+func (s *RedisDedupStore) Init(ctx *CenterContext, release func(key string, entry []byte)) error {
+	s.centerCtx = ctx
+	s.prefix = fmt.Sprintf("%s:controller:dedup:", ctx.Config.Global.Prefix)
+	s.window = ctx.Config.Controller.DuplicateWindow
+	s.local = &MemoryDedupStore{}
+	return s.local.Init(ctx, release)
+}
+
+func (s *RedisDedupStore) Seen(key string, mask []byte) (bool, error) {
+	if _, err := s.local.Seen(key, mask); err != nil {
+		klog.Warningf("[Dedup]failed to append local cache: %s", err.Error())
+	}
+	seen, err := dedupSeenScript.Run(s.centerCtx.Context, s.centerCtx.RDB.DB(), []string{s.prefix + key}, mask, s.window.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return seen == 1, nil
+}
+
+func (s *RedisDedupStore) Exists(key string) (bool, error) {
+	n, err := s.centerCtx.RDB.DB().Exists(s.centerCtx.Context, s.prefix+key).Result()
+	return n > 0, err
+}
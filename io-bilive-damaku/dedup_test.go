@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+)
+
+func TestDedupKeys(t *testing.T) {
+	room := uint64(1)
+	damaku := func(ts uint64) *agent.Damaku {
+		return &agent.Damaku{Meta: &agent.BasicMsgMeta{RoomID: &room, TimeStamp: ts}, UID: 2, Content: "hello"}
+	}
+	fuzzy := DedupStrategy{Key: DedupKeyTime, Window: time.Second * 2}
+	cases := []struct {
+		name      string
+		eventType string
+		strategy  DedupStrategy
+		event     any
+		want      []string
+	}{
+		{"exact", EventDamaku, DedupStrategy{Key: DedupKeyTime}, damaku(12345), []string{"damaku:1:2:12345"}},
+		{"first half", EventDamaku, fuzzy, damaku(10500), []string{"damaku:1:2:w5", "damaku:1:2:w4"}},
+		{"second half", EventDamaku, fuzzy, damaku(11500), []string{"damaku:1:2:w5", "damaku:1:2:w6"}},
+		{"first bucket", EventDamaku, fuzzy, damaku(500), []string{"damaku:1:2:w0"}},
+		{"id", EventGift, DedupStrategy{Key: DedupKeyID}, &agent.Gift{TID: 3}, []string{"gift:3"}},
+		{"superChat in seconds", EventSuperChat, DedupStrategy{Key: DedupKeyTime},
+			&agent.SuperChat{Meta: &agent.BasicMsgMeta{RoomID: &room, TimeStamp: 1719835200}, UID: 2},
+			[]string{"superChat:2:1719835200000"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := dedupKeys(c.eventType, c.strategy, c.event); !slices.Equal(got, c.want) {
+				t.Fatalf("unexpected keys: %v, want %v", got, c.want)
+			}
+		})
+	}
+	// same content only
+	content := dedupKeys(EventDamaku, DefaultDedupStrategies[EventDamaku], damaku(10500))
+	other := damaku(10500)
+	other.Content = "world"
+	if slices.Equal(content, dedupKeys(EventDamaku, DefaultDedupStrategies[EventDamaku], other)) {
+		t.Fatal("damaku of different content share keys")
+	}
+}
+
+func TestMsgSeen(t *testing.T) {
+	config := NewConfig()
+	config.Controller.DuplicateWindow = time.Minute
+	store := &MemoryDedupStore{}
+	if err := store.Init(&CenterContext{Context: context.Background(), Config: config}, func(string, []byte) {}); err != nil {
+		t.Fatal(err)
+	}
+	c := &DamakuController{dedup: store}
+	strategy := DedupStrategy{Key: DedupKeyTime, Window: time.Second * 2}
+	room := uint64(1)
+	seen := func(ts uint64) bool {
+		keys := dedupKeys(EventDamaku, strategy, &agent.Damaku{Meta: &agent.BasicMsgMeta{RoomID: &room, TimeStamp: ts}, UID: 2})
+		ok, err := c.msgSeen(keys, []byte{0, 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	cases := []struct {
+		name string
+		ts   uint64
+		want bool
+	}{
+		{"first", 11900, false},
+		{"same bucket", 10100, true},
+		{"adjacent bucket", 12100, true},   // within window across bucket boundary
+		{"next adjacent", 13900, false},    // bucket 6, second half, adjacent to 7
+		{"far away", 20000, false},         // bucket 10
+		{"back to adjacent", 19900, true},  // bucket 9, second half, adjacent to 10
+		{"two buckets away", 16000, false}, // bucket 8, first half, adjacent to 7
+	}
+	for _, c := range cases {
+		if got := seen(c.ts); got != c.want {
+			t.Fatalf("%s: unexpected seen of %d: %t, want %t", c.name, c.ts, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"k8s.io/klog/v2"
+)
+
+type fansMedalKey struct {
+	UID     uint64
+	RoomUID uint64
+}
+
+// metaTime of source message carried by meta, now if it is not carried by old agents
+func metaTime(ts uint64) time.Time {
+	if ts == 0 {
+		return time.Now()
+	}
+	return eventTime(&agent.BasicMsgMeta{TimeStamp: ts})
+}
+
+// changed user meta from controller, nil field means not carried by this source message
+func userHistoryRecord(meta *agent.UserInfoMeta) *UserHistoryRecord {
+	return &UserHistoryRecord{
+		Time:        metaTime(meta.TimeStamp),
+		UID:         meta.UID,
+		UserName:    meta.UserName,
+		Face:        clonePtr(meta.Face),
+		Level:       clonePtr(meta.Level),
+		WealthLevel: clonePtr(meta.WealthLevel),
+	}
+}
+
+// changed medal meta from controller, it is always a full update
+func medalHistoryRecord(meta *agent.FansMedalMeta) *FansMedalHistoryRecord {
+	return &FansMedalHistoryRecord{
+		Time:       metaTime(meta.TimeStamp),
+		UID:        meta.UID,
+		RoomUID:    meta.RoomUID,
+		Name:       meta.Name,
+		Level:      meta.Level,
+		Light:      meta.Light,
+		GuardLevel: int32(meta.GuardLevel),
+	}
+}
+
+func (s *StorageController) bufferUser(history *UserHistoryRecord) {
+	s.userHistory = append(s.userHistory, history)
+	user, ok := s.users[history.UID]
+	if !ok {
+		user = &UserRecord{UID: history.UID}
+		s.users[history.UID] = user
+	}
+	if history.Time.Before(user.UpdatedAt) {
+		// older source message arrived late, keep the newer state
+		return
+	}
+	// merge into pending state, one row can only be upserted once in a batch
+	user.UserName = history.UserName
+	user.UpdatedAt = history.Time
+	if history.Face != nil {
+		user.Face = history.Face
+	}
+	if history.Level != nil {
+		user.Level = history.Level
+	}
+	if history.WealthLevel != nil {
+		user.WealthLevel = history.WealthLevel
+	}
+}
+
+func (s *StorageController) bufferMedal(history *FansMedalHistoryRecord) {
+	s.medalHistory = append(s.medalHistory, history)
+	key := fansMedalKey{UID: history.UID, RoomUID: history.RoomUID}
+	if pending, ok := s.medals[key]; ok && pending.UpdatedAt.After(history.Time) {
+		// older source message arrived late, keep the newer state
+		return
+	}
+	s.medals[key] = &FansMedalRecord{
+		UID:        history.UID,
+		RoomUID:    history.RoomUID,
+		Name:       history.Name,
+		Level:      history.Level,
+		Light:      history.Light,
+		GuardLevel: history.GuardLevel,
+		UpdatedAt:  history.Time,
+	}
+}
+
+// upsert current state and insert change history
+func (s *StorageController) flushDimension(db *gorm.DB) {
+	if len(s.users) > 0 {
+		users := make([]*UserRecord, 0, len(s.users))
+		for _, u := range s.users {
+			users = append(users, u)
+		}
+		if err := db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "uid"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "user_name"}, Value: gorm.Expr("excluded.user_name")},
+				{Column: clause.Column{Name: "face"}, Value: gorm.Expr("COALESCE(excluded.face, bilive_user.face)")},
+				{Column: clause.Column{Name: "level"}, Value: gorm.Expr("COALESCE(excluded.level, bilive_user.level)")},
+				{Column: clause.Column{Name: "wealth_level"}, Value: gorm.Expr("COALESCE(excluded.wealth_level, bilive_user.wealth_level)")},
+				{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("excluded.updated_at")},
+			},
+			// never overwrite newer state by message arrived late in a later batch
+			Where: clause.Where{Exprs: []clause.Expression{gorm.Expr("excluded.updated_at >= bilive_user.updated_at")}},
+		}).CreateInBatches(users, s.config.BatchSize).Error; err != nil {
+			klog.Errorf("[Storage]failed to upsert %d users: %s", len(users), err.Error())
+		}
+		clear(s.users)
+	}
+	if len(s.medals) > 0 {
+		medals := make([]*FansMedalRecord, 0, len(s.medals))
+		for _, m := range s.medals {
+			medals = append(medals, m)
+		}
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "uid"}, {Name: "room_uid"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "level", "light", "guard_level", "updated_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{gorm.Expr("excluded.updated_at >= bilive_fans_medal.updated_at")}},
+		}).CreateInBatches(medals, s.config.BatchSize).Error; err != nil {
+			klog.Errorf("[Storage]failed to upsert %d fans medals: %s", len(medals), err.Error())
+		}
+		clear(s.medals)
+	}
+	insertBatch(db, "userHistory", &s.userHistory, s.config.BatchSize)
+	insertBatch(db, "medalHistory", &s.medalHistory, s.config.BatchSize)
+}
+
+// meta will be recycled after buffered, never keep pointer of it
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/search"
+	"github.com/allegro/bigcache/v3"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+// SearchIndexer feed danmaku and SuperChat text to MeiliSearch, it is a sink of MessageProcessor
+type SearchIndexer struct {
+	centerCtx     *CenterContext
+	indexer       *search.Indexer
+	userMetaCache *bigcache.BigCache // UserInfoMeta: uid, for username
+}
+
+func (s *SearchIndexer) Init(ctx *CenterContext, userMetaCache *bigcache.BigCache) {
+	s.centerCtx = ctx
+	s.indexer = search.NewIndexer(&ctx.Config.Search)
+	s.userMetaCache = userMetaCache
+}
+
+func (s *SearchIndexer) Start() {
+	klog.Infof("starting search indexer")
+	// setup in background, MeiliSearch may be unreachable with retries
+	s.centerCtx.Worker.Go(func() {
+		if err := s.indexer.Setup(s.centerCtx.Context); err != nil {
+			klog.Warningf("[Search]failed to setup index %s: %s", s.centerCtx.Config.Search.Index, err.Error())
+		}
+	})
+	s.centerCtx.Worker.Go(func() {
+		s.indexer.Run(s.centerCtx.Context, s.centerCtx.Config.Controller.ShutdownTimeout)
+	})
+}
+
+// Handle copy text of event into document
+func (s *SearchIndexer) Handle(event any) bool {
+	var doc *search.Document
+	switch e := event.(type) {
+	case *agent.Damaku:
+		doc = &search.Document{
+			ID:        fmt.Sprintf("%s-%d-%d-%d", EventDamaku, e.Meta.GetRoomID(), e.UID, e.Meta.TimeStamp),
+			Type:      EventDamaku,
+			RoomID:    e.Meta.GetRoomID(),
+			UID:       e.UID,
+			Medal:     e.Medal,
+			Content:   e.Content,
+			TimeStamp: uint64(eventTime(e.Meta).UnixMilli()),
+		}
+	case *agent.SuperChat:
+		doc = &search.Document{
+			ID:           fmt.Sprintf("%s-%d", EventSuperChat, e.ID),
+			Type:         EventSuperChat,
+			RoomID:       e.Meta.GetRoomID(),
+			UID:          e.UID,
+			Medal:        e.Medal,
+			Content:      e.Message,
+			ContentTrans: e.MessageTrans,
+			Price:        e.Price,
+			TimeStamp:    uint64(eventTime(e.Meta).UnixMilli()),
+		}
+	default:
+		return true
+	}
+	doc.UserName = s.userName(doc.UID)
+	if !s.indexer.Add(doc) {
+		klog.V(3).Infof("[Search]buffer full, %s dropped", doc.ID)
+	}
+	return true
+}
+
+func (s *SearchIndexer) userName(uid uint64) string {
+	cached, err := s.userMetaCache.Get(strconv.FormatUint(uid, 10))
+	if err != nil {
+		return ""
+	}
+	var meta agent.UserInfoMeta
+	if err := proto.Unmarshal(cached, &meta); err != nil {
+		return ""
+	}
+	return meta.UserName
+}
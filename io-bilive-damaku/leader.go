@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// renew lease only if still held by this instance
+	leaderRenewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	leaderReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// LeaderElector elect a leader from controller replicas through a redis lease,
+// only the leader drive agents and consume stream msg
+type LeaderElector struct {
+	centerCtx *CenterContext
+	config    *LeaderConfig
+	id        string // instance id, value of the lease
+	key       string
+	hooks     []func(isLeader bool)
+	renewedAt time.Time
+
+	leader atomic.Bool
+}
+
+func (e *LeaderElector) Init(ctx *CenterContext) {
+	e.centerCtx = ctx
+	e.config = &ctx.Config.Controller.Leader
+	hostname, _ := os.Hostname()
+	e.id = fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8])
+	e.key = fmt.Sprintf("%s:controller:leader", ctx.Config.Global.Prefix)
+}
+
+// OnChanged add a hook called when leadership changed, only available before Start
+func (e *LeaderElector) OnChanged(hook func(isLeader bool)) {
+	e.hooks = append(e.hooks, hook)
+}
+
+func (e *LeaderElector) Start() {
+	if !e.config.Enable {
+		klog.Infof("leader election disabled, running as the only controller")
+		e.set(true)
+		return
+	}
+	klog.Infof("starting leader elector, instance: %s", e.id)
+	e.centerCtx.Worker.Go(e.elector)
+}
+
+// IsLeader always true if leader election disabled
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// ID of this controller instance
+func (e *LeaderElector) ID() string {
+	return e.id
+}
+
+// Leader return the instance id of current leader
+func (e *LeaderElector) Leader() (string, error) {
+	if !e.config.Enable {
+		return e.id, nil
+	}
+	id, err := e.centerCtx.RDB.DB().Get(e.centerCtx.Context, e.key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return id, err
+}
+
+// keep trying to acquire or renew the lease
+func (e *LeaderElector) elector() {
+	ticker := time.NewTicker(e.config.LeaseTTL / 3)
+	defer ticker.Stop()
+	klog.Info("[Leader]elector start")
+	e.campaign()
+	for {
+		select {
+		case <-ticker.C:
+			e.campaign()
+		case <-e.centerCtx.Context.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			if err := leaderReleaseScript.Run(ctx, e.centerCtx.RDB.DB(), []string{e.key}, e.id).Err(); err != nil {
+				klog.Errorf("[Leader]failed to release lease: %s", err.Error())
+			}
+			cancel()
+			e.set(false)
+			klog.Info("[Leader]elector stopped")
+			return
+		}
+	}
+}
+
+func (e *LeaderElector) campaign() {
+	rdb := e.centerCtx.RDB.DB()
+	if e.IsLeader() {
+		renewed, err := leaderRenewScript.Run(e.centerCtx.Context, rdb, []string{e.key}, e.id, e.config.LeaseTTL.Milliseconds()).Int()
+		if err != nil {
+			klog.Errorf("[Leader]failed to renew lease: %s", err.Error())
+			if time.Since(e.renewedAt) > e.config.LeaseTTL {
+				// lease expired, another instance may be elected
+				e.set(false)
+			}
+			return
+		}
+		if renewed == 0 {
+			klog.Warning("[Leader]lease lost")
+			e.set(false)
+			return
+		}
+		e.renewedAt = time.Now()
+		return
+	}
+	acquired, err := rdb.SetNX(e.centerCtx.Context, e.key, e.id, e.config.LeaseTTL).Result()
+	if err != nil {
+		klog.Errorf("[Leader]failed to acquire lease: %s", err.Error())
+		return
+	}
+	if acquired {
+		e.renewedAt = time.Now()
+		e.set(true)
+	}
+}
+
+func (e *LeaderElector) set(isLeader bool) {
+	if e.leader.Swap(isLeader) == isLeader {
+		return
+	}
+	if isLeader {
+		klog.Infof("[Leader]instance %s became leader", e.id)
+	} else {
+		klog.Infof("[Leader]instance %s is no longer leader", e.id)
+	}
+	for _, hook := range e.hooks {
+		hook(isLeader)
+	}
+}
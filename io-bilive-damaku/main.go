@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"github.com/TiyaAnlite/FocotServicesCommon/dbx"
 	"github.com/TiyaAnlite/FocotServicesCommon/echox"
@@ -13,8 +12,10 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/klog/v2"
+	"slices"
 	"sync"
 	"testing"
+	"time"
 )
 
 type envConfig struct {
@@ -41,6 +42,10 @@ func init() {
 	testing.Init()
 	klog.InitFlags(nil)
 	flag.Parse()
+}
+
+// load config and connect to services
+func setup() {
 	envx.MustLoadEnv(envCfg)
 	envx.MustReadYamlConfig(cfg, envCfg.ConfigFile)
 	if err := mq.Open(envCfg.NatsConfig); err != nil {
@@ -55,9 +60,11 @@ func init() {
 }
 
 func main() {
-	// build global context
+	setup()
+	// build global context, cancelled at exit
+	baseCtx, cancel := context.WithCancel(context.Background())
 	ctx = &CenterContext{
-		Context:  context.Background(),
+		Context:  baseCtx,
 		Config:   cfg,
 		Worker:   worker,
 		Registry: prometheus.NewRegistry(),
@@ -70,25 +77,45 @@ func main() {
 	if err := controller.Init(ctx, providers); err != nil {
 		klog.Fatalf("failed to init controller: %s", err.Error())
 	}
+	controller.Start()
 	klog.Info("fire...")
 	utils.Wait4CtrlC()
+	klog.Info("stopping...")
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		worker.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		klog.Info("all workers stopped")
+	case <-time.After(cfg.Controller.ShutdownTimeout):
+		klog.Warningf("workers not stopped in %s, exiting", cfg.Controller.ShutdownTimeout)
+	}
 }
 
 func setupRoutes(e *echo.Echo) {
 	ctx.Echo = e
 	e.GET("/metrics", echoprometheus.NewHandlerWithConfig(echoprometheus.HandlerConfig{Gatherer: ctx.Registry}))
+	controller.SetupAdmin(e.Group("/admin"), adminAuth(&envCfg.EchoConfig, cfg.Admin.Token))
+	if cfg.WebSocket.Enable {
+		e.GET(cfg.WebSocket.Path, controller.ServeWebSocket)
+	}
 }
 
 func providerInit() {
 	for _, p := range cfg.Provider {
 		var addingProvider RoomProvider
 		switch p.Type {
-		case "room":
+		case "static", "room": // room is the former type name
 			addingProvider = &StaticConfigProvider{}
 		case "api":
 			addingProvider = &ApiConfigProvider{}
+		default:
+			klog.Fatalf("Unknown provider type: %s", p.Type)
 		}
-		if err := json.Unmarshal(p.RawMessage, &addingProvider); err != nil {
+		if err := p.Decode(addingProvider); err != nil {
 			klog.Fatalf("Cannot unmarshal provider(%s) config: %s", p.Type, err.Error())
 		}
 		if err := addingProvider.Init(ctx); err != nil {
@@ -96,4 +123,12 @@ func providerInit() {
 		}
 		providers = append(providers, addingProvider)
 	}
+	if !slices.ContainsFunc(cfg.Provider, func(p *RoomProviderConfig) bool { return isStaticProvider(p.Type) }) {
+		// revoke rooms claimed by static providers which are all removed from config
+		static := &StaticConfigProvider{}
+		if err := static.Init(ctx); err != nil {
+			klog.Fatalf("Provider init error: %s", err.Error())
+		}
+		providers = append(providers, static)
+	}
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"k8s.io/klog/v2"
+)
+
+// OnStreamGap set the hook receiving gap events of single stream, only called at leader
+func (m *AgentManager) OnStreamGap(hook func(gap *agent.StreamGap)) {
+	m.gapHook = hook
+}
+
+// RoomMaster return the agent that single stream of room followed, empty if no master elected
+func (m *AgentManager) RoomMaster(room uint64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.masters[room]
+}
+
+// MasterRooms return rooms that agent is master of
+func (m *AgentManager) MasterRooms(agentId string) []uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var rooms []uint64
+	for room, master := range m.masters {
+		if master == agentId {
+			rooms = append(rooms, room)
+		}
+	}
+	slices.Sort(rooms)
+	return rooms
+}
+
+// SetMaster reassign the master of room, or all rooms that agent watching if room is 0,
+// agent must be ready, not draining and not paused
+func (m *AgentManager) SetMaster(agentId string, room uint64) error {
+	a, ok := m.snapshot()[agentId]
+	if !ok || !a.Ready || a.Paused {
+		return fmt.Errorf("agent(%s) is not ready", agentId)
+	}
+	rooms := a.Watching
+	if room != 0 {
+		if !slices.Contains(a.Watching, room) || !m.wantRoom(agentId, room) {
+			return fmt.Errorf("agent(%s) is not watching room(%d)", agentId, room)
+		}
+		rooms = []uint64{room}
+	}
+	m.mu.Lock()
+	for _, r := range rooms {
+		m.masters[r] = agentId
+	}
+	m.mu.Unlock()
+	klog.Infof("master of %d rooms reassigned to: %s", len(rooms), agentId)
+	return nil
+}
+
+// reelect masters with the latest agent status, called as soon as an agent is no longer ready
+func (m *AgentManager) reelect() {
+	if !m.leader.IsLeader() {
+		return
+	}
+	m.electMasters(m.rooms.Rooms(), m.snapshot())
+}
+
+// electMasters keep the master of room while it still available,
+// otherwise pick a new one and emit StreamGap if the previous master failed
+func (m *AgentManager) electMasters(rooms []uint64, agents map[string]*agentSnapshot) {
+	var gaps []*agent.StreamGap
+	now := uint64(time.Now().UnixMilli())
+	m.mu.Lock()
+	masters := make(map[uint64]string, len(rooms))
+	for _, room := range rooms {
+		current := m.masters[room]
+		if a, ok := agents[current]; ok && m.eligibleMaster(a, room) {
+			masters[room] = current
+			continue
+		}
+		next := m.pickMaster(room, agents)
+		if next != "" {
+			masters[room] = next
+		}
+		if next == current {
+			continue
+		}
+		klog.Infof("master of room(%d) changed: %q -> %q", room, current, next)
+		if current == "" {
+			continue
+		}
+		if prev, ok := agents[current]; ok && prev.Alive && !prev.Paused {
+			continue // handed over by placement, previous master kept watching until new one ready
+		}
+		gap := &agent.StreamGap{
+			Meta: &agent.BasicMsgMeta{
+				Version:   agent.VERSION,
+				Agent:     next,
+				RoomID:    &room,
+				TimeStamp: now,
+			},
+			PreviousAgent: current,
+		}
+		if prev, ok := agents[current]; ok {
+			gap.LastSeen = prev.LastSeen
+		}
+		gaps = append(gaps, gap)
+	}
+	m.masters = masters
+	m.mu.Unlock()
+	for _, gap := range gaps {
+		klog.Warningf("single stream gap of room(%d), previous master: %s", gap.Meta.GetRoomID(), gap.PreviousAgent)
+		if m.gapHook != nil {
+			m.gapHook(gap)
+		}
+	}
+}
+
+// eligibleMaster agent must be watching room and placed to it, m.mu must be held
+func (m *AgentManager) eligibleMaster(a *agentSnapshot, room uint64) bool {
+	if !a.Alive || a.Paused || !slices.Contains(a.Watching, room) {
+		return false
+	}
+	return !m.Sharded() || slices.Contains(m.placement[room], a.ID)
+}
+
+// pickMaster prefer agents not draining, by placement order if sharded, otherwise by load, m.mu must be held
+func (m *AgentManager) pickMaster(room uint64, agents map[string]*agentSnapshot) string {
+	candidates := make([]*agentSnapshot, 0, len(agents))
+	for _, a := range agents {
+		if a.Alive && !a.Paused && slices.Contains(a.Watching, room) {
+			candidates = append(candidates, a)
+		}
+	}
+	placed := m.placement[room]
+	slices.SortFunc(candidates, func(a, b *agentSnapshot) int {
+		if a.Ready != b.Ready {
+			if a.Ready {
+				return -1
+			}
+			return 1
+		}
+		if m.Sharded() {
+			ia, ib := slices.Index(placed, a.ID), slices.Index(placed, b.ID)
+			if ia < 0 {
+				ia = len(placed)
+			}
+			if ib < 0 {
+				ib = len(placed)
+			}
+			if ia != ib {
+				return cmp.Compare(ia, ib)
+			}
+		}
+		return cmp.Or(cmp.Compare(a.BufferUsed, b.BufferUsed), cmp.Compare(a.ID, b.ID))
+	})
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].ID
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+)
+
+func TestPickMaster(t *testing.T) {
+	cases := []struct {
+		name      string
+		sharded   bool
+		placement []string
+		agents    []*agentSnapshot
+		want      string
+	}{
+		{
+			name: "least buffer used",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Ready: true, Watching: []uint64{1}, BufferUsed: 10},
+				{ID: "b", Alive: true, Ready: true, Watching: []uint64{1}, BufferUsed: 5},
+			},
+			want: "b",
+		},
+		{
+			name: "not draining first",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Watching: []uint64{1}},
+				{ID: "b", Alive: true, Ready: true, Watching: []uint64{1}, BufferUsed: 100},
+			},
+			want: "b",
+		},
+		{
+			name: "paused, dead or not watching excluded",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Ready: true, Paused: true, Watching: []uint64{1}},
+				{ID: "b", Watching: []uint64{1}},
+				{ID: "c", Alive: true, Ready: true, Watching: []uint64{2}},
+				{ID: "d", Alive: true, Watching: []uint64{1}},
+			},
+			want: "d",
+		},
+		{
+			name: "none",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Ready: true, Watching: []uint64{2}},
+			},
+			want: "",
+		},
+		{
+			name:      "placement order",
+			sharded:   true,
+			placement: []string{"b", "a"},
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Ready: true, Watching: []uint64{1}},
+				{ID: "b", Alive: true, Ready: true, Watching: []uint64{1}, BufferUsed: 100},
+				{ID: "c", Alive: true, Ready: true, Watching: []uint64{1}},
+			},
+			want: "b",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newTestAgentManager(0, 0)
+			if c.sharded {
+				m.centerCtx.Config.Controller.ReplicationFactor = len(c.placement)
+				m.placement = map[uint64][]string{1: c.placement}
+			}
+			agents := make(map[string]*agentSnapshot)
+			for _, a := range c.agents {
+				agents[a.ID] = a
+			}
+			if got := m.pickMaster(1, agents); got != c.want {
+				t.Fatalf("unexpected master: %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestElectMasters(t *testing.T) {
+	cases := []struct {
+		name    string
+		current string
+		agents  []*agentSnapshot
+		want    string
+		gap     bool
+	}{
+		{
+			name:    "keep current",
+			current: "a",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Ready: true, Watching: []uint64{1}, BufferUsed: 10},
+				{ID: "b", Alive: true, Ready: true, Watching: []uint64{1}},
+			},
+			want: "a",
+		},
+		{
+			name: "first election",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Ready: true, Watching: []uint64{1}},
+			},
+			want: "a",
+		},
+		{
+			name:    "failover",
+			current: "a",
+			agents: []*agentSnapshot{
+				{ID: "a", Watching: []uint64{1}, LastSeen: 1000},
+				{ID: "b", Alive: true, Ready: true, Watching: []uint64{1}},
+			},
+			want: "b",
+			gap:  true,
+		},
+		{
+			name:    "paused",
+			current: "a",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true, Ready: true, Paused: true, Watching: []uint64{1}},
+				{ID: "b", Alive: true, Ready: true, Watching: []uint64{1}},
+			},
+			want: "b",
+			gap:  true,
+		},
+		{
+			name:    "handover",
+			current: "a",
+			agents: []*agentSnapshot{
+				{ID: "a", Alive: true},
+				{ID: "b", Alive: true, Ready: true, Watching: []uint64{1}},
+			},
+			want: "b",
+		},
+		{
+			name:    "no agent left",
+			current: "a",
+			agents:  []*agentSnapshot{{ID: "a", Watching: []uint64{1}}},
+			gap:     true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newTestAgentManager(0, 0)
+			if c.current != "" {
+				m.masters[1] = c.current
+			}
+			var gaps []*agent.StreamGap
+			m.OnStreamGap(func(gap *agent.StreamGap) { gaps = append(gaps, gap) })
+			agents := make(map[string]*agentSnapshot)
+			for _, a := range c.agents {
+				agents[a.ID] = a
+			}
+			m.electMasters([]uint64{1}, agents)
+			if got := m.RoomMaster(1); got != c.want {
+				t.Fatalf("unexpected master: %q, want %q", got, c.want)
+			}
+			if !c.gap {
+				if len(gaps) != 0 {
+					t.Fatalf("unexpected gap: %+v", gaps[0])
+				}
+				return
+			}
+			if len(gaps) != 1 {
+				t.Fatalf("unexpected gaps: %d", len(gaps))
+			}
+			gap := gaps[0]
+			if gap.PreviousAgent != c.current || gap.Meta.Agent != c.want || gap.Meta.GetRoomID() != 1 || gap.LastSeen != agents[c.current].LastSeen {
+				t.Fatalf("unexpected gap: %+v", gap)
+			}
+		})
+	}
+}
@@ -1,4 +1,239 @@
 package main
 
+import (
+	"strconv"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+)
+
+var (
+	MetricsEventLabelNames = []string{"room_id", "type"}
+	MetricsAgentLabelNames = []string{"agent"}
+	MetricsTraceBuckets    = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1}
+	AgentConditions        = map[string]AgentCondition{
+		"INITIALIZED": AgentInitialization,
+		"READY":       AgentReady,
+		"SYNCED":      AgentSync,
+	}
+)
+
+// MetricsService export controller and agent metrics to CenterContext.Registry,
+// it is a sink of MessageProcessor
 type MetricsService struct {
+	centerCtx *CenterContext
+	agent     *AgentManager
+	channels  map[string]func() (int, int) // name:probe of len,cap
+
+	// event metrics
+	mEventsReceived   *prometheus.CounterVec
+	mEventsDuplicated *prometheus.CounterVec
+	mEventsAccepted   *prometheus.CounterVec
+	mTraceWait        *prometheus.HistogramVec
+	mTraceProcess     *prometheus.HistogramVec
+	// backpressure metrics
+	mChannelUsed      *prometheus.GaugeVec
+	mChannelCapacity  *prometheus.GaugeVec
+	mChannelSaturated *prometheus.CounterVec
+	// agent metrics
+	mAgentCondition        *prometheus.GaugeVec
+	mAgentHits             *prometheus.GaugeVec
+	mAgentHitRatio         *prometheus.GaugeVec
+	mAgentWatching         *prometheus.GaugeVec
+	mAgentBufferUsed       *prometheus.GaugeVec
+	mAgentBufferEventCount *prometheus.GaugeVec
+	mAgentBufferSize       *prometheus.GaugeVec
+	mAgentBufferDropped    *prometheus.GaugeVec
+	mAgentWorkerBacklog    *prometheus.GaugeVec
+	mAgentSaturation       *prometheus.GaugeVec
+	mAgentCacheBuffer      *prometheus.GaugeVec
+	mAgentCacheCached      *prometheus.GaugeVec
+	mAgentCacheHits        *prometheus.GaugeVec
+	mAgentCacheMisses      *prometheus.GaugeVec
+	mAgentCacheDelHits     *prometheus.GaugeVec
+	mAgentCacheDelMisses   *prometheus.GaugeVec
+	mAgentCacheCollisions  *prometheus.GaugeVec
+	mAgentCacheDropped     *prometheus.GaugeVec
+}
+
+func (s *MetricsService) Init(ctx *CenterContext, agentManager *AgentManager) {
+	s.centerCtx = ctx
+	s.agent = agentManager
+	s.channels = make(map[string]func() (int, int))
+	s.mEventsReceived = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "blive_damaku_events_received_total", Help: "events received from all agents"}, MetricsEventLabelNames)
+	s.mEventsDuplicated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "blive_damaku_events_duplicated_total", Help: "events filtered by duplicate filter"}, MetricsEventLabelNames)
+	s.mEventsAccepted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "blive_damaku_events_accepted_total", Help: "deduplicated events passed to processor"}, MetricsEventLabelNames)
+	s.mTraceWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: "blive_damaku_trace_wait_seconds", Help: "agent wait time before process", Buckets: MetricsTraceBuckets}, []string{"agent", "type"})
+	s.mTraceProcess = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: "blive_damaku_trace_process_seconds", Help: "agent process time", Buckets: MetricsTraceBuckets}, []string{"agent", "type"})
+	s.mChannelUsed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_channel_used"}, []string{"channel"})
+	s.mChannelCapacity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_channel_capacity"}, []string{"channel"})
+	s.mChannelSaturated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "blive_damaku_channel_saturated_total", Help: "sends blocked by full channel"}, []string{"channel"})
+	s.mAgentCondition = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_condition", Help: "units bool"}, []string{"agent", "condition"})
+	s.mAgentHits = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_hits", Help: "released duplicate window that agent took part in"}, []string{"agent", "type"})
+	s.mAgentHitRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_hit_ratio", Help: "agent hits / all released duplicate window"}, []string{"agent", "type"})
+	s.mAgentWatching = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_watching", Help: "cached watching rooms"}, MetricsAgentLabelNames)
+	s.mAgentBufferUsed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_buffer_used"}, MetricsAgentLabelNames)
+	s.mAgentBufferEventCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_buffer_event_count"}, []string{"agent", "type"})
+	s.mAgentBufferSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_buffer_size"}, MetricsAgentLabelNames)
+	s.mAgentBufferDropped = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_buffer_dropped", Help: "events dropped by full buffer since agent start"}, []string{"agent", "type"})
+	s.mAgentWorkerBacklog = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_worker_backlog", Help: "events waiting for event handler worker"}, []string{"agent", "worker"})
+	s.mAgentSaturation = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_saturation", Help: "buffer usage of the busiest event handler worker"}, MetricsAgentLabelNames)
+	cacheLabels := []string{"agent", "cache"}
+	s.mAgentCacheBuffer = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_buffer", Help: "meta indexer queue"}, cacheLabels)
+	s.mAgentCacheCached = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_cached"}, cacheLabels)
+	s.mAgentCacheHits = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_hits"}, cacheLabels)
+	s.mAgentCacheMisses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_misses"}, cacheLabels)
+	s.mAgentCacheDelHits = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_del_hits"}, cacheLabels)
+	s.mAgentCacheDelMisses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_del_misses"}, cacheLabels)
+	s.mAgentCacheCollisions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_collisions"}, cacheLabels)
+	s.mAgentCacheDropped = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_agent_meta_cache_dropped", Help: "meta dropped by full queue since agent start"}, cacheLabels)
+	ctx.Registry.MustRegister(s.mEventsReceived, s.mEventsDuplicated, s.mEventsAccepted, s.mTraceWait, s.mTraceProcess,
+		s.mChannelUsed, s.mChannelCapacity, s.mChannelSaturated,
+		s.mAgentCondition, s.mAgentHits, s.mAgentHitRatio, s.mAgentWatching, s.mAgentBufferUsed, s.mAgentBufferEventCount,
+		s.mAgentBufferSize, s.mAgentBufferDropped, s.mAgentWorkerBacklog, s.mAgentSaturation, s.mAgentCacheBuffer, s.mAgentCacheCached, s.mAgentCacheHits, s.mAgentCacheMisses, s.mAgentCacheDelHits,
+		s.mAgentCacheDelMisses, s.mAgentCacheCollisions, s.mAgentCacheDropped)
+}
+
+func (s *MetricsService) Start() {
+	klog.Infof("starting metrics service")
+	s.centerCtx.Worker.Go(s.collector)
+}
+
+// WatchChannel add a probe of channel len and cap, only available before Start
+func (s *MetricsService) WatchChannel(name string, probe func() (int, int)) {
+	s.channels[name] = probe
+}
+
+// Saturated count a send that blocked by full channel
+func (s *MetricsService) Saturated(channel string) {
+	s.mChannelSaturated.WithLabelValues(channel).Inc()
+}
+
+// Received count an event received from agent, before duplicate filter
+func (s *MetricsService) Received(eventType string, roomId uint64) {
+	s.mEventsReceived.WithLabelValues(strconv.FormatUint(roomId, 10), eventType).Inc()
+}
+
+// Duplicated count an event filtered by duplicate filter
+func (s *MetricsService) Duplicated(eventType string, roomId uint64) {
+	s.mEventsDuplicated.WithLabelValues(strconv.FormatUint(roomId, 10), eventType).Inc()
+}
+
+// Handle count accepted event and observe trace from agent
+func (s *MetricsService) Handle(event any) bool {
+	eventType := EventTypeOf(event)
+	meta := eventMeta(event)
+	s.mEventsAccepted.WithLabelValues(strconv.FormatUint(meta.GetRoomID(), 10), eventType).Inc()
+	if meta == nil {
+		return true
+	}
+	if wait, ok := meta.Trace[int32(agent.BasicMsgMeta_Wait)]; ok {
+		s.mTraceWait.WithLabelValues(meta.Agent, eventType).Observe(float64(wait) / 1e6)
+	}
+	if process, ok := meta.Trace[int32(agent.BasicMsgMeta_Process)]; ok {
+		s.mTraceProcess.WithLabelValues(meta.Agent, eventType).Observe(float64(process) / 1e6)
+	}
+	return true
+}
+
+// collect agent status by time
+func (s *MetricsService) collector() {
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+	klog.Info("[Metrics]collector start")
+	for {
+		select {
+		case <-ticker.C:
+			s.collectChannels()
+			s.collectAgents()
+		case <-s.centerCtx.Context.Done():
+			klog.Info("[Metrics]collector stopped")
+			return
+		}
+	}
+}
+
+func (s *MetricsService) collectChannels() {
+	for name, probe := range s.channels {
+		used, capacity := probe()
+		s.mChannelUsed.WithLabelValues(name).Set(float64(used))
+		s.mChannelCapacity.WithLabelValues(name).Set(float64(capacity))
+	}
+}
+
+func (s *MetricsService) collectAgents() {
+	s.agent.managed.Range(func(_, value any) bool {
+		a := value.(*AgentStatus)
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+		for name, condition := range AgentConditions {
+			v := 0.0
+			if a.Condition&condition > 0 {
+				v = 1
+			}
+			s.mAgentCondition.WithLabelValues(a.ID, name).Set(v)
+		}
+		for category, hits := range a.HitStatus {
+			s.mAgentHits.WithLabelValues(a.ID, category).Set(float64(hits))
+			if total := s.agent.HitTotal(category); total > 0 {
+				s.mAgentHitRatio.WithLabelValues(a.ID, category).Set(float64(hits) / float64(total))
+			}
+		}
+		if a.CachedStatus == nil {
+			return true
+		}
+		s.mAgentWatching.WithLabelValues(a.ID).Set(float64(len(a.CachedStatus.Watching)))
+		s.mAgentBufferUsed.WithLabelValues(a.ID).Set(float64(a.CachedStatus.BufferUsed))
+		s.mAgentBufferSize.WithLabelValues(a.ID).Set(float64(a.CachedStatus.BufferSize))
+		for workerId, backlog := range a.CachedStatus.WorkerBacklog {
+			s.mAgentWorkerBacklog.WithLabelValues(a.ID, strconv.Itoa(workerId)).Set(float64(backlog))
+		}
+		s.mAgentSaturation.WithLabelValues(a.ID).Set(a.CachedStatus.Saturation())
+		for bufferType, dropped := range a.CachedStatus.BufferDropped {
+			s.mAgentBufferDropped.WithLabelValues(a.ID, agent.AgentStatus_BufferType(bufferType).String()).Set(float64(dropped))
+		}
+		for bufferType, count := range a.CachedStatus.BufferEventCount {
+			s.mAgentBufferEventCount.WithLabelValues(a.ID, agent.AgentStatus_BufferType(bufferType).String()).Set(float64(count))
+		}
+		for cacheType, info := range a.CachedStatus.MetaCache {
+			labels := []string{a.ID, agent.AgentStatus_MetaCacheType(cacheType).String()}
+			s.mAgentCacheBuffer.WithLabelValues(labels...).Set(float64(info.Buffer))
+			s.mAgentCacheCached.WithLabelValues(labels...).Set(float64(info.Cached))
+			s.mAgentCacheHits.WithLabelValues(labels...).Set(float64(info.Hits))
+			s.mAgentCacheMisses.WithLabelValues(labels...).Set(float64(info.Misses))
+			s.mAgentCacheDelHits.WithLabelValues(labels...).Set(float64(info.DelHits))
+			s.mAgentCacheDelMisses.WithLabelValues(labels...).Set(float64(info.DelMisses))
+			s.mAgentCacheCollisions.WithLabelValues(labels...).Set(float64(info.Collisions))
+			s.mAgentCacheDropped.WithLabelValues(labels...).Set(float64(info.Dropped))
+		}
+		return true
+	})
 }
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"time"
+)
+
+// hypertables partitioned by time column, created at StorageController migrate
+var hypertables = []any{
+	&DamakuRecord{},
+	&GiftRecord{},
+	&GuardRecord{},
+	&SuperChatRecord{},
+	&OnlineRankCountRecord{},
+	&OnlineRankV2Record{},
+	&InteractRecord{},
+	&LikeCountRecord{},
+	&RoomChangeRecord{},
+	&LiveStatusRecord{},
+	&WatchedCountRecord{},
+	&FansCountRecord{},
+	&StreamGapRecord{},
+	&UserHistoryRecord{},
+	&FansMedalHistoryRecord{},
+}
+
+// dimension tables keep the current state, upsert by primary key
+var dimensionTables = []any{
+	&UserRecord{},
+	&FansMedalRecord{},
+	&LiveSessionRecord{},
+}
+
+type DamakuRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_damaku_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_damaku_room_time,priority:1"`
+	UID       uint64    `gorm:"not null;index"`
+	Content   string
+	Medal     uint64 // target user id
+	SessionID string // live session, empty if room not in live
+}
+
+func (*DamakuRecord) TableName() string {
+	return "bilive_damaku"
+}
+
+type GiftRecord struct {
+	Time             time.Time `gorm:"not null;index:idx_gift_room_time,priority:2,sort:desc"`
+	RoomID           uint64    `gorm:"not null;index:idx_gift_room_time,priority:1"`
+	TID              uint64    `gorm:"not null"` // gift msg id
+	UID              uint64    `gorm:"not null;index"`
+	Count            uint32
+	GiftID           uint32
+	GiftName         string
+	Price            uint32 // gold_seeds
+	OriginalGiftID   uint32
+	OriginalGiftName string
+	OriginalPrice    uint32 // gold_seeds
+	Medal            uint64
+	SessionID        string // live session, empty if room not in live
+}
+
+func (*GiftRecord) TableName() string {
+	return "bilive_gift"
+}
+
+type GuardRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_guard_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_guard_room_time,priority:1"`
+	UID       uint64    `gorm:"not null;index"`
+	Price     uint32    // gold_seeds
+	GiftType  int32     // agent.Guard_GuardGiftType
+	SessionID string    // live session, empty if room not in live
+}
+
+func (*GuardRecord) TableName() string {
+	return "bilive_guard"
+}
+
+type SuperChatRecord struct {
+	Time         time.Time `gorm:"not null;index:idx_super_chat_room_time,priority:2,sort:desc"`
+	RoomID       uint64    `gorm:"not null;index:idx_super_chat_room_time,priority:1"`
+	ID           uint64    `gorm:"not null"` // sc id
+	UID          uint64    `gorm:"not null;index"`
+	Message      string
+	MessageTrans string
+	Price        uint32 // RMB
+	Medal        uint64
+	SessionID    string // live session, empty if room not in live
+}
+
+func (*SuperChatRecord) TableName() string {
+	return "bilive_super_chat"
+}
+
+type OnlineRankCountRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_online_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_online_room_time,priority:1"`
+	Count     uint32
+	Online    uint32
+	SessionID string // live session, empty if room not in live
+}
+
+func (*OnlineRankCountRecord) TableName() string {
+	return "bilive_online_rank_count"
+}
+
+// OnlineRankV2Record is one rank entry of agent.OnlineRankV2 list
+type OnlineRankV2Record struct {
+	Time       time.Time `gorm:"not null;index:idx_online_v2_room_time,priority:2,sort:desc"`
+	RoomID     uint64    `gorm:"not null;index:idx_online_v2_room_time,priority:1"`
+	Rank       uint32
+	Score      uint32
+	UID        uint64
+	GuardLevel int32  // agent.GuardLevelType
+	SessionID  string // live session, empty if room not in live
+}
+
+func (*OnlineRankV2Record) TableName() string {
+	return "bilive_online_rank_v2"
+}
+
+type InteractRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_interact_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_interact_room_time,priority:1"`
+	UID       uint64    `gorm:"not null;index"`
+	Type      int32     // agent.Interact_InteractType
+	Medal     uint64
+	SessionID string // live session, empty if room not in live
+}
+
+func (*InteractRecord) TableName() string {
+	return "bilive_interact"
+}
+
+type LikeCountRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_like_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_like_room_time,priority:1"`
+	Count     uint32
+	SessionID string // live session, empty if room not in live
+}
+
+func (*LikeCountRecord) TableName() string {
+	return "bilive_like_count"
+}
+
+type RoomChangeRecord struct {
+	Time           time.Time `gorm:"not null;index:idx_room_change_room_time,priority:2,sort:desc"`
+	RoomID         uint64    `gorm:"not null;index:idx_room_change_room_time,priority:1"`
+	Title          string
+	AreaID         uint32
+	AreaName       string
+	ParentAreaID   uint32
+	ParentAreaName string
+	SessionID      string // live session, empty if room not in live
+}
+
+func (*RoomChangeRecord) TableName() string {
+	return "bilive_room_change"
+}
+
+type LiveStatusRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_live_status_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_live_status_room_time,priority:1"`
+	Live      bool
+	LiveTime  *time.Time // nil if unknown
+	LiveKey   string
+	SessionID string // live session, empty if room not in live
+}
+
+func (*LiveStatusRecord) TableName() string {
+	return "bilive_live_status"
+}
+
+type WatchedCountRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_watched_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_watched_room_time,priority:1"`
+	Count     uint32
+	SessionID string // live session, empty if room not in live
+}
+
+func (*WatchedCountRecord) TableName() string {
+	return "bilive_watched_count"
+}
+
+type FansCountRecord struct {
+	Time      time.Time `gorm:"not null;index:idx_fans_room_time,priority:2,sort:desc"`
+	RoomID    uint64    `gorm:"not null;index:idx_fans_room_time,priority:1"`
+	Fans      uint32
+	FansClub  uint32
+	SessionID string // live session, empty if room not in live
+}
+
+func (*FansCountRecord) TableName() string {
+	return "bilive_fans_count"
+}
+
+// StreamGapRecord mark a hole of online rank series between LastSeen and Time, caused by master agent failover
+type StreamGapRecord struct {
+	Time          time.Time `gorm:"not null;index:idx_stream_gap_room_time,priority:2,sort:desc"`
+	RoomID        uint64    `gorm:"not null;index:idx_stream_gap_room_time,priority:1"`
+	Agent         string    // new master, empty if no agent available
+	PreviousAgent string
+	LastSeen      time.Time
+}
+
+func (*StreamGapRecord) TableName() string {
+	return "bilive_stream_gap"
+}
+
+// UserRecord is the current profile of user, upsert by UID
+type UserRecord struct {
+	UID         uint64 `gorm:"primaryKey;autoIncrement:false"`
+	UserName    string
+	Face        *string
+	Level       *uint32
+	WealthLevel *uint32
+	UpdatedAt   time.Time `gorm:"not null"`
+}
+
+func (*UserRecord) TableName() string {
+	return "bilive_user"
+}
+
+// UserHistoryRecord is every changed profile of user,
+// profile at the time of an event is the latest record that Time <= event time
+type UserHistoryRecord struct {
+	Time        time.Time `gorm:"not null;index:idx_user_history_uid_time,priority:2,sort:desc"`
+	UID         uint64    `gorm:"not null;index:idx_user_history_uid_time,priority:1"`
+	UserName    string
+	Face        *string
+	Level       *uint32
+	WealthLevel *uint32
+}
+
+func (*UserHistoryRecord) TableName() string {
+	return "bilive_user_history"
+}
+
+// FansMedalRecord is the current medal of user at target room user, upsert by (UID, RoomUID)
+type FansMedalRecord struct {
+	UID        uint64 `gorm:"primaryKey;autoIncrement:false"`
+	RoomUID    uint64 `gorm:"primaryKey;autoIncrement:false"` // target user id
+	Name       string
+	Level      uint32
+	Light      bool
+	GuardLevel int32     // agent.GuardLevelType
+	UpdatedAt  time.Time `gorm:"not null"`
+}
+
+func (*FansMedalRecord) TableName() string {
+	return "bilive_fans_medal"
+}
+
+// FansMedalHistoryRecord is every changed medal of user at target room user
+type FansMedalHistoryRecord struct {
+	Time       time.Time `gorm:"not null;index:idx_fans_medal_history_uid_time,priority:3,sort:desc"`
+	UID        uint64    `gorm:"not null;index:idx_fans_medal_history_uid_time,priority:1"`
+	RoomUID    uint64    `gorm:"not null;index:idx_fans_medal_history_uid_time,priority:2"`
+	Name       string
+	Level      uint32
+	Light      bool
+	GuardLevel int32
+}
+
+func (*FansMedalHistoryRecord) TableName() string {
+	return "bilive_fans_medal_history"
+}
+
+// LiveSessionRecord is a live of room from LIVE to PREPARING, upsert by ID
+type LiveSessionRecord struct {
+	ID               string `gorm:"primaryKey"` // live key, [room]-[start MilliTimestamp] if unknown
+	RoomID           uint64 `gorm:"not null;index:idx_live_session_room_start,priority:1"`
+	LiveKey          string
+	StartTime        time.Time  `gorm:"not null;index:idx_live_session_room_start,priority:2,sort:desc"`
+	EndTime          *time.Time // nil if still in live
+	EndReason        string     // preparing, timeout or restart
+	Title            string
+	AreaID           uint32
+	AreaName         string
+	ParentAreaID     uint32
+	ParentAreaName   string
+	PeakOnline       uint32
+	Damaku           uint64
+	GiftRevenue      uint64 // gold_seeds
+	GuardCount       uint32
+	GuardRevenue     uint64 // gold_seeds
+	SuperChatCount   uint32
+	SuperChatRevenue uint64    // RMB
+	LastEventTime    time.Time // time of the latest event, end of session if timeout
+	UpdatedAt        time.Time `gorm:"not null"` // last change of session
+}
+
+func (*LiveSessionRecord) TableName() string {
+	return "bilive_live_session"
+}
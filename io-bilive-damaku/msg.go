@@ -1,4 +1,233 @@
 package main
 
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"k8s.io/klog/v2"
+)
+
+// event types, same as the stream subject suffix
+const (
+	EventFansMedal  = "fansMedal"
+	EventUserInfo   = "userInfoMeta"
+	EventDamaku     = "damaku"
+	EventGift       = "gift"
+	EventGuard      = "guard"
+	EventSuperChat  = "superChat"
+	EventOnline     = "online"
+	EventOnlineV2   = "onlineV2"
+	EventInteract   = "interact"
+	EventLike       = "like"
+	EventRoomChange = "roomChange"
+	EventLiveStatus = "liveStatus"
+	EventWatched    = "watched"
+	EventFans       = "fans"
+	EventStreamGap  = "streamGap" // emitted by controller, not from stream
+)
+
+var (
+	AllEventTypes = []string{EventFansMedal, EventUserInfo, EventDamaku, EventGift, EventGuard, EventSuperChat, EventOnline, EventOnlineV2,
+		EventInteract, EventLike, EventRoomChange, EventLiveStatus, EventWatched, EventFans, EventStreamGap}
+	StreamEventTypes = []string{EventDamaku, EventGift, EventGuard, EventSuperChat, EventOnline, EventOnlineV2,
+		EventInteract, EventLike, EventRoomChange, EventLiveStatus, EventWatched, EventFans}
+	MetaEventTypes = []string{EventFansMedal, EventUserInfo}
+)
+
+// EventTypeOf return the event type of event from eventChan, empty if unknown
+func EventTypeOf(event any) string {
+	switch event.(type) {
+	case *agent.FansMedalMeta:
+		return EventFansMedal
+	case *agent.UserInfoMeta:
+		return EventUserInfo
+	case *agent.Damaku:
+		return EventDamaku
+	case *agent.Gift:
+		return EventGift
+	case *agent.Guard:
+		return EventGuard
+	case *agent.SuperChat:
+		return EventSuperChat
+	case *agent.OnlineRankCount:
+		return EventOnline
+	case *agent.OnlineRankV2:
+		return EventOnlineV2
+	case *agent.Interact:
+		return EventInteract
+	case *agent.LikeCount:
+		return EventLike
+	case *agent.RoomChange:
+		return EventRoomChange
+	case *agent.LiveStatus:
+		return EventLiveStatus
+	case *agent.WatchedCount:
+		return EventWatched
+	case *agent.FansCount:
+		return EventFans
+	case *agent.StreamGap:
+		return EventStreamGap
+	}
+	return ""
+}
+
+// eventTime of meta, SuperChat from old agents and recorded files is in seconds instead of MilliTimestamp
+func eventTime(meta *agent.BasicMsgMeta) time.Time {
+	ts := meta.GetTimeStamp()
+	if ts < 1e12 {
+		ts *= 1000
+	}
+	return time.UnixMilli(int64(ts))
+}
+
+// eventMeta return the basic meta of stream event, nil for meta events
+func eventMeta(event any) *agent.BasicMsgMeta {
+	if e, ok := event.(interface{ GetMeta() *agent.BasicMsgMeta }); ok {
+		return e.GetMeta()
+	}
+	return nil
+}
+
+// EventHandler is a stage of MessageProcessor
+type EventHandler interface {
+	// Handle an event, return false to stop passing it to the following handlers.
+	// The event will be recycled after all handlers done, so handler must not hold it
+	Handle(event any) bool
+}
+
+// EventHandlerFunc adapt a function to EventHandler
+type EventHandlerFunc func(event any) bool
+
+func (f EventHandlerFunc) Handle(event any) bool {
+	return f(event)
+}
+
+// HandlerStage decide the order of handlers for an event type,
+// handlers in same stage run in registration order
+type HandlerStage int
+
+const (
+	StageFilter   = HandlerStage(iota) // drop unwanted event
+	StageEnricher                      // modify event
+	StageSink                          // consume event, e.g. storage, metrics, publishing
+)
+
+type registeredHandler struct {
+	name    string
+	stage   HandlerStage
+	handler EventHandler
+}
+
+// MessageProcessor consume eventChan and pass every event through handlers registered for its type,
+// then return event to recycleChan
 type MessageProcessor struct {
+	centerCtx   *CenterContext
+	eventChan   <-chan any
+	recycleChan chan<- any
+	producers   *sync.WaitGroup                 // senders of eventChan, waited before the last drain at shutdown
+	handlers    map[string][]*registeredHandler // eventType:handlers
+	stopped     chan struct{}                   // closed after pipeline stopped, no more events are received or recycled
+
+	// running flag
+	started atomic.Bool
+}
+
+func (p *MessageProcessor) Init(ctx *CenterContext, eventChan <-chan any, recycleChan chan<- any, producers *sync.WaitGroup) {
+	p.centerCtx = ctx
+	p.eventChan = eventChan
+	p.recycleChan = recycleChan
+	p.producers = producers
+	p.handlers = make(map[string][]*registeredHandler)
+	p.stopped = make(chan struct{})
+}
+
+// Stopped is closed after the events left in eventChan are handled at shutdown,
+// senders of eventChan and receivers of recycleChan should not wait for processor after that
+func (p *MessageProcessor) Stopped() <-chan struct{} {
+	return p.stopped
+}
+
+// Register a handler for event types, all event types if no type provided.
+// Only available before Start
+func (p *MessageProcessor) Register(name string, stage HandlerStage, handler EventHandler, eventTypes ...string) {
+	if p.started.Load() {
+		klog.Warningf("[Processor]handler(%s) cannot be registered after started", name)
+		return
+	}
+	if len(eventTypes) == 0 {
+		eventTypes = AllEventTypes
+	}
+	h := &registeredHandler{name: name, stage: stage, handler: handler}
+	for _, t := range eventTypes {
+		p.handlers[t] = append(p.handlers[t], h)
+		slices.SortStableFunc(p.handlers[t], func(a, b *registeredHandler) int {
+			return int(a.stage) - int(b.stage)
+		})
+	}
+	klog.Infof("[Processor]handler registered: %s%v", name, eventTypes)
+}
+
+func (p *MessageProcessor) Start() {
+	if !p.started.CompareAndSwap(false, true) {
+		klog.Warningf("message processor already started")
+		return
+	}
+	klog.Infof("starting message processor")
+	p.centerCtx.Worker.Go(p.process)
+}
+
+// keep events in order, no need to parallelization
+func (p *MessageProcessor) process() {
+	klog.Info("[Processor]pipeline start")
+	defer close(p.stopped)
+	for {
+		select {
+		case event := <-p.eventChan:
+			p.handle(event)
+		case <-p.centerCtx.Context.Done():
+			p.drain()
+			klog.Info("[Processor]pipeline stopped")
+			return
+		}
+	}
+}
+
+// drain handle events until producers stopped, then the events left in eventChan,
+// so no event already acked from JetStream is lost at graceful shutdown
+func (p *MessageProcessor) drain() {
+	produced := make(chan struct{})
+	go func() {
+		p.producers.Wait()
+		close(produced)
+	}()
+	for {
+		select {
+		case event := <-p.eventChan:
+			p.handle(event)
+		case <-produced:
+			for {
+				select {
+				case event := <-p.eventChan:
+					p.handle(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle pass event through handlers, recycler keeps running until processor stopped
+func (p *MessageProcessor) handle(event any) {
+	eventType := EventTypeOf(event)
+	for _, h := range p.handlers[eventType] {
+		if !h.handler.Handle(event) {
+			klog.V(5).Infof("[Processor]%s event stopped by %s", eventType, h.name)
+			break
+		}
+	}
+	p.recycleChan <- event
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+)
+
+func TestProcessorDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	eventChan := make(chan any, 4)
+	recycleChan := make(chan any, 4)
+	p := &MessageProcessor{}
+	p.Init(&CenterContext{Context: ctx, Config: NewConfig()}, eventChan, recycleChan, &sync.WaitGroup{})
+	var handled int
+	p.Register("count", StageSink, EventHandlerFunc(func(any) bool {
+		handled++
+		return true
+	}), EventDamaku)
+	for i := 0; i < 3; i++ {
+		eventChan <- &agent.Damaku{}
+	}
+	// events left in eventChan are handled even if context is done before running
+	cancel()
+	p.process()
+	select {
+	case <-p.Stopped():
+	case <-time.After(time.Second):
+		t.Fatal("processor not stopped")
+	}
+	if handled != 3 || len(recycleChan) != 3 {
+		t.Fatalf("unexpected handled: %d, recycled: %d", handled, len(recycleChan))
+	}
+}
+
+func TestProcessorWaitProducers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	eventChan := make(chan any, 1)
+	recycleChan := make(chan any, 8)
+	producers := &sync.WaitGroup{}
+	p := &MessageProcessor{}
+	p.Init(&CenterContext{Context: ctx, Config: NewConfig()}, eventChan, recycleChan, producers)
+	var handled int
+	p.Register("count", StageSink, EventHandlerFunc(func(any) bool {
+		handled++
+		return true
+	}), EventDamaku)
+	cancel()
+	// producer still sending after context done, blocked on full eventChan until drained
+	producers.Add(1)
+	go func() {
+		defer producers.Done()
+		for i := 0; i < 5; i++ {
+			eventChan <- &agent.Damaku{}
+		}
+	}()
+	p.process()
+	if handled != 5 {
+		t.Fatalf("unexpected handled: %d", handled)
+	}
+}
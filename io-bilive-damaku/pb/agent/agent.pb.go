@@ -171,8 +171,12 @@ func (AgentInfo_AgentType) EnumDescriptor() ([]byte, []int) {
 type AgentAction_AgentActionType int32
 
 const (
-	AgentAction_AddRoom AgentAction_AgentActionType = 0
-	AgentAction_DelRoom AgentAction_AgentActionType = 1
+	AgentAction_AddRoom  AgentAction_AgentActionType = 0
+	AgentAction_DelRoom  AgentAction_AgentActionType = 1
+	AgentAction_Drain    AgentAction_AgentActionType = 2 // stop accepting rooms, rooms will be moved to other agents
+	AgentAction_Shutdown AgentAction_AgentActionType = 3 // unsubscribe all rooms, flush buffers, publish final status and exit
+	AgentAction_Pause    AgentAction_AgentActionType = 4 // stop forwarding events, rooms keep connected
+	AgentAction_Resume   AgentAction_AgentActionType = 5 // cancel Drain or Pause
 )
 
 // Enum value maps for AgentAction_AgentActionType.
@@ -180,10 +184,18 @@ var (
 	AgentAction_AgentActionType_name = map[int32]string{
 		0: "AddRoom",
 		1: "DelRoom",
+		2: "Drain",
+		3: "Shutdown",
+		4: "Pause",
+		5: "Resume",
 	}
 	AgentAction_AgentActionType_value = map[string]int32{
-		"AddRoom": 0,
-		"DelRoom": 1,
+		"AddRoom":  0,
+		"DelRoom":  1,
+		"Drain":    2,
+		"Shutdown": 3,
+		"Pause":    4,
+		"Resume":   5,
 	}
 )
 
@@ -211,7 +223,59 @@ func (x AgentAction_AgentActionType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use AgentAction_AgentActionType.Descriptor instead.
 func (AgentAction_AgentActionType) EnumDescriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{3, 0}
+	return file_pb_agent_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type AgentStatus_AgentState int32
+
+const (
+	AgentStatus_Running  AgentStatus_AgentState = 0
+	AgentStatus_Paused   AgentStatus_AgentState = 1
+	AgentStatus_Draining AgentStatus_AgentState = 2
+	AgentStatus_Stopped  AgentStatus_AgentState = 3 // final status before agent exit
+)
+
+// Enum value maps for AgentStatus_AgentState.
+var (
+	AgentStatus_AgentState_name = map[int32]string{
+		0: "Running",
+		1: "Paused",
+		2: "Draining",
+		3: "Stopped",
+	}
+	AgentStatus_AgentState_value = map[string]int32{
+		"Running":  0,
+		"Paused":   1,
+		"Draining": 2,
+		"Stopped":  3,
+	}
+)
+
+func (x AgentStatus_AgentState) Enum() *AgentStatus_AgentState {
+	p := new(AgentStatus_AgentState)
+	*p = x
+	return p
+}
+
+func (x AgentStatus_AgentState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AgentStatus_AgentState) Descriptor() protoreflect.EnumDescriptor {
+	return file_pb_agent_proto_enumTypes[4].Descriptor()
+}
+
+func (AgentStatus_AgentState) Type() protoreflect.EnumType {
+	return &file_pb_agent_proto_enumTypes[4]
+}
+
+func (x AgentStatus_AgentState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AgentStatus_AgentState.Descriptor instead.
+func (AgentStatus_AgentState) EnumDescriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{5, 0}
 }
 
 type AgentStatus_BufferType int32
@@ -223,17 +287,31 @@ const (
 	AgentStatus_SuperChat    AgentStatus_BufferType = 3
 	AgentStatus_OnlineRank   AgentStatus_BufferType = 4
 	AgentStatus_OnlineRankV2 AgentStatus_BufferType = 5
+	AgentStatus_Interact     AgentStatus_BufferType = 6
+	AgentStatus_Like         AgentStatus_BufferType = 7
+	AgentStatus_RoomChange   AgentStatus_BufferType = 8
+	AgentStatus_Live         AgentStatus_BufferType = 9
+	AgentStatus_Preparing    AgentStatus_BufferType = 10
+	AgentStatus_Watched      AgentStatus_BufferType = 11
+	AgentStatus_RoomRealTime AgentStatus_BufferType = 12
 )
 
 // Enum value maps for AgentStatus_BufferType.
 var (
 	AgentStatus_BufferType_name = map[int32]string{
-		0: "Damaku",
-		1: "Gift",
-		2: "Guard",
-		3: "SuperChat",
-		4: "OnlineRank",
-		5: "OnlineRankV2",
+		0:  "Damaku",
+		1:  "Gift",
+		2:  "Guard",
+		3:  "SuperChat",
+		4:  "OnlineRank",
+		5:  "OnlineRankV2",
+		6:  "Interact",
+		7:  "Like",
+		8:  "RoomChange",
+		9:  "Live",
+		10: "Preparing",
+		11: "Watched",
+		12: "RoomRealTime",
 	}
 	AgentStatus_BufferType_value = map[string]int32{
 		"Damaku":       0,
@@ -242,6 +320,13 @@ var (
 		"SuperChat":    3,
 		"OnlineRank":   4,
 		"OnlineRankV2": 5,
+		"Interact":     6,
+		"Like":         7,
+		"RoomChange":   8,
+		"Live":         9,
+		"Preparing":    10,
+		"Watched":      11,
+		"RoomRealTime": 12,
 	}
 )
 
@@ -256,11 +341,11 @@ func (x AgentStatus_BufferType) String() string {
 }
 
 func (AgentStatus_BufferType) Descriptor() protoreflect.EnumDescriptor {
-	return file_pb_agent_proto_enumTypes[4].Descriptor()
+	return file_pb_agent_proto_enumTypes[5].Descriptor()
 }
 
 func (AgentStatus_BufferType) Type() protoreflect.EnumType {
-	return &file_pb_agent_proto_enumTypes[4]
+	return &file_pb_agent_proto_enumTypes[5]
 }
 
 func (x AgentStatus_BufferType) Number() protoreflect.EnumNumber {
@@ -269,7 +354,7 @@ func (x AgentStatus_BufferType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use AgentStatus_BufferType.Descriptor instead.
 func (AgentStatus_BufferType) EnumDescriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{4, 0}
+	return file_pb_agent_proto_rawDescGZIP(), []int{5, 1}
 }
 
 type AgentStatus_MetaCacheType int32
@@ -302,11 +387,11 @@ func (x AgentStatus_MetaCacheType) String() string {
 }
 
 func (AgentStatus_MetaCacheType) Descriptor() protoreflect.EnumDescriptor {
-	return file_pb_agent_proto_enumTypes[5].Descriptor()
+	return file_pb_agent_proto_enumTypes[6].Descriptor()
 }
 
 func (AgentStatus_MetaCacheType) Type() protoreflect.EnumType {
-	return &file_pb_agent_proto_enumTypes[5]
+	return &file_pb_agent_proto_enumTypes[6]
 }
 
 func (x AgentStatus_MetaCacheType) Number() protoreflect.EnumNumber {
@@ -315,7 +400,7 @@ func (x AgentStatus_MetaCacheType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use AgentStatus_MetaCacheType.Descriptor instead.
 func (AgentStatus_MetaCacheType) EnumDescriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{4, 1}
+	return file_pb_agent_proto_rawDescGZIP(), []int{5, 2}
 }
 
 type BasicMsgMeta_TraceStep int32
@@ -348,11 +433,11 @@ func (x BasicMsgMeta_TraceStep) String() string {
 }
 
 func (BasicMsgMeta_TraceStep) Descriptor() protoreflect.EnumDescriptor {
-	return file_pb_agent_proto_enumTypes[6].Descriptor()
+	return file_pb_agent_proto_enumTypes[7].Descriptor()
 }
 
 func (BasicMsgMeta_TraceStep) Type() protoreflect.EnumType {
-	return &file_pb_agent_proto_enumTypes[6]
+	return &file_pb_agent_proto_enumTypes[7]
 }
 
 func (x BasicMsgMeta_TraceStep) Number() protoreflect.EnumNumber {
@@ -361,7 +446,56 @@ func (x BasicMsgMeta_TraceStep) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use BasicMsgMeta_TraceStep.Descriptor instead.
 func (BasicMsgMeta_TraceStep) EnumDescriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{7, 0}
+	return file_pb_agent_proto_rawDescGZIP(), []int{8, 0}
+}
+
+type Gift_CoinType int32
+
+const (
+	Gift_UnknownCoin Gift_CoinType = 0 // not forwarded by agent, treated as gold
+	Gift_Gold        Gift_CoinType = 1
+	Gift_Silver      Gift_CoinType = 2 // free gifts, price in silver seeds
+)
+
+// Enum value maps for Gift_CoinType.
+var (
+	Gift_CoinType_name = map[int32]string{
+		0: "UnknownCoin",
+		1: "Gold",
+		2: "Silver",
+	}
+	Gift_CoinType_value = map[string]int32{
+		"UnknownCoin": 0,
+		"Gold":        1,
+		"Silver":      2,
+	}
+)
+
+func (x Gift_CoinType) Enum() *Gift_CoinType {
+	p := new(Gift_CoinType)
+	*p = x
+	return p
+}
+
+func (x Gift_CoinType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Gift_CoinType) Descriptor() protoreflect.EnumDescriptor {
+	return file_pb_agent_proto_enumTypes[8].Descriptor()
+}
+
+func (Gift_CoinType) Type() protoreflect.EnumType {
+	return &file_pb_agent_proto_enumTypes[8]
+}
+
+func (x Gift_CoinType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Gift_CoinType.Descriptor instead.
+func (Gift_CoinType) EnumDescriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{10, 0}
 }
 
 type Guard_GuardGiftType int32
@@ -400,11 +534,11 @@ func (x Guard_GuardGiftType) String() string {
 }
 
 func (Guard_GuardGiftType) Descriptor() protoreflect.EnumDescriptor {
-	return file_pb_agent_proto_enumTypes[7].Descriptor()
+	return file_pb_agent_proto_enumTypes[9].Descriptor()
 }
 
 func (Guard_GuardGiftType) Type() protoreflect.EnumType {
-	return &file_pb_agent_proto_enumTypes[7]
+	return &file_pb_agent_proto_enumTypes[9]
 }
 
 func (x Guard_GuardGiftType) Number() protoreflect.EnumNumber {
@@ -413,7 +547,65 @@ func (x Guard_GuardGiftType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Guard_GuardGiftType.Descriptor instead.
 func (Guard_GuardGiftType) EnumDescriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{10, 0}
+	return file_pb_agent_proto_rawDescGZIP(), []int{11, 0}
+}
+
+type Interact_InteractType int32
+
+const (
+	Interact_UnknownInteract Interact_InteractType = 0
+	Interact_Enter           Interact_InteractType = 1
+	Interact_Follow          Interact_InteractType = 2
+	Interact_Share           Interact_InteractType = 3
+	Interact_SpecialFollow   Interact_InteractType = 4
+	Interact_MutualFollow    Interact_InteractType = 5
+)
+
+// Enum value maps for Interact_InteractType.
+var (
+	Interact_InteractType_name = map[int32]string{
+		0: "UnknownInteract",
+		1: "Enter",
+		2: "Follow",
+		3: "Share",
+		4: "SpecialFollow",
+		5: "MutualFollow",
+	}
+	Interact_InteractType_value = map[string]int32{
+		"UnknownInteract": 0,
+		"Enter":           1,
+		"Follow":          2,
+		"Share":           3,
+		"SpecialFollow":   4,
+		"MutualFollow":    5,
+	}
+)
+
+func (x Interact_InteractType) Enum() *Interact_InteractType {
+	p := new(Interact_InteractType)
+	*p = x
+	return p
+}
+
+func (x Interact_InteractType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Interact_InteractType) Descriptor() protoreflect.EnumDescriptor {
+	return file_pb_agent_proto_enumTypes[10].Descriptor()
+}
+
+func (Interact_InteractType) Type() protoreflect.EnumType {
+	return &file_pb_agent_proto_enumTypes[10]
+}
+
+func (x Interact_InteractType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Interact_InteractType.Descriptor instead.
+func (Interact_InteractType) EnumDescriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{15, 0}
 }
 
 // normal response for request msg
@@ -474,6 +666,10 @@ type AgentInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ID            string                 `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
 	Type          AgentInfo_AgentType    `protobuf:"varint,2,opt,name=Type,proto3,enum=pb.AgentInfo_AgentType" json:"Type,omitempty"`
+	Version       uint32                 `protobuf:"varint,3,opt,name=Version,proto3" json:"Version,omitempty"`   // protocol version, same as BasicMsgMeta.Version
+	MsgTypes      []string               `protobuf:"bytes,4,rep,name=MsgTypes,proto3" json:"MsgTypes,omitempty"`  // supported bili msg cmd
+	Build         string                 `protobuf:"bytes,5,opt,name=Build,proto3" json:"Build,omitempty"`        // build version of agent
+	MaxRooms      uint32                 `protobuf:"varint,6,opt,name=MaxRooms,proto3" json:"MaxRooms,omitempty"` // room capacity, 0 for unlimited
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -522,6 +718,34 @@ func (x *AgentInfo) GetType() AgentInfo_AgentType {
 	return AgentInfo_UnknownAgent
 }
 
+func (x *AgentInfo) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *AgentInfo) GetMsgTypes() []string {
+	if x != nil {
+		return x.MsgTypes
+	}
+	return nil
+}
+
+func (x *AgentInfo) GetBuild() string {
+	if x != nil {
+		return x.Build
+	}
+	return ""
+}
+
+func (x *AgentInfo) GetMaxRooms() uint32 {
+	if x != nil {
+		return x.MaxRooms
+	}
+	return 0
+}
+
 // bind to control request agent.[AgentID].init
 type AgentInit struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -531,6 +755,7 @@ type AgentInit struct {
 	UA            *string                `protobuf:"bytes,4,opt,name=UA,proto3,oneof" json:"UA,omitempty"`
 	Header        map[string]string      `protobuf:"bytes,5,rep,name=Header,proto3" json:"Header,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	PriorityMode  *uint32                `protobuf:"varint,6,opt,name=PriorityMode,proto3,oneof" json:"PriorityMode,omitempty"` // defined const at github.com/FishZe/go-bili-chat/client:PriorityMode
+	MsgTypes      []string               `protobuf:"bytes,7,rep,name=MsgTypes,proto3" json:"MsgTypes,omitempty"`                // bili msg cmd to forward, all supported types if empty
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -607,18 +832,103 @@ func (x *AgentInit) GetPriorityMode() uint32 {
 	return 0
 }
 
+func (x *AgentInit) GetMsgTypes() []string {
+	if x != nil {
+		return x.MsgTypes
+	}
+	return nil
+}
+
+// account credential for connecting live room, same as AgentInit
+type AgentCredential struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BUVID         string                 `protobuf:"bytes,1,opt,name=BUVID,proto3" json:"BUVID,omitempty"`
+	UID           uint64                 `protobuf:"varint,2,opt,name=UID,proto3" json:"UID,omitempty"`
+	Cookie        string                 `protobuf:"bytes,3,opt,name=Cookie,proto3" json:"Cookie,omitempty"`
+	UA            *string                `protobuf:"bytes,4,opt,name=UA,proto3,oneof" json:"UA,omitempty"`
+	Header        map[string]string      `protobuf:"bytes,5,rep,name=Header,proto3" json:"Header,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentCredential) Reset() {
+	*x = AgentCredential{}
+	mi := &file_pb_agent_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentCredential) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentCredential) ProtoMessage() {}
+
+func (x *AgentCredential) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentCredential.ProtoReflect.Descriptor instead.
+func (*AgentCredential) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AgentCredential) GetBUVID() string {
+	if x != nil {
+		return x.BUVID
+	}
+	return ""
+}
+
+func (x *AgentCredential) GetUID() uint64 {
+	if x != nil {
+		return x.UID
+	}
+	return 0
+}
+
+func (x *AgentCredential) GetCookie() string {
+	if x != nil {
+		return x.Cookie
+	}
+	return ""
+}
+
+func (x *AgentCredential) GetUA() string {
+	if x != nil && x.UA != nil {
+		return *x.UA
+	}
+	return ""
+}
+
+func (x *AgentCredential) GetHeader() map[string]string {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
 // bind to control request agent.[AgentID].action
 type AgentAction struct {
 	state         protoimpl.MessageState      `protogen:"open.v1"`
 	Type          AgentAction_AgentActionType `protobuf:"varint,1,opt,name=Type,proto3,enum=pb.AgentAction_AgentActionType" json:"Type,omitempty"`
 	RoomID        *uint64                     `protobuf:"varint,2,opt,name=RoomID,proto3,oneof" json:"RoomID,omitempty"`
+	Credential    *AgentCredential            `protobuf:"bytes,3,opt,name=Credential,proto3,oneof" json:"Credential,omitempty"` // available at AddRoom, using the identity of AgentInit if not set
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *AgentAction) Reset() {
 	*x = AgentAction{}
-	mi := &file_pb_agent_proto_msgTypes[3]
+	mi := &file_pb_agent_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -630,7 +940,7 @@ func (x *AgentAction) String() string {
 func (*AgentAction) ProtoMessage() {}
 
 func (x *AgentAction) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[3]
+	mi := &file_pb_agent_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -643,7 +953,7 @@ func (x *AgentAction) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AgentAction.ProtoReflect.Descriptor instead.
 func (*AgentAction) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{3}
+	return file_pb_agent_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *AgentAction) GetType() AgentAction_AgentActionType {
@@ -660,6 +970,13 @@ func (x *AgentAction) GetRoomID() uint64 {
 	return 0
 }
 
+func (x *AgentAction) GetCredential() *AgentCredential {
+	if x != nil {
+		return x.Credential
+	}
+	return nil
+}
+
 // bind to agent.status
 type AgentStatus struct {
 	state            protoimpl.MessageState               `protogen:"open.v1"`
@@ -668,13 +985,17 @@ type AgentStatus struct {
 	BufferUsed       uint32                               `protobuf:"varint,3,opt,name=BufferUsed,proto3" json:"BufferUsed,omitempty"`
 	BufferEventCount map[int32]int32                      `protobuf:"bytes,4,rep,name=BufferEventCount,proto3" json:"BufferEventCount,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // BufferType:count
 	MetaCache        map[int32]*AgentStatus_MetaCacheInfo `protobuf:"bytes,5,rep,name=MetaCache,proto3" json:"MetaCache,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`                // MetaCacheType:MetaCacheInfo
+	State            AgentStatus_AgentState               `protobuf:"varint,6,opt,name=State,proto3,enum=pb.AgentStatus_AgentState" json:"State,omitempty"`
+	BufferDropped    map[int32]uint64                     `protobuf:"bytes,7,rep,name=BufferDropped,proto3" json:"BufferDropped,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // BufferType:events dropped by full buffer since agent start
+	BufferSize       uint32                               `protobuf:"varint,8,opt,name=BufferSize,proto3" json:"BufferSize,omitempty"`
+	WorkerBacklog    []uint32                             `protobuf:"varint,9,rep,packed,name=WorkerBacklog,proto3" json:"WorkerBacklog,omitempty"` // events waiting for each event handler worker, BufferUsed is the sum
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
 
 func (x *AgentStatus) Reset() {
 	*x = AgentStatus{}
-	mi := &file_pb_agent_proto_msgTypes[4]
+	mi := &file_pb_agent_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -686,7 +1007,7 @@ func (x *AgentStatus) String() string {
 func (*AgentStatus) ProtoMessage() {}
 
 func (x *AgentStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[4]
+	mi := &file_pb_agent_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -699,7 +1020,7 @@ func (x *AgentStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AgentStatus.ProtoReflect.Descriptor instead.
 func (*AgentStatus) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{4}
+	return file_pb_agent_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *AgentStatus) GetMeta() *BasicMsgMeta {
@@ -737,6 +1058,34 @@ func (x *AgentStatus) GetMetaCache() map[int32]*AgentStatus_MetaCacheInfo {
 	return nil
 }
 
+func (x *AgentStatus) GetState() AgentStatus_AgentState {
+	if x != nil {
+		return x.State
+	}
+	return AgentStatus_Running
+}
+
+func (x *AgentStatus) GetBufferDropped() map[int32]uint64 {
+	if x != nil {
+		return x.BufferDropped
+	}
+	return nil
+}
+
+func (x *AgentStatus) GetBufferSize() uint32 {
+	if x != nil {
+		return x.BufferSize
+	}
+	return 0
+}
+
+func (x *AgentStatus) GetWorkerBacklog() []uint32 {
+	if x != nil {
+		return x.WorkerBacklog
+	}
+	return nil
+}
+
 // bind to request stream.fansMedal
 type FansMedalMeta struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -746,13 +1095,14 @@ type FansMedalMeta struct {
 	Level         uint32                 `protobuf:"varint,4,opt,name=Level,proto3" json:"Level,omitempty"`
 	Light         bool                   `protobuf:"varint,5,opt,name=Light,proto3" json:"Light,omitempty"`
 	GuardLevel    GuardLevelType         `protobuf:"varint,6,opt,name=GuardLevel,proto3,enum=pb.GuardLevelType" json:"GuardLevel,omitempty"`
+	TimeStamp     uint64                 `protobuf:"varint,7,opt,name=TimeStamp,proto3" json:"TimeStamp,omitempty"` // MilliTimestamp of source message, 0 if unknown
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *FansMedalMeta) Reset() {
 	*x = FansMedalMeta{}
-	mi := &file_pb_agent_proto_msgTypes[5]
+	mi := &file_pb_agent_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -764,7 +1114,7 @@ func (x *FansMedalMeta) String() string {
 func (*FansMedalMeta) ProtoMessage() {}
 
 func (x *FansMedalMeta) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[5]
+	mi := &file_pb_agent_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -777,7 +1127,7 @@ func (x *FansMedalMeta) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FansMedalMeta.ProtoReflect.Descriptor instead.
 func (*FansMedalMeta) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{5}
+	return file_pb_agent_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *FansMedalMeta) GetUID() uint64 {
@@ -822,7 +1172,14 @@ func (x *FansMedalMeta) GetGuardLevel() GuardLevelType {
 	return GuardLevelType_NoGuard
 }
 
-// bind to request stream.userInfo
+func (x *FansMedalMeta) GetTimeStamp() uint64 {
+	if x != nil {
+		return x.TimeStamp
+	}
+	return 0
+}
+
+// bind to request stream.userInfoMeta
 type UserInfoMeta struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UID           uint64                 `protobuf:"varint,1,opt,name=UID,proto3" json:"UID,omitempty"`
@@ -830,13 +1187,14 @@ type UserInfoMeta struct {
 	Face          *string                `protobuf:"bytes,3,opt,name=Face,proto3,oneof" json:"Face,omitempty"`                // available at damaku,gift,superChat,onlineRankV2
 	Level         *uint32                `protobuf:"varint,4,opt,name=Level,proto3,oneof" json:"Level,omitempty"`             // available at superChat
 	WealthLevel   *uint32                `protobuf:"varint,5,opt,name=WealthLevel,proto3,oneof" json:"WealthLevel,omitempty"` // available at gift
+	TimeStamp     uint64                 `protobuf:"varint,6,opt,name=TimeStamp,proto3" json:"TimeStamp,omitempty"`           // MilliTimestamp of source message, 0 if unknown
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UserInfoMeta) Reset() {
 	*x = UserInfoMeta{}
-	mi := &file_pb_agent_proto_msgTypes[6]
+	mi := &file_pb_agent_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -848,7 +1206,7 @@ func (x *UserInfoMeta) String() string {
 func (*UserInfoMeta) ProtoMessage() {}
 
 func (x *UserInfoMeta) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[6]
+	mi := &file_pb_agent_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -861,7 +1219,7 @@ func (x *UserInfoMeta) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserInfoMeta.ProtoReflect.Descriptor instead.
 func (*UserInfoMeta) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{6}
+	return file_pb_agent_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *UserInfoMeta) GetUID() uint64 {
@@ -899,6 +1257,13 @@ func (x *UserInfoMeta) GetWealthLevel() uint32 {
 	return 0
 }
 
+func (x *UserInfoMeta) GetTimeStamp() uint64 {
+	if x != nil {
+		return x.TimeStamp
+	}
+	return 0
+}
+
 type BasicMsgMeta struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Version       uint32                 `protobuf:"varint,1,opt,name=Version,proto3" json:"Version,omitempty"`
@@ -906,13 +1271,14 @@ type BasicMsgMeta struct {
 	RoomID        *uint64                `protobuf:"varint,3,opt,name=RoomID,proto3,oneof" json:"RoomID,omitempty"`                                                                    // available at msg
 	TimeStamp     uint64                 `protobuf:"varint,4,opt,name=TimeStamp,proto3" json:"TimeStamp,omitempty"`                                                                    // MilliTimestamp
 	Trace         map[int32]uint64       `protobuf:"bytes,5,rep,name=Trace,proto3" json:"Trace,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // TraceStep:Microseconds, available at msg
+	Session       string                 `protobuf:"bytes,6,opt,name=Session,proto3" json:"Session,omitempty"`                                                                         // live session id of room, set by controller, empty if room not in live
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *BasicMsgMeta) Reset() {
 	*x = BasicMsgMeta{}
-	mi := &file_pb_agent_proto_msgTypes[7]
+	mi := &file_pb_agent_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -924,7 +1290,7 @@ func (x *BasicMsgMeta) String() string {
 func (*BasicMsgMeta) ProtoMessage() {}
 
 func (x *BasicMsgMeta) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[7]
+	mi := &file_pb_agent_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -937,7 +1303,7 @@ func (x *BasicMsgMeta) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BasicMsgMeta.ProtoReflect.Descriptor instead.
 func (*BasicMsgMeta) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{7}
+	return file_pb_agent_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *BasicMsgMeta) GetVersion() uint32 {
@@ -975,6 +1341,13 @@ func (x *BasicMsgMeta) GetTrace() map[int32]uint64 {
 	return nil
 }
 
+func (x *BasicMsgMeta) GetSession() string {
+	if x != nil {
+		return x.Session
+	}
+	return ""
+}
+
 // bind to stream.damaku
 type Damaku struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -988,7 +1361,7 @@ type Damaku struct {
 
 func (x *Damaku) Reset() {
 	*x = Damaku{}
-	mi := &file_pb_agent_proto_msgTypes[8]
+	mi := &file_pb_agent_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1000,7 +1373,7 @@ func (x *Damaku) String() string {
 func (*Damaku) ProtoMessage() {}
 
 func (x *Damaku) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[8]
+	mi := &file_pb_agent_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1013,7 +1386,7 @@ func (x *Damaku) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Damaku.ProtoReflect.Descriptor instead.
 func (*Damaku) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{8}
+	return file_pb_agent_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Damaku) GetMeta() *BasicMsgMeta {
@@ -1054,13 +1427,14 @@ type Gift struct {
 	Info          *Gift_GiftInfo         `protobuf:"bytes,5,opt,name=Info,proto3" json:"Info,omitempty"`
 	OriginalInfo  *Gift_GiftInfo         `protobuf:"bytes,6,opt,name=OriginalInfo,proto3" json:"OriginalInfo,omitempty"` // include blind gift, or same as info
 	Medal         uint64                 `protobuf:"varint,7,opt,name=Medal,proto3" json:"Medal,omitempty"`
+	Coin          Gift_CoinType          `protobuf:"varint,8,opt,name=Coin,proto3,enum=pb.Gift_CoinType" json:"Coin,omitempty"` // only gold gifts are revenue
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Gift) Reset() {
 	*x = Gift{}
-	mi := &file_pb_agent_proto_msgTypes[9]
+	mi := &file_pb_agent_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1072,7 +1446,7 @@ func (x *Gift) String() string {
 func (*Gift) ProtoMessage() {}
 
 func (x *Gift) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[9]
+	mi := &file_pb_agent_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1085,7 +1459,7 @@ func (x *Gift) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Gift.ProtoReflect.Descriptor instead.
 func (*Gift) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{9}
+	return file_pb_agent_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Gift) GetMeta() *BasicMsgMeta {
@@ -1137,6 +1511,13 @@ func (x *Gift) GetMedal() uint64 {
 	return 0
 }
 
+func (x *Gift) GetCoin() Gift_CoinType {
+	if x != nil {
+		return x.Coin
+	}
+	return Gift_UnknownCoin
+}
+
 // bind to stream.guard
 type Guard struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -1150,7 +1531,7 @@ type Guard struct {
 
 func (x *Guard) Reset() {
 	*x = Guard{}
-	mi := &file_pb_agent_proto_msgTypes[10]
+	mi := &file_pb_agent_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1162,7 +1543,7 @@ func (x *Guard) String() string {
 func (*Guard) ProtoMessage() {}
 
 func (x *Guard) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[10]
+	mi := &file_pb_agent_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1175,7 +1556,7 @@ func (x *Guard) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Guard.ProtoReflect.Descriptor instead.
 func (*Guard) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{10}
+	return file_pb_agent_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *Guard) GetMeta() *BasicMsgMeta {
@@ -1222,7 +1603,7 @@ type SuperChat struct {
 
 func (x *SuperChat) Reset() {
 	*x = SuperChat{}
-	mi := &file_pb_agent_proto_msgTypes[11]
+	mi := &file_pb_agent_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1234,7 +1615,7 @@ func (x *SuperChat) String() string {
 func (*SuperChat) ProtoMessage() {}
 
 func (x *SuperChat) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[11]
+	mi := &file_pb_agent_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1247,7 +1628,7 @@ func (x *SuperChat) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SuperChat.ProtoReflect.Descriptor instead.
 func (*SuperChat) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{11}
+	return file_pb_agent_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *SuperChat) GetMeta() *BasicMsgMeta {
@@ -1311,7 +1692,7 @@ type OnlineRankCount struct {
 
 func (x *OnlineRankCount) Reset() {
 	*x = OnlineRankCount{}
-	mi := &file_pb_agent_proto_msgTypes[12]
+	mi := &file_pb_agent_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1323,7 +1704,7 @@ func (x *OnlineRankCount) String() string {
 func (*OnlineRankCount) ProtoMessage() {}
 
 func (x *OnlineRankCount) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[12]
+	mi := &file_pb_agent_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1336,7 +1717,7 @@ func (x *OnlineRankCount) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OnlineRankCount.ProtoReflect.Descriptor instead.
 func (*OnlineRankCount) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{12}
+	return file_pb_agent_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *OnlineRankCount) GetMeta() *BasicMsgMeta {
@@ -1371,7 +1752,7 @@ type OnlineRankV2 struct {
 
 func (x *OnlineRankV2) Reset() {
 	*x = OnlineRankV2{}
-	mi := &file_pb_agent_proto_msgTypes[13]
+	mi := &file_pb_agent_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1383,7 +1764,7 @@ func (x *OnlineRankV2) String() string {
 func (*OnlineRankV2) ProtoMessage() {}
 
 func (x *OnlineRankV2) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[13]
+	mi := &file_pb_agent_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1396,7 +1777,7 @@ func (x *OnlineRankV2) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OnlineRankV2.ProtoReflect.Descriptor instead.
 func (*OnlineRankV2) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{13}
+	return file_pb_agent_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *OnlineRankV2) GetMeta() *BasicMsgMeta {
@@ -1413,6 +1794,644 @@ func (x *OnlineRankV2) GetList() []*OnlineRankV2_OnlineRankList {
 	return nil
 }
 
+// bind to stream.interact, user entered, followed or shared the room
+type Interact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *BasicMsgMeta          `protobuf:"bytes,1,opt,name=Meta,proto3" json:"Meta,omitempty"`
+	UID           uint64                 `protobuf:"varint,2,opt,name=UID,proto3" json:"UID,omitempty"`
+	Type          Interact_InteractType  `protobuf:"varint,3,opt,name=Type,proto3,enum=pb.Interact_InteractType" json:"Type,omitempty"`
+	Medal         uint64                 `protobuf:"varint,4,opt,name=Medal,proto3" json:"Medal,omitempty"` // target user id
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Interact) Reset() {
+	*x = Interact{}
+	mi := &file_pb_agent_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Interact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Interact) ProtoMessage() {}
+
+func (x *Interact) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Interact.ProtoReflect.Descriptor instead.
+func (*Interact) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Interact) GetMeta() *BasicMsgMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *Interact) GetUID() uint64 {
+	if x != nil {
+		return x.UID
+	}
+	return 0
+}
+
+func (x *Interact) GetType() Interact_InteractType {
+	if x != nil {
+		return x.Type
+	}
+	return Interact_UnknownInteract
+}
+
+func (x *Interact) GetMedal() uint64 {
+	if x != nil {
+		return x.Medal
+	}
+	return 0
+}
+
+// bind to stream.like
+type LikeCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *BasicMsgMeta          `protobuf:"bytes,1,opt,name=Meta,proto3" json:"Meta,omitempty"`
+	Count         uint32                 `protobuf:"varint,2,opt,name=Count,proto3" json:"Count,omitempty"` // total likes of current live
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LikeCount) Reset() {
+	*x = LikeCount{}
+	mi := &file_pb_agent_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LikeCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LikeCount) ProtoMessage() {}
+
+func (x *LikeCount) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LikeCount.ProtoReflect.Descriptor instead.
+func (*LikeCount) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *LikeCount) GetMeta() *BasicMsgMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *LikeCount) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// bind to stream.roomChange, title or area changed
+type RoomChange struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Meta           *BasicMsgMeta          `protobuf:"bytes,1,opt,name=Meta,proto3" json:"Meta,omitempty"`
+	Title          string                 `protobuf:"bytes,2,opt,name=Title,proto3" json:"Title,omitempty"`
+	AreaID         uint32                 `protobuf:"varint,3,opt,name=AreaID,proto3" json:"AreaID,omitempty"`
+	AreaName       string                 `protobuf:"bytes,4,opt,name=AreaName,proto3" json:"AreaName,omitempty"`
+	ParentAreaID   uint32                 `protobuf:"varint,5,opt,name=ParentAreaID,proto3" json:"ParentAreaID,omitempty"`
+	ParentAreaName string                 `protobuf:"bytes,6,opt,name=ParentAreaName,proto3" json:"ParentAreaName,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RoomChange) Reset() {
+	*x = RoomChange{}
+	mi := &file_pb_agent_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomChange) ProtoMessage() {}
+
+func (x *RoomChange) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomChange.ProtoReflect.Descriptor instead.
+func (*RoomChange) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RoomChange) GetMeta() *BasicMsgMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *RoomChange) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *RoomChange) GetAreaID() uint32 {
+	if x != nil {
+		return x.AreaID
+	}
+	return 0
+}
+
+func (x *RoomChange) GetAreaName() string {
+	if x != nil {
+		return x.AreaName
+	}
+	return ""
+}
+
+func (x *RoomChange) GetParentAreaID() uint32 {
+	if x != nil {
+		return x.ParentAreaID
+	}
+	return 0
+}
+
+func (x *RoomChange) GetParentAreaName() string {
+	if x != nil {
+		return x.ParentAreaName
+	}
+	return ""
+}
+
+// bind to stream.liveStatus, live started (LIVE) or ended (PREPARING)
+type LiveStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *BasicMsgMeta          `protobuf:"bytes,1,opt,name=Meta,proto3" json:"Meta,omitempty"`
+	Live          bool                   `protobuf:"varint,2,opt,name=Live,proto3" json:"Live,omitempty"`
+	LiveTime      uint64                 `protobuf:"varint,3,opt,name=LiveTime,proto3" json:"LiveTime,omitempty"` // MilliTimestamp of live started, 0 if unknown
+	LiveKey       string                 `protobuf:"bytes,4,opt,name=LiveKey,proto3" json:"LiveKey,omitempty"`    // unique key of live session, empty if unknown
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LiveStatus) Reset() {
+	*x = LiveStatus{}
+	mi := &file_pb_agent_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LiveStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LiveStatus) ProtoMessage() {}
+
+func (x *LiveStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LiveStatus.ProtoReflect.Descriptor instead.
+func (*LiveStatus) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *LiveStatus) GetMeta() *BasicMsgMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *LiveStatus) GetLive() bool {
+	if x != nil {
+		return x.Live
+	}
+	return false
+}
+
+func (x *LiveStatus) GetLiveTime() uint64 {
+	if x != nil {
+		return x.LiveTime
+	}
+	return 0
+}
+
+func (x *LiveStatus) GetLiveKey() string {
+	if x != nil {
+		return x.LiveKey
+	}
+	return ""
+}
+
+// bind to stream.watched
+type WatchedCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *BasicMsgMeta          `protobuf:"bytes,1,opt,name=Meta,proto3" json:"Meta,omitempty"`
+	Count         uint32                 `protobuf:"varint,2,opt,name=Count,proto3" json:"Count,omitempty"` // users watched current live
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchedCount) Reset() {
+	*x = WatchedCount{}
+	mi := &file_pb_agent_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchedCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchedCount) ProtoMessage() {}
+
+func (x *WatchedCount) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchedCount.ProtoReflect.Descriptor instead.
+func (*WatchedCount) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *WatchedCount) GetMeta() *BasicMsgMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *WatchedCount) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// bind to stream.fans
+type FansCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *BasicMsgMeta          `protobuf:"bytes,1,opt,name=Meta,proto3" json:"Meta,omitempty"`
+	Fans          uint32                 `protobuf:"varint,2,opt,name=Fans,proto3" json:"Fans,omitempty"`
+	FansClub      uint32                 `protobuf:"varint,3,opt,name=FansClub,proto3" json:"FansClub,omitempty"` // fans medal owners
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FansCount) Reset() {
+	*x = FansCount{}
+	mi := &file_pb_agent_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FansCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FansCount) ProtoMessage() {}
+
+func (x *FansCount) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FansCount.ProtoReflect.Descriptor instead.
+func (*FansCount) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *FansCount) GetMeta() *BasicMsgMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *FansCount) GetFans() uint32 {
+	if x != nil {
+		return x.Fans
+	}
+	return 0
+}
+
+func (x *FansCount) GetFansClub() uint32 {
+	if x != nil {
+		return x.FansClub
+	}
+	return 0
+}
+
+// emitted by controller when master agent of room failed over,
+// single stream (online, onlineV2) of room may have a hole between LastSeen and Meta.TimeStamp
+type StreamGap struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meta          *BasicMsgMeta          `protobuf:"bytes,1,opt,name=Meta,proto3" json:"Meta,omitempty"` // Agent is the new master, empty if no agent available
+	PreviousAgent string                 `protobuf:"bytes,2,opt,name=PreviousAgent,proto3" json:"PreviousAgent,omitempty"`
+	LastSeen      uint64                 `protobuf:"varint,3,opt,name=LastSeen,proto3" json:"LastSeen,omitempty"` // MilliTimestamp of the last status from previous agent
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamGap) Reset() {
+	*x = StreamGap{}
+	mi := &file_pb_agent_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamGap) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamGap) ProtoMessage() {}
+
+func (x *StreamGap) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamGap.ProtoReflect.Descriptor instead.
+func (*StreamGap) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *StreamGap) GetMeta() *BasicMsgMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *StreamGap) GetPreviousAgent() string {
+	if x != nil {
+		return x.PreviousAgent
+	}
+	return ""
+}
+
+func (x *StreamGap) GetLastSeen() uint64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+// frame of websocket batch from controller, a batch is uvarint length-prefixed frames
+type EventFrame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=Type,proto3" json:"Type,omitempty"` // event type, same as stream subject suffix
+	Data          []byte                 `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"` // marshaled msg of Type
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventFrame) Reset() {
+	*x = EventFrame{}
+	mi := &file_pb_agent_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventFrame) ProtoMessage() {}
+
+func (x *EventFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventFrame.ProtoReflect.Descriptor instead.
+func (*EventFrame) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *EventFrame) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *EventFrame) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// published by controller to [prefix].stats.[window].[room] when a tumbling window closed
+type RoomStats struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	RoomID           uint64                 `protobuf:"varint,1,opt,name=RoomID,proto3" json:"RoomID,omitempty"`
+	Window           string                 `protobuf:"bytes,2,opt,name=Window,proto3" json:"Window,omitempty"` // e.g. 1m, 5m, 1h
+	Start            uint64                 `protobuf:"varint,3,opt,name=Start,proto3" json:"Start,omitempty"`  // MilliTimestamp, included
+	End              uint64                 `protobuf:"varint,4,opt,name=End,proto3" json:"End,omitempty"`      // MilliTimestamp, excluded
+	Damaku           uint32                 `protobuf:"varint,5,opt,name=Damaku,proto3" json:"Damaku,omitempty"`
+	DamakuPerMinute  float64                `protobuf:"fixed64,6,opt,name=DamakuPerMinute,proto3" json:"DamakuPerMinute,omitempty"`
+	Chatters         uint32                 `protobuf:"varint,7,opt,name=Chatters,proto3" json:"Chatters,omitempty"`       // unique UID of damaku
+	GiftRevenue      uint64                 `protobuf:"varint,8,opt,name=GiftRevenue,proto3" json:"GiftRevenue,omitempty"` // gold_seeds, Info.Price * Count
+	GuardCount       uint32                 `protobuf:"varint,9,opt,name=GuardCount,proto3" json:"GuardCount,omitempty"`
+	GuardRevenue     uint64                 `protobuf:"varint,10,opt,name=GuardRevenue,proto3" json:"GuardRevenue,omitempty"` // gold_seeds
+	SuperChatCount   uint32                 `protobuf:"varint,11,opt,name=SuperChatCount,proto3" json:"SuperChatCount,omitempty"`
+	SuperChatRevenue uint64                 `protobuf:"varint,12,opt,name=SuperChatRevenue,proto3" json:"SuperChatRevenue,omitempty"` // RMB
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *RoomStats) Reset() {
+	*x = RoomStats{}
+	mi := &file_pb_agent_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomStats) ProtoMessage() {}
+
+func (x *RoomStats) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_agent_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomStats.ProtoReflect.Descriptor instead.
+func (*RoomStats) Descriptor() ([]byte, []int) {
+	return file_pb_agent_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RoomStats) GetRoomID() uint64 {
+	if x != nil {
+		return x.RoomID
+	}
+	return 0
+}
+
+func (x *RoomStats) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+func (x *RoomStats) GetStart() uint64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *RoomStats) GetEnd() uint64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *RoomStats) GetDamaku() uint32 {
+	if x != nil {
+		return x.Damaku
+	}
+	return 0
+}
+
+func (x *RoomStats) GetDamakuPerMinute() float64 {
+	if x != nil {
+		return x.DamakuPerMinute
+	}
+	return 0
+}
+
+func (x *RoomStats) GetChatters() uint32 {
+	if x != nil {
+		return x.Chatters
+	}
+	return 0
+}
+
+func (x *RoomStats) GetGiftRevenue() uint64 {
+	if x != nil {
+		return x.GiftRevenue
+	}
+	return 0
+}
+
+func (x *RoomStats) GetGuardCount() uint32 {
+	if x != nil {
+		return x.GuardCount
+	}
+	return 0
+}
+
+func (x *RoomStats) GetGuardRevenue() uint64 {
+	if x != nil {
+		return x.GuardRevenue
+	}
+	return 0
+}
+
+func (x *RoomStats) GetSuperChatCount() uint32 {
+	if x != nil {
+		return x.SuperChatCount
+	}
+	return 0
+}
+
+func (x *RoomStats) GetSuperChatRevenue() uint64 {
+	if x != nil {
+		return x.SuperChatRevenue
+	}
+	return 0
+}
+
 type AgentStatus_MetaCacheInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Buffer        uint32                 `protobuf:"varint,1,opt,name=Buffer,proto3" json:"Buffer,omitempty"` // meta indexer queue
@@ -1422,13 +2441,14 @@ type AgentStatus_MetaCacheInfo struct {
 	DelHits       int64                  `protobuf:"varint,5,opt,name=DelHits,proto3" json:"DelHits,omitempty"`
 	DelMisses     int64                  `protobuf:"varint,6,opt,name=DelMisses,proto3" json:"DelMisses,omitempty"`
 	Collisions    int64                  `protobuf:"varint,7,opt,name=Collisions,proto3" json:"Collisions,omitempty"`
+	Dropped       uint64                 `protobuf:"varint,8,opt,name=Dropped,proto3" json:"Dropped,omitempty"` // dropped by full queue since agent start
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *AgentStatus_MetaCacheInfo) Reset() {
 	*x = AgentStatus_MetaCacheInfo{}
-	mi := &file_pb_agent_proto_msgTypes[17]
+	mi := &file_pb_agent_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1440,7 +2460,7 @@ func (x *AgentStatus_MetaCacheInfo) String() string {
 func (*AgentStatus_MetaCacheInfo) ProtoMessage() {}
 
 func (x *AgentStatus_MetaCacheInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[17]
+	mi := &file_pb_agent_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1453,7 +2473,7 @@ func (x *AgentStatus_MetaCacheInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AgentStatus_MetaCacheInfo.ProtoReflect.Descriptor instead.
 func (*AgentStatus_MetaCacheInfo) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{4, 2}
+	return file_pb_agent_proto_rawDescGZIP(), []int{5, 3}
 }
 
 func (x *AgentStatus_MetaCacheInfo) GetBuffer() uint32 {
@@ -1505,6 +2525,13 @@ func (x *AgentStatus_MetaCacheInfo) GetCollisions() int64 {
 	return 0
 }
 
+func (x *AgentStatus_MetaCacheInfo) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
 type Gift_GiftInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ID            uint32                 `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
@@ -1516,7 +2543,7 @@ type Gift_GiftInfo struct {
 
 func (x *Gift_GiftInfo) Reset() {
 	*x = Gift_GiftInfo{}
-	mi := &file_pb_agent_proto_msgTypes[19]
+	mi := &file_pb_agent_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1528,7 +2555,7 @@ func (x *Gift_GiftInfo) String() string {
 func (*Gift_GiftInfo) ProtoMessage() {}
 
 func (x *Gift_GiftInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[19]
+	mi := &file_pb_agent_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1541,7 +2568,7 @@ func (x *Gift_GiftInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Gift_GiftInfo.ProtoReflect.Descriptor instead.
 func (*Gift_GiftInfo) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{9, 0}
+	return file_pb_agent_proto_rawDescGZIP(), []int{10, 0}
 }
 
 func (x *Gift_GiftInfo) GetID() uint32 {
@@ -1577,7 +2604,7 @@ type OnlineRankV2_OnlineRankList struct {
 
 func (x *OnlineRankV2_OnlineRankList) Reset() {
 	*x = OnlineRankV2_OnlineRankList{}
-	mi := &file_pb_agent_proto_msgTypes[20]
+	mi := &file_pb_agent_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1589,7 +2616,7 @@ func (x *OnlineRankV2_OnlineRankList) String() string {
 func (*OnlineRankV2_OnlineRankList) ProtoMessage() {}
 
 func (x *OnlineRankV2_OnlineRankList) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_agent_proto_msgTypes[20]
+	mi := &file_pb_agent_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1602,7 +2629,7 @@ func (x *OnlineRankV2_OnlineRankList) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OnlineRankV2_OnlineRankList.ProtoReflect.Descriptor instead.
 func (*OnlineRankV2_OnlineRankList) Descriptor() ([]byte, []int) {
-	return file_pb_agent_proto_rawDescGZIP(), []int{13, 0}
+	return file_pb_agent_proto_rawDescGZIP(), []int{14, 0}
 }
 
 func (x *OnlineRankV2_OnlineRankList) GetRank() uint32 {
@@ -1645,33 +2672,57 @@ const file_pb_agent_proto_rawDesc = "" +
 	"StatusType\x12\x06\n" +
 	"\x02OK\x10\x00\x12\a\n" +
 	"\x03Err\x10\x01B\b\n" +
-	"\x06_Error\"\x8d\x01\n" +
+	"\x06_Error\"\xf5\x01\n" +
 	"\tAgentInfo\x12\x0e\n" +
 	"\x02ID\x18\x01 \x01(\tR\x02ID\x12+\n" +
-	"\x04Type\x18\x02 \x01(\x0e2\x17.pb.AgentInfo.AgentTypeR\x04Type\"C\n" +
+	"\x04Type\x18\x02 \x01(\x0e2\x17.pb.AgentInfo.AgentTypeR\x04Type\x12\x18\n" +
+	"\aVersion\x18\x03 \x01(\rR\aVersion\x12\x1a\n" +
+	"\bMsgTypes\x18\x04 \x03(\tR\bMsgTypes\x12\x14\n" +
+	"\x05Build\x18\x05 \x01(\tR\x05Build\x12\x1a\n" +
+	"\bMaxRooms\x18\x06 \x01(\rR\bMaxRooms\"C\n" +
 	"\tAgentType\x12\x10\n" +
 	"\fUnknownAgent\x10\x00\x12\x11\n" +
 	"\rRealTimeAgent\x10\x01\x12\x11\n" +
-	"\rPlaybackAgent\x10\x02\"\x8f\x02\n" +
+	"\rPlaybackAgent\x10\x02\"\xab\x02\n" +
 	"\tAgentInit\x12\x14\n" +
 	"\x05BUVID\x18\x01 \x01(\tR\x05BUVID\x12\x10\n" +
 	"\x03UID\x18\x02 \x01(\x04R\x03UID\x12\x16\n" +
 	"\x06Cookie\x18\x03 \x01(\tR\x06Cookie\x12\x13\n" +
 	"\x02UA\x18\x04 \x01(\tH\x00R\x02UA\x88\x01\x01\x121\n" +
 	"\x06Header\x18\x05 \x03(\v2\x19.pb.AgentInit.HeaderEntryR\x06Header\x12'\n" +
-	"\fPriorityMode\x18\x06 \x01(\rH\x01R\fPriorityMode\x88\x01\x01\x1a9\n" +
+	"\fPriorityMode\x18\x06 \x01(\rH\x01R\fPriorityMode\x88\x01\x01\x12\x1a\n" +
+	"\bMsgTypes\x18\a \x03(\tR\bMsgTypes\x1a9\n" +
 	"\vHeaderEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x05\n" +
 	"\x03_UAB\x0f\n" +
-	"\r_PriorityMode\"\x97\x01\n" +
+	"\r_PriorityMode\"\xe1\x01\n" +
+	"\x0fAgentCredential\x12\x14\n" +
+	"\x05BUVID\x18\x01 \x01(\tR\x05BUVID\x12\x10\n" +
+	"\x03UID\x18\x02 \x01(\x04R\x03UID\x12\x16\n" +
+	"\x06Cookie\x18\x03 \x01(\tR\x06Cookie\x12\x13\n" +
+	"\x02UA\x18\x04 \x01(\tH\x00R\x02UA\x88\x01\x01\x127\n" +
+	"\x06Header\x18\x05 \x03(\v2\x1f.pb.AgentCredential.HeaderEntryR\x06Header\x1a9\n" +
+	"\vHeaderEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x05\n" +
+	"\x03_UA\"\x90\x02\n" +
 	"\vAgentAction\x123\n" +
 	"\x04Type\x18\x01 \x01(\x0e2\x1f.pb.AgentAction.AgentActionTypeR\x04Type\x12\x1b\n" +
-	"\x06RoomID\x18\x02 \x01(\x04H\x00R\x06RoomID\x88\x01\x01\"+\n" +
+	"\x06RoomID\x18\x02 \x01(\x04H\x00R\x06RoomID\x88\x01\x01\x128\n" +
+	"\n" +
+	"Credential\x18\x03 \x01(\v2\x13.pb.AgentCredentialH\x01R\n" +
+	"Credential\x88\x01\x01\"[\n" +
 	"\x0fAgentActionType\x12\v\n" +
 	"\aAddRoom\x10\x00\x12\v\n" +
-	"\aDelRoom\x10\x01B\t\n" +
-	"\a_RoomID\"\xee\x05\n" +
+	"\aDelRoom\x10\x01\x12\t\n" +
+	"\x05Drain\x10\x02\x12\f\n" +
+	"\bShutdown\x10\x03\x12\t\n" +
+	"\x05Pause\x10\x04\x12\n" +
+	"\n" +
+	"\x06Resume\x10\x05B\t\n" +
+	"\a_RoomIDB\r\n" +
+	"\v_Credential\"\xaf\t\n" +
 	"\vAgentStatus\x12$\n" +
 	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x1a\n" +
 	"\bWatching\x18\x02 \x03(\x04R\bWatching\x12\x1e\n" +
@@ -1679,13 +2730,22 @@ const file_pb_agent_proto_rawDesc = "" +
 	"BufferUsed\x18\x03 \x01(\rR\n" +
 	"BufferUsed\x12Q\n" +
 	"\x10BufferEventCount\x18\x04 \x03(\v2%.pb.AgentStatus.BufferEventCountEntryR\x10BufferEventCount\x12<\n" +
-	"\tMetaCache\x18\x05 \x03(\v2\x1e.pb.AgentStatus.MetaCacheEntryR\tMetaCache\x1aC\n" +
+	"\tMetaCache\x18\x05 \x03(\v2\x1e.pb.AgentStatus.MetaCacheEntryR\tMetaCache\x120\n" +
+	"\x05State\x18\x06 \x01(\x0e2\x1a.pb.AgentStatus.AgentStateR\x05State\x12H\n" +
+	"\rBufferDropped\x18\a \x03(\v2\".pb.AgentStatus.BufferDroppedEntryR\rBufferDropped\x12\x1e\n" +
+	"\n" +
+	"BufferSize\x18\b \x01(\rR\n" +
+	"BufferSize\x12$\n" +
+	"\rWorkerBacklog\x18\t \x03(\rR\rWorkerBacklog\x1aC\n" +
 	"\x15BufferEventCountEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\x1a[\n" +
 	"\x0eMetaCacheEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\x05R\x03key\x123\n" +
-	"\x05value\x18\x02 \x01(\v2\x1d.pb.AgentStatus.MetaCacheInfoR\x05value:\x028\x01\x1a\xc3\x01\n" +
+	"\x05value\x18\x02 \x01(\v2\x1d.pb.AgentStatus.MetaCacheInfoR\x05value:\x028\x01\x1a@\n" +
+	"\x12BufferDroppedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a\xdd\x01\n" +
 	"\rMetaCacheInfo\x12\x16\n" +
 	"\x06Buffer\x18\x01 \x01(\rR\x06Buffer\x12\x16\n" +
 	"\x06Cached\x18\x02 \x01(\rR\x06Cached\x12\x12\n" +
@@ -1695,7 +2755,15 @@ const file_pb_agent_proto_rawDesc = "" +
 	"\tDelMisses\x18\x06 \x01(\x03R\tDelMisses\x12\x1e\n" +
 	"\n" +
 	"Collisions\x18\a \x01(\x03R\n" +
-	"Collisions\"^\n" +
+	"Collisions\x12\x18\n" +
+	"\aDropped\x18\b \x01(\x04R\aDropped\"@\n" +
+	"\n" +
+	"AgentState\x12\v\n" +
+	"\aRunning\x10\x00\x12\n" +
+	"\n" +
+	"\x06Paused\x10\x01\x12\f\n" +
+	"\bDraining\x10\x02\x12\v\n" +
+	"\aStopped\x10\x03\"\xbe\x01\n" +
 	"\n" +
 	"BufferType\x12\n" +
 	"\n" +
@@ -1705,10 +2773,19 @@ const file_pb_agent_proto_rawDesc = "" +
 	"\tSuperChat\x10\x03\x12\x0e\n" +
 	"\n" +
 	"OnlineRank\x10\x04\x12\x10\n" +
-	"\fOnlineRankV2\x10\x05\"$\n" +
+	"\fOnlineRankV2\x10\x05\x12\f\n" +
+	"\bInteract\x10\x06\x12\b\n" +
+	"\x04Like\x10\a\x12\x0e\n" +
+	"\n" +
+	"RoomChange\x10\b\x12\b\n" +
+	"\x04Live\x10\t\x12\r\n" +
+	"\tPreparing\x10\n" +
+	"\x12\v\n" +
+	"\aWatched\x10\v\x12\x10\n" +
+	"\fRoomRealTime\x10\f\"$\n" +
 	"\rMetaCacheType\x12\b\n" +
 	"\x04User\x10\x00\x12\t\n" +
-	"\x05Medal\x10\x01\"\xaf\x01\n" +
+	"\x05Medal\x10\x01\"\xcd\x01\n" +
 	"\rFansMedalMeta\x12\x10\n" +
 	"\x03UID\x18\x01 \x01(\x04R\x03UID\x12\x18\n" +
 	"\aRoomUID\x18\x02 \x01(\x04R\aRoomUID\x12\x12\n" +
@@ -1717,22 +2794,25 @@ const file_pb_agent_proto_rawDesc = "" +
 	"\x05Light\x18\x05 \x01(\bR\x05Light\x122\n" +
 	"\n" +
 	"GuardLevel\x18\x06 \x01(\x0e2\x12.pb.GuardLevelTypeR\n" +
-	"GuardLevel\"\xba\x01\n" +
+	"GuardLevel\x12\x1c\n" +
+	"\tTimeStamp\x18\a \x01(\x04R\tTimeStamp\"\xd8\x01\n" +
 	"\fUserInfoMeta\x12\x10\n" +
 	"\x03UID\x18\x01 \x01(\x04R\x03UID\x12\x1a\n" +
 	"\bUserName\x18\x02 \x01(\tR\bUserName\x12\x17\n" +
 	"\x04Face\x18\x03 \x01(\tH\x00R\x04Face\x88\x01\x01\x12\x19\n" +
 	"\x05Level\x18\x04 \x01(\rH\x01R\x05Level\x88\x01\x01\x12%\n" +
-	"\vWealthLevel\x18\x05 \x01(\rH\x02R\vWealthLevel\x88\x01\x01B\a\n" +
+	"\vWealthLevel\x18\x05 \x01(\rH\x02R\vWealthLevel\x88\x01\x01\x12\x1c\n" +
+	"\tTimeStamp\x18\x06 \x01(\x04R\tTimeStampB\a\n" +
 	"\x05_FaceB\b\n" +
 	"\x06_LevelB\x0e\n" +
-	"\f_WealthLevel\"\x95\x02\n" +
+	"\f_WealthLevel\"\xaf\x02\n" +
 	"\fBasicMsgMeta\x12\x18\n" +
 	"\aVersion\x18\x01 \x01(\rR\aVersion\x12\x14\n" +
 	"\x05Agent\x18\x02 \x01(\tR\x05Agent\x12\x1b\n" +
 	"\x06RoomID\x18\x03 \x01(\x04H\x00R\x06RoomID\x88\x01\x01\x12\x1c\n" +
 	"\tTimeStamp\x18\x04 \x01(\x04R\tTimeStamp\x121\n" +
-	"\x05Trace\x18\x05 \x03(\v2\x1b.pb.BasicMsgMeta.TraceEntryR\x05Trace\x1a8\n" +
+	"\x05Trace\x18\x05 \x03(\v2\x1b.pb.BasicMsgMeta.TraceEntryR\x05Trace\x12\x18\n" +
+	"\aSession\x18\x06 \x01(\tR\aSession\x1a8\n" +
 	"\n" +
 	"TraceEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
@@ -1745,7 +2825,7 @@ const file_pb_agent_proto_rawDesc = "" +
 	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x10\n" +
 	"\x03UID\x18\x02 \x01(\x04R\x03UID\x12\x18\n" +
 	"\aContent\x18\x03 \x01(\tR\aContent\x12\x14\n" +
-	"\x05Medal\x18\x04 \x01(\x04R\x05Medal\"\xa0\x02\n" +
+	"\x05Medal\x18\x04 \x01(\x04R\x05Medal\"\xfa\x02\n" +
 	"\x04Gift\x12$\n" +
 	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x10\n" +
 	"\x03TID\x18\x02 \x01(\x04R\x03TID\x12\x10\n" +
@@ -1753,11 +2833,17 @@ const file_pb_agent_proto_rawDesc = "" +
 	"\x05Count\x18\x04 \x01(\rR\x05Count\x12%\n" +
 	"\x04Info\x18\x05 \x01(\v2\x11.pb.Gift.GiftInfoR\x04Info\x125\n" +
 	"\fOriginalInfo\x18\x06 \x01(\v2\x11.pb.Gift.GiftInfoR\fOriginalInfo\x12\x14\n" +
-	"\x05Medal\x18\a \x01(\x04R\x05Medal\x1aD\n" +
+	"\x05Medal\x18\a \x01(\x04R\x05Medal\x12%\n" +
+	"\x04Coin\x18\b \x01(\x0e2\x11.pb.Gift.CoinTypeR\x04Coin\x1aD\n" +
 	"\bGiftInfo\x12\x0e\n" +
 	"\x02ID\x18\x01 \x01(\rR\x02ID\x12\x12\n" +
 	"\x04Name\x18\x02 \x01(\tR\x04Name\x12\x14\n" +
-	"\x05Price\x18\x03 \x01(\rR\x05Price\"\xd7\x01\n" +
+	"\x05Price\x18\x03 \x01(\rR\x05Price\"1\n" +
+	"\bCoinType\x12\x0f\n" +
+	"\vUnknownCoin\x10\x00\x12\b\n" +
+	"\x04Gold\x10\x01\x12\n" +
+	"\n" +
+	"\x06Silver\x10\x02\"\xd7\x01\n" +
 	"\x05Guard\x12$\n" +
 	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x10\n" +
 	"\x03UID\x18\x02 \x01(\x04R\x03UID\x12\x14\n" +
@@ -1789,7 +2875,68 @@ const file_pb_agent_proto_rawDesc = "" +
 	"\x03UID\x18\x03 \x01(\x04R\x03UID\x122\n" +
 	"\n" +
 	"GuardLevel\x18\x04 \x01(\x0e2\x12.pb.GuardLevelTypeR\n" +
-	"GuardLevel*E\n" +
+	"GuardLevel\"\xf3\x01\n" +
+	"\bInteract\x12$\n" +
+	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x10\n" +
+	"\x03UID\x18\x02 \x01(\x04R\x03UID\x12-\n" +
+	"\x04Type\x18\x03 \x01(\x0e2\x19.pb.Interact.InteractTypeR\x04Type\x12\x14\n" +
+	"\x05Medal\x18\x04 \x01(\x04R\x05Medal\"j\n" +
+	"\fInteractType\x12\x13\n" +
+	"\x0fUnknownInteract\x10\x00\x12\t\n" +
+	"\x05Enter\x10\x01\x12\n" +
+	"\n" +
+	"\x06Follow\x10\x02\x12\t\n" +
+	"\x05Share\x10\x03\x12\x11\n" +
+	"\rSpecialFollow\x10\x04\x12\x10\n" +
+	"\fMutualFollow\x10\x05\"G\n" +
+	"\tLikeCount\x12$\n" +
+	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x14\n" +
+	"\x05Count\x18\x02 \x01(\rR\x05Count\"\xc8\x01\n" +
+	"\n" +
+	"RoomChange\x12$\n" +
+	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x14\n" +
+	"\x05Title\x18\x02 \x01(\tR\x05Title\x12\x16\n" +
+	"\x06AreaID\x18\x03 \x01(\rR\x06AreaID\x12\x1a\n" +
+	"\bAreaName\x18\x04 \x01(\tR\bAreaName\x12\"\n" +
+	"\fParentAreaID\x18\x05 \x01(\rR\fParentAreaID\x12&\n" +
+	"\x0eParentAreaName\x18\x06 \x01(\tR\x0eParentAreaName\"|\n" +
+	"\n" +
+	"LiveStatus\x12$\n" +
+	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x12\n" +
+	"\x04Live\x18\x02 \x01(\bR\x04Live\x12\x1a\n" +
+	"\bLiveTime\x18\x03 \x01(\x04R\bLiveTime\x12\x18\n" +
+	"\aLiveKey\x18\x04 \x01(\tR\aLiveKey\"J\n" +
+	"\fWatchedCount\x12$\n" +
+	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x14\n" +
+	"\x05Count\x18\x02 \x01(\rR\x05Count\"a\n" +
+	"\tFansCount\x12$\n" +
+	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12\x12\n" +
+	"\x04Fans\x18\x02 \x01(\rR\x04Fans\x12\x1a\n" +
+	"\bFansClub\x18\x03 \x01(\rR\bFansClub\"s\n" +
+	"\tStreamGap\x12$\n" +
+	"\x04Meta\x18\x01 \x01(\v2\x10.pb.BasicMsgMetaR\x04Meta\x12$\n" +
+	"\rPreviousAgent\x18\x02 \x01(\tR\rPreviousAgent\x12\x1a\n" +
+	"\bLastSeen\x18\x03 \x01(\x04R\bLastSeen\"4\n" +
+	"\n" +
+	"EventFrame\x12\x12\n" +
+	"\x04Type\x18\x01 \x01(\tR\x04Type\x12\x12\n" +
+	"\x04Data\x18\x02 \x01(\fR\x04Data\"\xfb\x02\n" +
+	"\tRoomStats\x12\x16\n" +
+	"\x06RoomID\x18\x01 \x01(\x04R\x06RoomID\x12\x16\n" +
+	"\x06Window\x18\x02 \x01(\tR\x06Window\x12\x14\n" +
+	"\x05Start\x18\x03 \x01(\x04R\x05Start\x12\x10\n" +
+	"\x03End\x18\x04 \x01(\x04R\x03End\x12\x16\n" +
+	"\x06Damaku\x18\x05 \x01(\rR\x06Damaku\x12(\n" +
+	"\x0fDamakuPerMinute\x18\x06 \x01(\x01R\x0fDamakuPerMinute\x12\x1a\n" +
+	"\bChatters\x18\a \x01(\rR\bChatters\x12 \n" +
+	"\vGiftRevenue\x18\b \x01(\x04R\vGiftRevenue\x12\x1e\n" +
+	"\n" +
+	"GuardCount\x18\t \x01(\rR\n" +
+	"GuardCount\x12\"\n" +
+	"\fGuardRevenue\x18\n" +
+	" \x01(\x04R\fGuardRevenue\x12&\n" +
+	"\x0eSuperChatCount\x18\v \x01(\rR\x0eSuperChatCount\x12*\n" +
+	"\x10SuperChatRevenue\x18\f \x01(\x04R\x10SuperChatRevenue*E\n" +
 	"\x0eGuardLevelType\x12\v\n" +
 	"\aNoGuard\x10\x00\x12\f\n" +
 	"\bGovernor\x10\x01\x12\v\n" +
@@ -1808,66 +2955,94 @@ func file_pb_agent_proto_rawDescGZIP() []byte {
 	return file_pb_agent_proto_rawDescData
 }
 
-var file_pb_agent_proto_enumTypes = make([]protoimpl.EnumInfo, 8)
-var file_pb_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_pb_agent_proto_enumTypes = make([]protoimpl.EnumInfo, 11)
+var file_pb_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 33)
 var file_pb_agent_proto_goTypes = []any{
 	(GuardLevelType)(0),                  // 0: pb.GuardLevelType
 	(AgentControlResponse_StatusType)(0), // 1: pb.AgentControlResponse.StatusType
 	(AgentInfo_AgentType)(0),             // 2: pb.AgentInfo.AgentType
 	(AgentAction_AgentActionType)(0),     // 3: pb.AgentAction.AgentActionType
-	(AgentStatus_BufferType)(0),          // 4: pb.AgentStatus.BufferType
-	(AgentStatus_MetaCacheType)(0),       // 5: pb.AgentStatus.MetaCacheType
-	(BasicMsgMeta_TraceStep)(0),          // 6: pb.BasicMsgMeta.TraceStep
-	(Guard_GuardGiftType)(0),             // 7: pb.Guard.GuardGiftType
-	(*AgentControlResponse)(nil),         // 8: pb.AgentControlResponse
-	(*AgentInfo)(nil),                    // 9: pb.AgentInfo
-	(*AgentInit)(nil),                    // 10: pb.AgentInit
-	(*AgentAction)(nil),                  // 11: pb.AgentAction
-	(*AgentStatus)(nil),                  // 12: pb.AgentStatus
-	(*FansMedalMeta)(nil),                // 13: pb.FansMedalMeta
-	(*UserInfoMeta)(nil),                 // 14: pb.UserInfoMeta
-	(*BasicMsgMeta)(nil),                 // 15: pb.BasicMsgMeta
-	(*Damaku)(nil),                       // 16: pb.Damaku
-	(*Gift)(nil),                         // 17: pb.Gift
-	(*Guard)(nil),                        // 18: pb.Guard
-	(*SuperChat)(nil),                    // 19: pb.SuperChat
-	(*OnlineRankCount)(nil),              // 20: pb.OnlineRankCount
-	(*OnlineRankV2)(nil),                 // 21: pb.OnlineRankV2
-	nil,                                  // 22: pb.AgentInit.HeaderEntry
-	nil,                                  // 23: pb.AgentStatus.BufferEventCountEntry
-	nil,                                  // 24: pb.AgentStatus.MetaCacheEntry
-	(*AgentStatus_MetaCacheInfo)(nil),    // 25: pb.AgentStatus.MetaCacheInfo
-	nil,                                  // 26: pb.BasicMsgMeta.TraceEntry
-	(*Gift_GiftInfo)(nil),                // 27: pb.Gift.GiftInfo
-	(*OnlineRankV2_OnlineRankList)(nil),  // 28: pb.OnlineRankV2.OnlineRankList
+	(AgentStatus_AgentState)(0),          // 4: pb.AgentStatus.AgentState
+	(AgentStatus_BufferType)(0),          // 5: pb.AgentStatus.BufferType
+	(AgentStatus_MetaCacheType)(0),       // 6: pb.AgentStatus.MetaCacheType
+	(BasicMsgMeta_TraceStep)(0),          // 7: pb.BasicMsgMeta.TraceStep
+	(Gift_CoinType)(0),                   // 8: pb.Gift.CoinType
+	(Guard_GuardGiftType)(0),             // 9: pb.Guard.GuardGiftType
+	(Interact_InteractType)(0),           // 10: pb.Interact.InteractType
+	(*AgentControlResponse)(nil),         // 11: pb.AgentControlResponse
+	(*AgentInfo)(nil),                    // 12: pb.AgentInfo
+	(*AgentInit)(nil),                    // 13: pb.AgentInit
+	(*AgentCredential)(nil),              // 14: pb.AgentCredential
+	(*AgentAction)(nil),                  // 15: pb.AgentAction
+	(*AgentStatus)(nil),                  // 16: pb.AgentStatus
+	(*FansMedalMeta)(nil),                // 17: pb.FansMedalMeta
+	(*UserInfoMeta)(nil),                 // 18: pb.UserInfoMeta
+	(*BasicMsgMeta)(nil),                 // 19: pb.BasicMsgMeta
+	(*Damaku)(nil),                       // 20: pb.Damaku
+	(*Gift)(nil),                         // 21: pb.Gift
+	(*Guard)(nil),                        // 22: pb.Guard
+	(*SuperChat)(nil),                    // 23: pb.SuperChat
+	(*OnlineRankCount)(nil),              // 24: pb.OnlineRankCount
+	(*OnlineRankV2)(nil),                 // 25: pb.OnlineRankV2
+	(*Interact)(nil),                     // 26: pb.Interact
+	(*LikeCount)(nil),                    // 27: pb.LikeCount
+	(*RoomChange)(nil),                   // 28: pb.RoomChange
+	(*LiveStatus)(nil),                   // 29: pb.LiveStatus
+	(*WatchedCount)(nil),                 // 30: pb.WatchedCount
+	(*FansCount)(nil),                    // 31: pb.FansCount
+	(*StreamGap)(nil),                    // 32: pb.StreamGap
+	(*EventFrame)(nil),                   // 33: pb.EventFrame
+	(*RoomStats)(nil),                    // 34: pb.RoomStats
+	nil,                                  // 35: pb.AgentInit.HeaderEntry
+	nil,                                  // 36: pb.AgentCredential.HeaderEntry
+	nil,                                  // 37: pb.AgentStatus.BufferEventCountEntry
+	nil,                                  // 38: pb.AgentStatus.MetaCacheEntry
+	nil,                                  // 39: pb.AgentStatus.BufferDroppedEntry
+	(*AgentStatus_MetaCacheInfo)(nil),    // 40: pb.AgentStatus.MetaCacheInfo
+	nil,                                  // 41: pb.BasicMsgMeta.TraceEntry
+	(*Gift_GiftInfo)(nil),                // 42: pb.Gift.GiftInfo
+	(*OnlineRankV2_OnlineRankList)(nil),  // 43: pb.OnlineRankV2.OnlineRankList
 }
 var file_pb_agent_proto_depIdxs = []int32{
 	1,  // 0: pb.AgentControlResponse.Status:type_name -> pb.AgentControlResponse.StatusType
 	2,  // 1: pb.AgentInfo.Type:type_name -> pb.AgentInfo.AgentType
-	22, // 2: pb.AgentInit.Header:type_name -> pb.AgentInit.HeaderEntry
-	3,  // 3: pb.AgentAction.Type:type_name -> pb.AgentAction.AgentActionType
-	15, // 4: pb.AgentStatus.Meta:type_name -> pb.BasicMsgMeta
-	23, // 5: pb.AgentStatus.BufferEventCount:type_name -> pb.AgentStatus.BufferEventCountEntry
-	24, // 6: pb.AgentStatus.MetaCache:type_name -> pb.AgentStatus.MetaCacheEntry
-	0,  // 7: pb.FansMedalMeta.GuardLevel:type_name -> pb.GuardLevelType
-	26, // 8: pb.BasicMsgMeta.Trace:type_name -> pb.BasicMsgMeta.TraceEntry
-	15, // 9: pb.Damaku.Meta:type_name -> pb.BasicMsgMeta
-	15, // 10: pb.Gift.Meta:type_name -> pb.BasicMsgMeta
-	27, // 11: pb.Gift.Info:type_name -> pb.Gift.GiftInfo
-	27, // 12: pb.Gift.OriginalInfo:type_name -> pb.Gift.GiftInfo
-	15, // 13: pb.Guard.Meta:type_name -> pb.BasicMsgMeta
-	7,  // 14: pb.Guard.GiftType:type_name -> pb.Guard.GuardGiftType
-	15, // 15: pb.SuperChat.Meta:type_name -> pb.BasicMsgMeta
-	15, // 16: pb.OnlineRankCount.Meta:type_name -> pb.BasicMsgMeta
-	15, // 17: pb.OnlineRankV2.Meta:type_name -> pb.BasicMsgMeta
-	28, // 18: pb.OnlineRankV2.list:type_name -> pb.OnlineRankV2.OnlineRankList
-	25, // 19: pb.AgentStatus.MetaCacheEntry.value:type_name -> pb.AgentStatus.MetaCacheInfo
-	0,  // 20: pb.OnlineRankV2.OnlineRankList.GuardLevel:type_name -> pb.GuardLevelType
-	21, // [21:21] is the sub-list for method output_type
-	21, // [21:21] is the sub-list for method input_type
-	21, // [21:21] is the sub-list for extension type_name
-	21, // [21:21] is the sub-list for extension extendee
-	0,  // [0:21] is the sub-list for field type_name
+	35, // 2: pb.AgentInit.Header:type_name -> pb.AgentInit.HeaderEntry
+	36, // 3: pb.AgentCredential.Header:type_name -> pb.AgentCredential.HeaderEntry
+	3,  // 4: pb.AgentAction.Type:type_name -> pb.AgentAction.AgentActionType
+	14, // 5: pb.AgentAction.Credential:type_name -> pb.AgentCredential
+	19, // 6: pb.AgentStatus.Meta:type_name -> pb.BasicMsgMeta
+	37, // 7: pb.AgentStatus.BufferEventCount:type_name -> pb.AgentStatus.BufferEventCountEntry
+	38, // 8: pb.AgentStatus.MetaCache:type_name -> pb.AgentStatus.MetaCacheEntry
+	4,  // 9: pb.AgentStatus.State:type_name -> pb.AgentStatus.AgentState
+	39, // 10: pb.AgentStatus.BufferDropped:type_name -> pb.AgentStatus.BufferDroppedEntry
+	0,  // 11: pb.FansMedalMeta.GuardLevel:type_name -> pb.GuardLevelType
+	41, // 12: pb.BasicMsgMeta.Trace:type_name -> pb.BasicMsgMeta.TraceEntry
+	19, // 13: pb.Damaku.Meta:type_name -> pb.BasicMsgMeta
+	19, // 14: pb.Gift.Meta:type_name -> pb.BasicMsgMeta
+	42, // 15: pb.Gift.Info:type_name -> pb.Gift.GiftInfo
+	42, // 16: pb.Gift.OriginalInfo:type_name -> pb.Gift.GiftInfo
+	8,  // 17: pb.Gift.Coin:type_name -> pb.Gift.CoinType
+	19, // 18: pb.Guard.Meta:type_name -> pb.BasicMsgMeta
+	9,  // 19: pb.Guard.GiftType:type_name -> pb.Guard.GuardGiftType
+	19, // 20: pb.SuperChat.Meta:type_name -> pb.BasicMsgMeta
+	19, // 21: pb.OnlineRankCount.Meta:type_name -> pb.BasicMsgMeta
+	19, // 22: pb.OnlineRankV2.Meta:type_name -> pb.BasicMsgMeta
+	43, // 23: pb.OnlineRankV2.list:type_name -> pb.OnlineRankV2.OnlineRankList
+	19, // 24: pb.Interact.Meta:type_name -> pb.BasicMsgMeta
+	10, // 25: pb.Interact.Type:type_name -> pb.Interact.InteractType
+	19, // 26: pb.LikeCount.Meta:type_name -> pb.BasicMsgMeta
+	19, // 27: pb.RoomChange.Meta:type_name -> pb.BasicMsgMeta
+	19, // 28: pb.LiveStatus.Meta:type_name -> pb.BasicMsgMeta
+	19, // 29: pb.WatchedCount.Meta:type_name -> pb.BasicMsgMeta
+	19, // 30: pb.FansCount.Meta:type_name -> pb.BasicMsgMeta
+	19, // 31: pb.StreamGap.Meta:type_name -> pb.BasicMsgMeta
+	40, // 32: pb.AgentStatus.MetaCacheEntry.value:type_name -> pb.AgentStatus.MetaCacheInfo
+	0,  // 33: pb.OnlineRankV2.OnlineRankList.GuardLevel:type_name -> pb.GuardLevelType
+	34, // [34:34] is the sub-list for method output_type
+	34, // [34:34] is the sub-list for method input_type
+	34, // [34:34] is the sub-list for extension type_name
+	34, // [34:34] is the sub-list for extension extendee
+	0,  // [0:34] is the sub-list for field type_name
 }
 
 func init() { file_pb_agent_proto_init() }
@@ -1878,15 +3053,16 @@ func file_pb_agent_proto_init() {
 	file_pb_agent_proto_msgTypes[0].OneofWrappers = []any{}
 	file_pb_agent_proto_msgTypes[2].OneofWrappers = []any{}
 	file_pb_agent_proto_msgTypes[3].OneofWrappers = []any{}
-	file_pb_agent_proto_msgTypes[6].OneofWrappers = []any{}
+	file_pb_agent_proto_msgTypes[4].OneofWrappers = []any{}
 	file_pb_agent_proto_msgTypes[7].OneofWrappers = []any{}
+	file_pb_agent_proto_msgTypes[8].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pb_agent_proto_rawDesc), len(file_pb_agent_proto_rawDesc)),
-			NumEnums:      8,
-			NumMessages:   21,
+			NumEnums:      11,
+			NumMessages:   33,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
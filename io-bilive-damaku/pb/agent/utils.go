@@ -3,14 +3,42 @@ package agent
 import (
 	"github.com/nats-io/nats.go"
 	"google.golang.org/protobuf/proto"
+	"runtime/debug"
 	"time"
 )
 
 var (
 	VERSION = uint32(1)
+	BUILD   = "" // override by -ldflags "-X github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent.BUILD=xxx"
 )
 
+// BuildVersion return BUILD, or vcs revision from build info
+func BuildVersion() string {
+	if BUILD != "" {
+		return BUILD
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+			return setting.Value[:7]
+		}
+	}
+	return info.Main.Version
+}
+
+// isJetStreamMsg the reply subject of JetStream msg is used for ack, never respond to it
+func isJetStreamMsg(msg *nats.Msg) bool {
+	_, err := msg.Metadata()
+	return err == nil
+}
+
 func ControlSuccess(controlMsg *nats.Msg) error {
+	if isJetStreamMsg(controlMsg) {
+		return nil
+	}
 	resp := &AgentControlResponse{Status: AgentControlResponse_OK}
 	data, err := proto.Marshal(resp)
 	if err != nil {
@@ -20,6 +48,9 @@ func ControlSuccess(controlMsg *nats.Msg) error {
 }
 
 func ControlError(controlMsg *nats.Msg, err error) error {
+	if isJetStreamMsg(controlMsg) {
+		return nil
+	}
 	errStr := err.Error()
 	resp := &AgentControlResponse{Status: AgentControlResponse_Err, Error: &errStr}
 	data, err := proto.Marshal(resp)
@@ -29,6 +60,40 @@ func ControlError(controlMsg *nats.Msg, err error) error {
 	return controlMsg.Respond(data)
 }
 
+// Saturation of the busiest event handler worker, 1 means its buffer is full
+func (x *AgentStatus) Saturation() float64 {
+	workers := len(x.GetWorkerBacklog())
+	if workers == 0 || x.GetBufferSize() == 0 {
+		return 0
+	}
+	capacity := float64(x.GetBufferSize()) / float64(workers)
+	var busiest uint32
+	for _, backlog := range x.GetWorkerBacklog() {
+		busiest = max(busiest, backlog)
+	}
+	return float64(busiest) / capacity
+}
+
+// Revenue of gift in gold seeds, silver gifts are free
+func (x *Gift) Revenue() uint64 {
+	if x.GetCoin() == Gift_Silver {
+		return 0
+	}
+	return uint64(x.GetInfo().GetPrice()) * uint64(x.GetCount())
+}
+
+// CoinTypeOf bili coin_type of gift
+func CoinTypeOf(coinType string) Gift_CoinType {
+	switch coinType {
+	case "gold":
+		return Gift_Gold
+	case "silver":
+		return Gift_Silver
+	default:
+		return Gift_UnknownCoin
+	}
+}
+
 type MetaBuilder func() *BasicMsgMeta
 
 func NewMsgMetaBuilder(agentId string) MetaBuilder {
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"k8s.io/klog/v2"
+)
+
+// agentSnapshot is the view of an agent used by placement, copied out of AgentStatus lock
+type agentSnapshot struct {
+	ID         string
+	Alive      bool // ready, may be draining
+	Ready      bool // ready and not draining, can be placed rooms
+	Paused     bool
+	Watching   []uint64
+	BufferUsed uint32
+	MaxRooms   uint32 // 0 for unlimited
+	LastSeen   uint64 // MilliTimestamp of the latest status
+}
+
+// snapshot all agents that have reported status, playback agents only replay records and never watch live rooms
+func (m *AgentManager) snapshot() map[string]*agentSnapshot {
+	agents := make(map[string]*agentSnapshot)
+	m.managed.Range(func(_, value any) bool {
+		a := value.(*AgentStatus)
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+		if a.CachedStatus == nil || a.Info.GetType() == agent.AgentInfo_PlaybackAgent {
+			return true
+		}
+		agents[a.ID] = &agentSnapshot{
+			ID:         a.ID,
+			Alive:      a.IsReady(),
+			Ready:      a.IsReady() && !a.Draining,
+			Paused:     a.Paused,
+			Watching:   slices.Clone(a.CachedStatus.Watching),
+			BufferUsed: a.CachedStatus.BufferUsed,
+			LastSeen:   a.CachedStatus.Meta.GetTimeStamp(),
+		}
+		if a.Info != nil {
+			agents[a.ID].MaxRooms = a.Info.MaxRooms
+		}
+		return true
+	})
+	return agents
+}
+
+// Sharded return true if rooms are placed to part of agents instead of broadcasting to all agents
+func (m *AgentManager) Sharded() bool {
+	return m.centerCtx.Config.Controller.ReplicationFactor > 0
+}
+
+// Placement return the agents that room placed to, nil if not sharded
+func (m *AgentManager) Placement(room uint64) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.placement[room])
+}
+
+// wantRoom decide whether agent should watch the room
+func (m *AgentManager) wantRoom(agentId string, room uint64) bool {
+	if !m.Sharded() {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Contains(m.placement[room], agentId)
+}
+
+// releasable return true if all target agents of room are watching it,
+// so that a room moved away can be deleted from old agent without gap, dedup will cover the overlap
+func (m *AgentManager) releasable(room uint64, agents map[string]*agentSnapshot) bool {
+	if !m.Sharded() {
+		// any other agent is watching
+		for _, a := range agents {
+			if a.Ready && slices.Contains(a.Watching, room) {
+				return true
+			}
+		}
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, id := range m.placement[room] {
+		if a, ok := agents[id]; !ok || !slices.Contains(a.Watching, room) {
+			return false
+		}
+	}
+	return true
+}
+
+// place rooms to ready agents with replication factor, keep previous placement as much as possible,
+// then fill rooms to least loaded agents and move limited rooms from the most loaded agent to the least one
+func (m *AgentManager) place(rooms []uint64, agents map[string]*agentSnapshot) {
+	if !m.Sharded() {
+		return
+	}
+	var ready []*agentSnapshot
+	for _, a := range agents {
+		if a.Ready {
+			ready = append(ready, a)
+		}
+	}
+	if len(ready) == 0 {
+		return // keep placement until any agent back
+	}
+	m.mu.RLock()
+	previous := m.placement
+	m.mu.RUnlock()
+	k := min(m.centerCtx.Config.Controller.ReplicationFactor, len(ready))
+	isReady := func(id string) bool {
+		a, ok := agents[id]
+		return ok && a.Ready
+	}
+	load := make(map[string]int, len(ready))
+	full := func(a *agentSnapshot) bool {
+		return a.MaxRooms > 0 && load[a.ID] >= int(a.MaxRooms)
+	}
+	placement := make(map[uint64][]string, len(rooms))
+	slices.Sort(rooms)
+	// keep previous placement, or current watching agents after leader changed, under max rooms of agent
+	for _, room := range rooms {
+		targets := slices.DeleteFunc(slices.Clone(previous[room]), func(id string) bool { return !isReady(id) })
+		if len(targets) == 0 {
+			for _, a := range ready {
+				if slices.Contains(a.Watching, room) {
+					targets = append(targets, a.ID)
+				}
+			}
+			slices.Sort(targets)
+		}
+		kept := targets[:0]
+		for _, id := range targets {
+			if len(kept) < k && !full(agents[id]) {
+				kept = append(kept, id)
+				load[id]++
+			}
+		}
+		placement[room] = kept
+	}
+	// least loaded first, buffer used as the second load factor
+	byLoad := func(a, b *agentSnapshot) int {
+		return cmp.Or(cmp.Compare(load[a.ID], load[b.ID]), cmp.Compare(a.BufferUsed, b.BufferUsed), cmp.Compare(a.ID, b.ID))
+	}
+	lacked := 0
+	for _, room := range rooms {
+		for len(placement[room]) < k {
+			slices.SortFunc(ready, byLoad)
+			placed := false
+			for _, a := range ready {
+				if !slices.Contains(placement[room], a.ID) && !full(a) {
+					placement[room] = append(placement[room], a.ID)
+					load[a.ID]++
+					placed = true
+					break
+				}
+			}
+			if !placed {
+				lacked++
+				break
+			}
+		}
+	}
+	if lacked > 0 {
+		klog.Warningf("agents reached max rooms, %d rooms placed to less than %d agents", lacked, k)
+	}
+	// rebalance
+	for moves := 0; moves < m.centerCtx.Config.Controller.RebalanceMoves; moves++ {
+		slices.SortFunc(ready, byLoad)
+		least, most := ready[0], ready[len(ready)-1]
+		if load[most.ID]-load[least.ID] <= 1 || full(least) {
+			break
+		}
+		moved := false
+		for _, room := range rooms {
+			targets := placement[room]
+			if i := slices.Index(targets, most.ID); i >= 0 && !slices.Contains(targets, least.ID) {
+				targets[i] = least.ID
+				load[most.ID]--
+				load[least.ID]++
+				klog.Infof("room(%d) moved from agent(%s) to agent(%s)", room, most.ID, least.ID)
+				moved = true
+				break
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	m.mu.Lock()
+	m.placement = placement
+	m.mu.Unlock()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func newTestAgentManager(replication, moves int) *AgentManager {
+	config := NewConfig()
+	config.Controller.ReplicationFactor = replication
+	config.Controller.RebalanceMoves = moves
+	return &AgentManager{
+		centerCtx: &CenterContext{Config: config},
+		masters:   make(map[uint64]string),
+	}
+}
+
+// roomsOf count rooms placed to every agent
+func roomsOf(placement map[uint64][]string) map[string]int {
+	load := make(map[string]int)
+	for _, targets := range placement {
+		for _, id := range targets {
+			load[id]++
+		}
+	}
+	return load
+}
+
+func TestPlace(t *testing.T) {
+	rooms := []uint64{1, 2, 3, 4, 5, 6}
+	cases := []struct {
+		name        string
+		replication int
+		moves       int
+		agents      []*agentSnapshot
+		previous    map[uint64][]string
+		want        map[string]int // agent:rooms
+	}{
+		{
+			name:        "spread",
+			replication: 1,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true}, {ID: "b", Ready: true}},
+			want:        map[string]int{"a": 3, "b": 3},
+		},
+		{
+			name:        "draining agent excluded",
+			replication: 2,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true}, {ID: "b", Ready: true}, {ID: "c", Alive: true}},
+			previous:    map[uint64][]string{1: {"a", "c"}, 2: {"c"}},
+			want:        map[string]int{"a": 6, "b": 6},
+		},
+		{
+			name:        "replication limited by agents",
+			replication: 3,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true}, {ID: "b", Ready: true}},
+			want:        map[string]int{"a": 6, "b": 6},
+		},
+		{
+			name:        "max rooms",
+			replication: 1,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true, MaxRooms: 1}, {ID: "b", Ready: true}},
+			previous:    map[uint64][]string{1: {"a"}, 2: {"a"}, 3: {"a"}},
+			want:        map[string]int{"a": 1, "b": 5},
+		},
+		{
+			name:        "max rooms of all agents",
+			replication: 1,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true, MaxRooms: 2}, {ID: "b", Ready: true, MaxRooms: 2}},
+			want:        map[string]int{"a": 2, "b": 2},
+		},
+		{
+			name:        "rebalance limited",
+			replication: 1,
+			moves:       2,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true}, {ID: "b", Ready: true}},
+			previous:    map[uint64][]string{1: {"a"}, 2: {"a"}, 3: {"a"}, 4: {"a"}, 5: {"a"}, 6: {"a"}},
+			want:        map[string]int{"a": 4, "b": 2},
+		},
+		{
+			name:        "rebalance until balanced",
+			replication: 1,
+			moves:       10,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true}, {ID: "b", Ready: true}},
+			previous:    map[uint64][]string{1: {"a"}, 2: {"a"}, 3: {"a"}, 4: {"a"}, 5: {"a"}, 6: {"a"}},
+			want:        map[string]int{"a": 3, "b": 3},
+		},
+		{
+			name:        "no rebalance",
+			replication: 1,
+			agents:      []*agentSnapshot{{ID: "a", Ready: true}, {ID: "b", Ready: true}},
+			previous:    map[uint64][]string{1: {"a"}, 2: {"a"}, 3: {"a"}, 4: {"a"}, 5: {"a"}, 6: {"a"}},
+			want:        map[string]int{"a": 6},
+		},
+		{
+			name:        "watching after leader changed",
+			replication: 1,
+			agents: []*agentSnapshot{
+				{ID: "a", Ready: true, Watching: []uint64{1, 2, 3, 4, 5}},
+				{ID: "b", Ready: true, Watching: []uint64{6}},
+			},
+			want: map[string]int{"a": 5, "b": 1},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newTestAgentManager(c.replication, c.moves)
+			m.placement = c.previous
+			agents := make(map[string]*agentSnapshot)
+			for _, a := range c.agents {
+				agents[a.ID] = a
+			}
+			m.place(slices.Clone(rooms), agents)
+			load := roomsOf(m.placement)
+			if len(load) != len(c.want) {
+				t.Fatalf("unexpected load: %v, want %v", load, c.want)
+			}
+			for id, n := range c.want {
+				if load[id] != n {
+					t.Fatalf("unexpected load: %v, want %v", load, c.want)
+				}
+			}
+			for room, targets := range m.placement {
+				if len(slices.Compact(slices.Sorted(slices.Values(targets)))) != len(targets) {
+					t.Fatalf("room(%d) placed to the same agent twice: %v", room, targets)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaceKeepPrevious(t *testing.T) {
+	m := newTestAgentManager(1, 4)
+	previous := map[uint64][]string{1: {"a"}, 2: {"b"}, 3: {"a"}, 4: {"b"}}
+	m.placement = previous
+	m.place([]uint64{1, 2, 3, 4}, map[string]*agentSnapshot{"a": {ID: "a", Ready: true}, "b": {ID: "b", Ready: true}})
+	for room, targets := range previous {
+		if !slices.Equal(m.placement[room], targets) {
+			t.Fatalf("placement of room(%d) changed: %v -> %v", room, targets, m.placement[room])
+		}
+	}
+	// keep placement until any agent back
+	m.place([]uint64{1, 2, 3, 4}, map[string]*agentSnapshot{"a": {ID: "a", Alive: true}})
+	if len(m.placement) != len(previous) {
+		t.Fatalf("placement dropped without ready agent: %v", m.placement)
+	}
+}
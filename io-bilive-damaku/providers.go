@@ -1,37 +1,85 @@
 package main
 
 import (
+	"fmt"
 	"github.com/TiyaAnlite/FocotServicesCommon/echox"
 	"github.com/labstack/echo/v4"
 	"k8s.io/klog/v2"
 	"net/http"
+	"slices"
 	"strconv"
 )
 
-type StaticConfigProvider struct {
-	Rooms []string `json:"rooms" yaml:"rooms"`
+// isStaticProvider room is the former type name of static provider, kept for old config
+func isStaticProvider(providerType string) bool {
+	return providerType == "static" || providerType == "room"
 }
 
-func (p *StaticConfigProvider) Init(*CenterContext) error {
-	return nil
+type StaticConfigProvider struct {
+	Rooms      []string `json:"rooms" yaml:"rooms"`
+	Credential string   `json:"credential" yaml:"credential"` // credential profile for all rooms
+	centerCtx  *CenterContext
+	configured map[uint64]bool // rooms of all static providers in config
 }
 
-func (p *StaticConfigProvider) Provide(c chan<- *ProvidedRoom) {
-	for _, room := range p.Rooms {
-		roomId, err := strconv.ParseUint(room, 10, 64)
-		if err != nil {
-			klog.Errorf("Failed to parse room id from config: %s", room)
+func (p *StaticConfigProvider) Init(ctx *CenterContext) error {
+	p.centerCtx = ctx
+	p.configured = make(map[uint64]bool)
+	for _, c := range ctx.Config.Provider {
+		if !isStaticProvider(c.Type) {
 			continue
 		}
-		c <- &ProvidedRoom{
-			ProviderName: "static",
-			RoomID:       roomId,
+		static := &StaticConfigProvider{}
+		if err := c.Decode(static); err != nil {
+			return fmt.Errorf("failed to decode static provider config: %s", err.Error())
+		}
+		for _, room := range static.Rooms {
+			if roomId, err := strconv.ParseUint(room, 10, 64); err == nil {
+				p.configured[roomId] = true
+			}
 		}
 	}
+	return nil
 }
 
-func (p *StaticConfigProvider) Revoke(chan<- uint64) {
+func (p *StaticConfigProvider) Provide(c chan<- *ProvidedRoom) {
+	// provide async, channel will be consumed after agent manager started
+	go func() {
+		for _, room := range p.Rooms {
+			roomId, err := strconv.ParseUint(room, 10, 64)
+			if err != nil {
+				klog.Errorf("Failed to parse room id from config: %s", room)
+				continue
+			}
+			c <- &ProvidedRoom{
+				ProviderName: "static",
+				RoomID:       roomId,
+				Credential:   p.Credential,
+			}
+		}
+	}()
+}
 
+// Revoke rooms still claimed by static in redis but removed from config, reconciled once at startup
+func (p *StaticConfigProvider) Revoke(c chan<- *ProvidedRoom) {
+	go func() {
+		store := &RoomStore{}
+		store.Init(p.centerCtx)
+		if err := store.Load(); err != nil {
+			klog.Errorf("[StaticConfigProvider]failed to reconcile rooms: %s", err.Error())
+			return
+		}
+		for _, room := range store.Rooms() {
+			if p.configured[room] || !slices.Contains(store.Providers(room), "static") {
+				continue
+			}
+			klog.Infof("[StaticConfigProvider]room(%d) removed from config", room)
+			c <- &ProvidedRoom{
+				ProviderName: "static",
+				RoomID:       room,
+			}
+		}
+	}()
 }
 
 type ApiConfigProvider struct {
@@ -60,14 +108,17 @@ func (p *ApiConfigProvider) Provide(r chan<- *ProvidedRoom) {
 	})
 }
 
-func (p *ApiConfigProvider) Revoke(r chan<- uint64) {
+func (p *ApiConfigProvider) Revoke(r chan<- *ProvidedRoom) {
 	p.e.DELETE(p.Path+"/:roomId", func(c echo.Context) error {
 		room := c.Param("roomId")
 		roomId, err := strconv.ParseUint(room, 10, 64)
 		if err != nil {
 			return echox.NormalErrorResponse(c, http.StatusBadRequest, http.StatusBadRequest, err.Error())
 		}
-		r <- roomId
+		r <- &ProvidedRoom{
+			ProviderName: "api",
+			RoomID:       roomId,
+		}
 		return echox.NormalResponse(c, http.StatusOK)
 	})
 }
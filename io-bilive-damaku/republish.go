@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+// Republisher publish deduplicated events to canonical subjects for downstream services,
+// it is a sink of MessageProcessor.
+// Subject: [prefix].[republish prefix].[room].[type], meta events without room use "meta" as room
+type Republisher struct {
+	centerCtx *CenterContext
+	prefix    string
+}
+
+func (r *Republisher) Init(ctx *CenterContext) {
+	r.centerCtx = ctx
+	r.prefix = fmt.Sprintf("%s.%s", ctx.Config.Global.Prefix, ctx.Config.Controller.Republish.Prefix)
+}
+
+// Handle normalize meta of event and publish it, the event is restored for the following handlers
+func (r *Republisher) Handle(event any) bool {
+	msg, ok := event.(proto.Message)
+	if !ok {
+		return true
+	}
+	eventType := EventTypeOf(event)
+	room := "meta"
+	meta := eventMeta(event)
+	var data []byte
+	var err error
+	if meta != nil {
+		room = fmt.Sprint(meta.GetRoomID())
+		// which agent delivered it and its trace are meaningless after dedup
+		agentId, trace := meta.Agent, meta.Trace
+		meta.Agent, meta.Trace = "", nil
+		data, err = proto.Marshal(msg)
+		meta.Agent, meta.Trace = agentId, trace
+	} else {
+		data, err = proto.Marshal(msg)
+	}
+	if err != nil {
+		klog.Errorf("[Republish]failed to marshal %s: %s", eventType, err.Error())
+		return true
+	}
+	if err := r.centerCtx.MQ.Publish(fmt.Sprintf("%s.%s.%s", r.prefix, room, eventType), data); err != nil {
+		klog.Errorf("[Republish]failed to publish %s: %s", eventType, err.Error())
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+)
+
+// unset provider flag and credential of room, remove the room if no provider left, return count of remaining providers
+var roomRevokeScript = redis.NewScript(`
+redis.call("SREM", KEYS[2], ARGV[2])
+redis.call("HDEL", KEYS[3], ARGV[2])
+local remaining = redis.call("SCARD", KEYS[2])
+if remaining == 0 then
+	redis.call("SREM", KEYS[1], ARGV[1])
+	redis.call("DEL", KEYS[3])
+end
+return remaining`)
+
+// RoomStore persist watched rooms and the providers that claim each room in redis,
+// shared by all controller replicas and reloaded after restarted
+type RoomStore struct {
+	centerCtx *CenterContext
+	key       string                       // set of rooms, providers of room are stored at [key]:[room]
+	rooms     map[uint64][]string          // room:providers, local cache
+	creds     map[uint64]map[string]string // room:provider:credential profile, local cache, stored at hash [key]:[room]:credential
+	mu        sync.RWMutex
+}
+
+func (s *RoomStore) Init(ctx *CenterContext) {
+	s.centerCtx = ctx
+	s.key = fmt.Sprintf("%s:controller:rooms", ctx.Config.Global.Prefix)
+	s.rooms = make(map[uint64][]string)
+	s.creds = make(map[uint64]map[string]string)
+}
+
+func (s *RoomStore) providerKey(room uint64) string {
+	return fmt.Sprintf("%s:%d", s.key, room)
+}
+
+func (s *RoomStore) credentialKey(room uint64) string {
+	return s.providerKey(room) + ":credential"
+}
+
+// Load all rooms from redis and replace local cache
+func (s *RoomStore) Load() error {
+	rdb := s.centerCtx.RDB.DB()
+	members, err := rdb.SMembers(s.centerCtx.Context, s.key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get rooms: %s", err.Error())
+	}
+	roomIds := make([]uint64, 0, len(members))
+	for _, member := range members {
+		room, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			klog.Warningf("[RoomStore]illegal room: %s", member)
+			continue
+		}
+		roomIds = append(roomIds, room)
+	}
+	cmds, err := rdb.Pipelined(s.centerCtx.Context, func(pipe redis.Pipeliner) error {
+		for _, room := range roomIds {
+			pipe.SMembers(s.centerCtx.Context, s.providerKey(room))
+			pipe.HGetAll(s.centerCtx.Context, s.credentialKey(room))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get room providers: %s", err.Error())
+	}
+	rooms := make(map[uint64][]string, len(roomIds))
+	creds := make(map[uint64]map[string]string)
+	for i, room := range roomIds {
+		providers := cmds[i*2].(*redis.StringSliceCmd).Val()
+		slices.Sort(providers)
+		rooms[room] = providers
+		if credentials := cmds[i*2+1].(*redis.MapStringStringCmd).Val(); len(credentials) > 0 {
+			creds[room] = credentials
+		}
+	}
+	s.mu.Lock()
+	s.rooms = rooms
+	s.creds = creds
+	s.mu.Unlock()
+	return nil
+}
+
+// Add a room claimed by provider with the credential profile attached by this provider,
+// an empty credential only clears the one of this provider, never the ones attached by others
+func (s *RoomStore) Add(room uint64, provider, credential string) error {
+	_, err := s.centerCtx.RDB.DB().TxPipelined(s.centerCtx.Context, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(s.centerCtx.Context, s.providerKey(room), provider)
+		pipe.SAdd(s.centerCtx.Context, s.key, room)
+		if credential != "" {
+			pipe.HSet(s.centerCtx.Context, s.credentialKey(room), provider, credential)
+		} else {
+			pipe.HDel(s.centerCtx.Context, s.credentialKey(room), provider)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add room %d: %s", room, err.Error())
+	}
+	s.mu.Lock()
+	if !slices.Contains(s.rooms[room], provider) {
+		s.rooms[room] = append(s.rooms[room], provider)
+		slices.Sort(s.rooms[room])
+	}
+	if credential != "" {
+		if s.creds[room] == nil {
+			s.creds[room] = make(map[string]string)
+		}
+		s.creds[room][provider] = credential
+	} else {
+		delete(s.creds[room], provider)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Revoke unset provider flag of room, room is removed when all providers revoked it.
+// Return count of providers still claim the room
+func (s *RoomStore) Revoke(room uint64, provider string) (int, error) {
+	remaining, err := roomRevokeScript.Run(s.centerCtx.Context, s.centerCtx.RDB.DB(),
+		[]string{s.key, s.providerKey(room), s.credentialKey(room)}, room, provider).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke room %d: %s", room, err.Error())
+	}
+	s.mu.Lock()
+	if remaining == 0 {
+		delete(s.rooms, room)
+		delete(s.creds, room)
+	} else {
+		s.rooms[room] = slices.DeleteFunc(s.rooms[room], func(p string) bool { return p == provider })
+		delete(s.creds[room], provider)
+	}
+	s.mu.Unlock()
+	return remaining, nil
+}
+
+// Rooms return all watched rooms from local cache
+func (s *RoomStore) Rooms() []uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rooms := make([]uint64, 0, len(s.rooms))
+	for room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Credential return the credential profile name of room, empty for global account.
+// If several providers attached one, the first of sorted providers is used
+func (s *RoomStore) Credential(room uint64) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, provider := range s.rooms[room] {
+		if cred := s.creds[room][provider]; cred != "" {
+			return cred
+		}
+	}
+	return ""
+}
+
+// Providers return the providers that claim the room
+func (s *RoomStore) Providers(room uint64) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.rooms[room])
+}
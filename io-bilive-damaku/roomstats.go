@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+// RoomStatsAggregator count events of every room in tumbling windows by event time, it is a sink of MessageProcessor.
+// A window is closed after its end plus lateness, then published to [prefix].stats.[window].[room]
+// and exported as gauges, events arrived after their window closed are dropped
+type RoomStatsAggregator struct {
+	centerCtx *CenterContext
+	windows   []time.Duration
+	lateness  time.Duration
+
+	mu      sync.Mutex
+	open    []map[roomWindowKey]*roomWindow // per window
+	closed  []time.Time                     // per window: windows start before it are closed
+	exposed []map[uint64]struct{}           // per window: rooms with gauges
+
+	mDamakuRate   *prometheus.GaugeVec
+	mChatters     *prometheus.GaugeVec
+	mGiftRevenue  *prometheus.GaugeVec
+	mGuards       *prometheus.GaugeVec
+	mGuardRevenue *prometheus.GaugeVec
+	mSCRevenue    *prometheus.GaugeVec
+	mLate         prometheus.Counter
+}
+
+type roomWindowKey struct {
+	room  uint64
+	start int64
+}
+
+type roomWindow struct {
+	stats *agent.RoomStats
+	uids  map[uint64]struct{}
+}
+
+func (s *RoomStatsAggregator) Init(ctx *CenterContext) error {
+	s.centerCtx = ctx
+	s.windows = ctx.Config.Controller.RoomStats.Windows
+	s.lateness = ctx.Config.Controller.RoomStats.Lateness
+	if len(s.windows) == 0 {
+		return fmt.Errorf("no window configured")
+	}
+	now := time.Now()
+	for _, window := range s.windows {
+		if window < time.Second {
+			return fmt.Errorf("window %s too small", window)
+		}
+		s.open = append(s.open, make(map[roomWindowKey]*roomWindow))
+		s.closed = append(s.closed, now.Truncate(window))
+		s.exposed = append(s.exposed, make(map[uint64]struct{}))
+	}
+	labels := []string{"room_id", "window"}
+	s.mDamakuRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_room_damaku_per_minute", Help: "damaku per minute of room in last window"}, labels)
+	s.mChatters = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_room_chatters", Help: "unique damaku uid of room in last window"}, labels)
+	s.mGiftRevenue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_room_gift_revenue", Help: "gift revenue of room in last window, gold seeds"}, labels)
+	s.mGuards = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_room_guards", Help: "guard purchases of room in last window"}, labels)
+	s.mGuardRevenue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_room_guard_revenue", Help: "guard revenue of room in last window, gold seeds"}, labels)
+	s.mSCRevenue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "blive_damaku_room_superchat_revenue", Help: "superChat revenue of room in last window, RMB"}, labels)
+	s.mLate = prometheus.NewCounter(
+		prometheus.CounterOpts{Name: "blive_damaku_room_stats_late_total", Help: "events dropped by closed window"})
+	ctx.Registry.MustRegister(s.mDamakuRate, s.mChatters, s.mGiftRevenue, s.mGuards, s.mGuardRevenue, s.mSCRevenue, s.mLate)
+	return nil
+}
+
+func (s *RoomStatsAggregator) Start() {
+	klog.Infof("starting room stats aggregator, windows: %v", s.windows)
+	s.centerCtx.Worker.Go(func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.tumble(now)
+			case <-s.centerCtx.Context.Done():
+				return
+			}
+		}
+	})
+}
+
+// Handle add event into current window of every size, only values are copied
+func (s *RoomStatsAggregator) Handle(event any) bool {
+	meta := eventMeta(event)
+	if meta == nil || meta.GetRoomID() == 0 {
+		return true
+	}
+	ts := eventTime(meta)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, window := range s.windows {
+		start := ts.Truncate(window)
+		if start.Before(s.closed[i]) {
+			s.mLate.Inc()
+			continue
+		}
+		rw := s.window(i, meta.GetRoomID(), start)
+		switch e := event.(type) {
+		case *agent.Damaku:
+			rw.stats.Damaku++
+			rw.uids[e.UID] = struct{}{}
+		case *agent.Gift:
+			rw.stats.GiftRevenue += e.Revenue()
+		case *agent.Guard:
+			rw.stats.GuardCount++
+			rw.stats.GuardRevenue += uint64(e.Price)
+		case *agent.SuperChat:
+			rw.stats.SuperChatCount++
+			rw.stats.SuperChatRevenue += uint64(e.Price)
+		}
+	}
+	return true
+}
+
+// window of room starts at start, opened by its first event
+func (s *RoomStatsAggregator) window(i int, room uint64, start time.Time) *roomWindow {
+	key := roomWindowKey{room: room, start: start.UnixMilli()}
+	rw, ok := s.open[i][key]
+	if !ok {
+		rw = &roomWindow{
+			stats: &agent.RoomStats{
+				RoomID: room,
+				Window: windowName(s.windows[i]),
+				Start:  uint64(start.UnixMilli()),
+				End:    uint64(start.Add(s.windows[i]).UnixMilli()),
+			},
+			uids: make(map[uint64]struct{}),
+		}
+		s.open[i][key] = rw
+	}
+	return rw
+}
+
+// tumble close windows ended before now - lateness, publish them and update gauges
+func (s *RoomStatsAggregator) tumble(now time.Time) {
+	for _, stats := range s.closeWindows(now) {
+		s.publish(stats)
+	}
+}
+
+// closeWindows remove windows ended before now - lateness and return their stats
+func (s *RoomStatsAggregator) closeWindows(now time.Time) (results []*agent.RoomStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, window := range s.windows {
+		closed := now.Add(-s.lateness).Truncate(window)
+		if !closed.After(s.closed[i]) {
+			continue
+		}
+		s.closed[i] = closed
+		reported := make(map[uint64]struct{})
+		for key, rw := range s.open[i] {
+			if rw.stats.End > uint64(closed.UnixMilli()) {
+				continue
+			}
+			delete(s.open[i], key)
+			rw.stats.Chatters = uint32(len(rw.uids))
+			rw.stats.DamakuPerMinute = float64(rw.stats.Damaku) / window.Minutes()
+			results = append(results, rw.stats)
+			reported[rw.stats.RoomID] = struct{}{}
+		}
+		// room without events in last window
+		for room := range s.exposed[i] {
+			if _, ok := reported[room]; !ok {
+				s.deleteGauges(room, windowName(window))
+			}
+		}
+		s.exposed[i] = reported
+	}
+	return
+}
+
+func (s *RoomStatsAggregator) publish(stats *agent.RoomStats) {
+	room := strconv.FormatUint(stats.RoomID, 10)
+	s.mDamakuRate.WithLabelValues(room, stats.Window).Set(stats.DamakuPerMinute)
+	s.mChatters.WithLabelValues(room, stats.Window).Set(float64(stats.Chatters))
+	s.mGiftRevenue.WithLabelValues(room, stats.Window).Set(float64(stats.GiftRevenue))
+	s.mGuards.WithLabelValues(room, stats.Window).Set(float64(stats.GuardCount))
+	s.mGuardRevenue.WithLabelValues(room, stats.Window).Set(float64(stats.GuardRevenue))
+	s.mSCRevenue.WithLabelValues(room, stats.Window).Set(float64(stats.SuperChatRevenue))
+	data, err := proto.Marshal(stats)
+	if err != nil {
+		klog.Errorf("[RoomStats]failed to marshal stats of room %d: %s", stats.RoomID, err.Error())
+		return
+	}
+	subject := fmt.Sprintf("%s.stats.%s.%d", s.centerCtx.Config.Global.Prefix, stats.Window, stats.RoomID)
+	if err := s.centerCtx.MQ.Publish(subject, data); err != nil {
+		klog.Errorf("[RoomStats]failed to publish stats of room %d: %s", stats.RoomID, err.Error())
+	}
+}
+
+func (s *RoomStatsAggregator) deleteGauges(room uint64, window string) {
+	roomId := strconv.FormatUint(room, 10)
+	for _, gauge := range []*prometheus.GaugeVec{s.mDamakuRate, s.mChatters, s.mGiftRevenue, s.mGuards, s.mGuardRevenue, s.mSCRevenue} {
+		gauge.DeleteLabelValues(roomId, window)
+	}
+}
+
+// windowName format window as 1h, 5m or 30s
+func windowName(window time.Duration) string {
+	switch {
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", window/time.Second)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestRoomStats(t *testing.T) *RoomStatsAggregator {
+	config := NewConfig()
+	config.Controller.RoomStats.Windows = []time.Duration{time.Minute}
+	config.Controller.RoomStats.Lateness = 10 * time.Second
+	s := &RoomStatsAggregator{}
+	if err := s.Init(&CenterContext{Config: config, Registry: prometheus.NewRegistry()}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testMeta(room uint64, ts time.Time) *agent.BasicMsgMeta {
+	return &agent.BasicMsgMeta{RoomID: &room, TimeStamp: uint64(ts.UnixMilli())}
+}
+
+func TestRoomStatsWindow(t *testing.T) {
+	s := newTestRoomStats(t)
+	room := uint64(1)
+	start := time.Now().Truncate(time.Minute).Add(time.Minute)
+	s.Handle(&agent.Damaku{Meta: testMeta(room, start.Add(time.Second)), UID: 1})
+	s.Handle(&agent.Damaku{Meta: testMeta(room, start.Add(2*time.Second)), UID: 1})
+	s.Handle(&agent.Damaku{Meta: testMeta(room, start.Add(3*time.Second)), UID: 2})
+	s.Handle(&agent.Gift{Meta: testMeta(room, start.Add(4*time.Second)), Count: 2, Info: &agent.Gift_GiftInfo{Price: 100}, Coin: agent.Gift_Gold})
+	s.Handle(&agent.Gift{Meta: testMeta(room, start.Add(4*time.Second)), Count: 1, Info: &agent.Gift_GiftInfo{Price: 1000}, Coin: agent.Gift_Silver})
+	s.Handle(&agent.Guard{Meta: testMeta(room, start.Add(5*time.Second)), Price: 198000})
+	// SuperChat time in seconds from old agents
+	s.Handle(&agent.SuperChat{Meta: &agent.BasicMsgMeta{RoomID: &room, TimeStamp: uint64(start.Add(6 * time.Second).Unix())}, Price: 30})
+	if late := testutil.ToFloat64(s.mLate); late != 0 {
+		t.Fatalf("unexpected late events: %f", late)
+	}
+
+	if results := s.closeWindows(start.Add(time.Minute + 9*time.Second)); len(results) != 0 {
+		t.Fatalf("window closed within lateness: %d", len(results))
+	}
+	results := s.closeWindows(start.Add(time.Minute + 10*time.Second))
+	if len(results) != 1 {
+		t.Fatalf("unexpected closed windows: %d", len(results))
+	}
+	stats := results[0]
+	if stats.RoomID != room || stats.Window != "1m" || stats.Start != uint64(start.UnixMilli()) || stats.End != uint64(start.Add(time.Minute).UnixMilli()) {
+		t.Fatalf("unexpected window: %+v", stats)
+	}
+	if stats.Damaku != 3 || stats.Chatters != 2 || stats.DamakuPerMinute != 3 {
+		t.Fatalf("unexpected damaku stats: %+v", stats)
+	}
+	if stats.GiftRevenue != 200 {
+		t.Fatalf("unexpected gift revenue: %d", stats.GiftRevenue)
+	}
+	if stats.GuardCount != 1 || stats.GuardRevenue != 198000 || stats.SuperChatCount != 1 || stats.SuperChatRevenue != 30 {
+		t.Fatalf("unexpected revenue stats: %+v", stats)
+	}
+	if len(s.open[0]) != 0 {
+		t.Fatalf("closed window still open: %d", len(s.open[0]))
+	}
+}
+
+func TestRoomStatsTumble(t *testing.T) {
+	s := newTestRoomStats(t)
+	start := time.Now().Truncate(time.Minute).Add(time.Minute)
+	s.Handle(&agent.Damaku{Meta: testMeta(1, start.Add(time.Second)), UID: 1})
+	s.Handle(&agent.Damaku{Meta: testMeta(2, start.Add(time.Second)), UID: 1})
+	s.Handle(&agent.Damaku{Meta: testMeta(1, start.Add(time.Minute+time.Second)), UID: 1})
+	if results := s.closeWindows(start.Add(time.Minute + 10*time.Second)); len(results) != 2 {
+		t.Fatalf("unexpected closed windows of both rooms: %d", len(results))
+	}
+	if len(s.exposed[0]) != 2 {
+		t.Fatalf("unexpected exposed rooms: %d", len(s.exposed[0]))
+	}
+
+	// event of closed window is dropped
+	s.Handle(&agent.Damaku{Meta: testMeta(2, start.Add(30*time.Second)), UID: 1})
+	if late := testutil.ToFloat64(s.mLate); late != 1 {
+		t.Fatalf("unexpected late events: %f", late)
+	}
+
+	results := s.closeWindows(start.Add(2*time.Minute + 10*time.Second))
+	if len(results) != 1 || results[0].RoomID != 1 || results[0].Start != uint64(start.Add(time.Minute).UnixMilli()) {
+		t.Fatalf("unexpected closed windows: %+v", results)
+	}
+	// room without events in last window is not exposed anymore
+	if _, ok := s.exposed[0][2]; ok || len(s.exposed[0]) != 1 {
+		t.Fatalf("unexpected exposed rooms: %v", s.exposed[0])
+	}
+}
@@ -0,0 +1,185 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Config of MeiliSearch indexer
+type Config struct {
+	Enable        bool          `json:"enable" yaml:"enable"`
+	Host          string        `json:"host" yaml:"host"` // e.g. http://127.0.0.1:7700
+	APIKey        string        `json:"api_key" yaml:"api_key"`
+	Index         string        `json:"index" yaml:"index"`
+	BatchSize     int           `json:"batch_size" yaml:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+	Buffer        int           `json:"buffer" yaml:"buffer"` // documents dropped if buffer is full
+	MaxRetries    int           `json:"max_retries" yaml:"max_retries"`
+	RetryBackoff  time.Duration `json:"retry_backoff" yaml:"retry_backoff"` // doubled every retry
+	Timeout       time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// Document of danmaku or SuperChat
+type Document struct {
+	ID           string `json:"id"`   // [type]-[unique key], only a-z A-Z 0-9 - _ allowed
+	Type         string `json:"type"` // damaku or superChat
+	RoomID       uint64 `json:"room_id"`
+	UID          uint64 `json:"uid"`
+	UserName     string `json:"user_name,omitempty"`
+	Medal        uint64 `json:"medal,omitempty"` // target user id
+	Content      string `json:"content"`
+	ContentTrans string `json:"content_trans,omitempty"`
+	Price        uint32 `json:"price,omitempty"`
+	TimeStamp    uint64 `json:"timestamp"` // MilliTimestamp
+}
+
+// Indexer push documents to MeiliSearch in batch
+type Indexer struct {
+	config *Config
+	client *http.Client
+	docs   chan *Document
+}
+
+func NewIndexer(config *Config) *Indexer {
+	return &Indexer{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		docs:   make(chan *Document, config.Buffer),
+	}
+}
+
+// Add document without blocking, return false if buffer is full
+func (i *Indexer) Add(doc *Document) bool {
+	select {
+	case i.docs <- doc:
+		return true
+	default:
+		return false
+	}
+}
+
+// Setup index settings, filterable and sortable attributes
+func (i *Indexer) Setup(ctx context.Context) error {
+	settings := map[string]any{
+		"searchableAttributes": []string{"content", "content_trans", "user_name"},
+		"filterableAttributes": []string{"type", "room_id", "uid", "medal", "timestamp"},
+		"sortableAttributes":   []string{"timestamp", "price"},
+	}
+	return i.request(ctx, http.MethodPatch, fmt.Sprintf("/indexes/%s/settings", i.config.Index), settings)
+}
+
+// Run batch documents until context done, buffered documents are pushed within shutdownTimeout before return
+func (i *Indexer) Run(ctx context.Context, shutdownTimeout time.Duration) {
+	ticker := time.NewTicker(i.config.FlushInterval)
+	defer ticker.Stop()
+	// pushing outlives ctx by shutdownTimeout, so that buffered documents can still be pushed
+	pushCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { time.AfterFunc(shutdownTimeout, cancel) })
+	defer stop()
+	batch := make([]*Document, 0, i.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := i.Push(pushCtx, batch); err != nil {
+			klog.Errorf("[Search]failed to index %d documents: %s", len(batch), err.Error())
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case doc := <-i.docs:
+			batch = append(batch, doc)
+			if len(batch) >= i.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case doc := <-i.docs:
+					batch = append(batch, doc)
+					if len(batch) >= i.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Push documents with retry
+func (i *Indexer) Push(ctx context.Context, docs []*Document) error {
+	return i.request(ctx, http.MethodPost, fmt.Sprintf("/indexes/%s/documents?primaryKey=id", i.config.Index), docs)
+}
+
+// retryableError is network error, 429 or 5xx
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (i *Indexer) request(ctx context.Context, method, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal body: %s", err.Error())
+	}
+	backoff := i.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = i.do(ctx, method, path, data)
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= i.config.MaxRetries {
+			return err
+		}
+		klog.Warningf("[Search]request failed, retry in %s: %s", backoff, err.Error())
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (i *Indexer) do(ctx context.Context, method, path string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(i.config.Host, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if i.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+i.config.APIKey)
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return &retryableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("meilisearch responded %d: %s", resp.StatusCode, msg)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return &retryableError{err}
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConfig(host string) *Config {
+	return &Config{
+		Host:          host,
+		APIKey:        "key",
+		Index:         "damaku",
+		BatchSize:     2,
+		FlushInterval: time.Millisecond * 50,
+		Buffer:        8,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		Timeout:       time.Second,
+	}
+}
+
+func TestIndexerPushRetry(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/indexes/damaku/documents" || r.URL.Query().Get("primaryKey") != "id" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		}
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	indexer := NewIndexer(testConfig(server.URL))
+	if err := indexer.Push(context.Background(), []*Document{{ID: "damaku-1", Content: "hello"}}); err != nil {
+		t.Fatalf("push failed: %s", err.Error())
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Fatalf("unexpected attempts: %d", got)
+	}
+}
+
+func TestIndexerPushNoRetryOnBadRequest(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	indexer := NewIndexer(testConfig(server.URL))
+	if err := indexer.Push(context.Background(), []*Document{{ID: "damaku-1"}}); err == nil {
+		t.Fatal("push should fail")
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Fatalf("unexpected attempts: %d", got)
+	}
+}
+
+func TestIndexerRunBatch(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]*Document
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var docs []*Document
+		if err := json.NewDecoder(r.Body).Decode(&docs); err != nil {
+			t.Errorf("decode documents failed: %s", err.Error())
+		}
+		mu.Lock()
+		batches = append(batches, docs)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	indexer := NewIndexer(testConfig(server.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		indexer.Run(ctx, time.Second)
+		close(done)
+	}()
+	for _, id := range []string{"damaku-1", "damaku-2", "superChat-3"} {
+		if !indexer.Add(&Document{ID: id}) {
+			t.Fatalf("add %s failed", id)
+		}
+	}
+	time.Sleep(time.Millisecond * 200)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+	if batches[1][0].ID != "superChat-3" {
+		t.Fatalf("unexpected document: %s", batches[1][0].ID)
+	}
+}
+
+func TestIndexerRunDrain(t *testing.T) {
+	var pushed atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var docs []*Document
+		if err := json.NewDecoder(r.Body).Decode(&docs); err != nil {
+			t.Errorf("decode documents failed: %s", err.Error())
+		}
+		pushed.Add(int32(len(docs)))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	indexer := NewIndexer(testConfig(server.URL))
+	for i := 0; i < 5; i++ {
+		if !indexer.Add(&Document{ID: fmt.Sprintf("damaku-%d", i)}) {
+			t.Fatalf("add %d failed", i)
+		}
+	}
+	// buffered documents are pushed even if context is done before run
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	indexer.Run(ctx, time.Second)
+	if got := pushed.Load(); got != 5 {
+		t.Fatalf("unexpected pushed documents: %d", got)
+	}
+}
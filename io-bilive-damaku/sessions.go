@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm/clause"
+	"k8s.io/klog/v2"
+)
+
+// reasons of session end
+const (
+	SessionEndPreparing = "preparing"
+	SessionEndTimeout   = "timeout" // no event of room within timeout, end at time of its last event
+	SessionEndRestart   = "restart" // another live started without preparing
+)
+
+// SessionTracker derive live sessions of rooms from LIVE and PREPARING, it is an enricher of MessageProcessor.
+// Events of room in live are stamped with the session id at Meta.Session, and counted into the session.
+// Sessions are upserted to bilive_live_session, open sessions are restored when becoming leader
+type SessionTracker struct {
+	centerCtx *CenterContext
+	config    *SessionConfig
+
+	mu     sync.Mutex
+	leader bool
+	open   map[uint64]*LiveSessionRecord // room:session
+	info   map[uint64]*agent.RoomChange  // room:latest title and area, copied
+	dirty  map[string]*LiveSessionRecord // session id:session changed since last flush
+
+	mOpen prometheus.Gauge
+}
+
+func (t *SessionTracker) Init(ctx *CenterContext, leader *LeaderElector) {
+	t.centerCtx = ctx
+	t.config = &ctx.Config.Controller.Session
+	t.open = make(map[uint64]*LiveSessionRecord)
+	t.info = make(map[uint64]*agent.RoomChange)
+	t.dirty = make(map[string]*LiveSessionRecord)
+	t.mOpen = prometheus.NewGauge(prometheus.GaugeOpts{Name: "blive_damaku_live_sessions", Help: "open live sessions"})
+	ctx.Registry.MustRegister(t.mOpen)
+	leader.OnChanged(t.follow)
+}
+
+func (t *SessionTracker) Start() {
+	klog.Infof("starting session tracker, timeout: %s", t.config.Timeout)
+	t.centerCtx.Worker.Go(func() {
+		ticker := time.NewTicker(t.config.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				t.expire(now)
+				t.flush(t.centerCtx.Context)
+			case <-t.centerCtx.Context.Done():
+				ctx, cancel := context.WithTimeout(context.Background(), t.centerCtx.Config.Controller.ShutdownTimeout)
+				t.flush(ctx)
+				cancel()
+				return
+			}
+		}
+	})
+}
+
+// Handle stamp event with session of its room, LIVE and PREPARING belong to the session they start or end.
+// Events before the start of session are neither stamped nor counted
+func (t *SessionTracker) Handle(event any) bool {
+	meta := eventMeta(event)
+	if meta == nil || meta.GetRoomID() == 0 {
+		return true
+	}
+	room := meta.GetRoomID()
+	ts := eventTime(meta)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	session := t.open[room]
+	if session != nil && ts.Before(session.StartTime) {
+		session = nil
+	}
+	switch e := event.(type) {
+	case *agent.LiveStatus:
+		if e.Live {
+			session = t.start(room, e, ts)
+		} else if session != nil {
+			meta.Session = session.ID
+			t.end(session, ts, SessionEndPreparing)
+			return true
+		}
+	case *agent.RoomChange:
+		t.info[room] = &agent.RoomChange{
+			Title:          e.Title,
+			AreaID:         e.AreaID,
+			AreaName:       e.AreaName,
+			ParentAreaID:   e.ParentAreaID,
+			ParentAreaName: e.ParentAreaName,
+		}
+	}
+	if session == nil {
+		return true
+	}
+	meta.Session = session.ID
+	switch e := event.(type) {
+	case *agent.Damaku:
+		session.Damaku++
+	case *agent.Gift:
+		session.GiftRevenue += e.Revenue()
+	case *agent.Guard:
+		session.GuardCount++
+		session.GuardRevenue += uint64(e.Price)
+	case *agent.SuperChat:
+		session.SuperChatCount++
+		session.SuperChatRevenue += uint64(e.Price)
+	case *agent.OnlineRankCount:
+		session.PeakOnline = max(session.PeakOnline, e.Online)
+	case *agent.RoomChange:
+		applyRoomInfo(session, e)
+	}
+	if ts.After(session.LastEventTime) {
+		session.LastEventTime = ts
+	}
+	session.UpdatedAt = time.Now()
+	t.dirty[session.ID] = session
+	return true
+}
+
+// start a session, LIVE may be sent more than once at start, only a different live key starts another session
+func (t *SessionTracker) start(room uint64, e *agent.LiveStatus, ts time.Time) *LiveSessionRecord {
+	startTime := ts
+	if e.LiveTime > 0 {
+		startTime = time.UnixMilli(int64(e.LiveTime))
+	}
+	if session := t.open[room]; session != nil {
+		if e.LiveKey == "" || session.LiveKey == "" || e.LiveKey == session.LiveKey {
+			if session.LiveKey == "" {
+				session.LiveKey = e.LiveKey
+			}
+			if e.LiveTime > 0 {
+				session.StartTime = startTime
+			}
+			return session
+		}
+		t.end(session, ts, SessionEndRestart)
+	}
+	id := e.LiveKey
+	if id == "" {
+		id = fmt.Sprintf("%d-%d", room, startTime.UnixMilli())
+	}
+	session := &LiveSessionRecord{
+		ID:            id,
+		RoomID:        room,
+		LiveKey:       e.LiveKey,
+		StartTime:     startTime,
+		LastEventTime: startTime,
+	}
+	if info, ok := t.info[room]; ok {
+		applyRoomInfo(session, info)
+	}
+	t.open[room] = session
+	klog.Infof("[Session]room %d live session started: %s", room, id)
+	return session
+}
+
+func (t *SessionTracker) end(session *LiveSessionRecord, ts time.Time, reason string) {
+	session.EndTime = &ts
+	session.EndReason = reason
+	session.UpdatedAt = time.Now()
+	t.dirty[session.ID] = session
+	delete(t.open, session.RoomID)
+	klog.Infof("[Session]room %d live session ended by %s: %s", session.RoomID, reason, session.ID)
+}
+
+// expire sessions without event in timeout and end them at their last event, only leader sees events.
+// Events replayed or delivered late keep the session open while they are still being received
+func (t *SessionTracker) expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.leader {
+		return
+	}
+	for _, session := range t.open {
+		if now.Sub(session.LastEventTime) > t.config.Timeout && now.Sub(session.UpdatedAt) > t.config.Timeout {
+			t.end(session, session.LastEventTime, SessionEndTimeout)
+		}
+	}
+	t.mOpen.Set(float64(len(t.open)))
+}
+
+// upsert changed sessions, failed sessions will be upserted at their next change
+func (t *SessionTracker) flush(ctx context.Context) {
+	t.mu.Lock()
+	sessions := make([]*LiveSessionRecord, 0, len(t.dirty))
+	for _, session := range t.dirty {
+		s := *session
+		sessions = append(sessions, &s)
+	}
+	clear(t.dirty)
+	t.mu.Unlock()
+	if len(sessions) == 0 {
+		return
+	}
+	db := t.centerCtx.DB.DBWithCtx(ctx)
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&sessions).Error; err != nil {
+		klog.Errorf("[Session]failed to upsert %d sessions: %s", len(sessions), err.Error())
+	}
+}
+
+// follow leadership, sessions opened by the previous leader are restored from database
+func (t *SessionTracker) follow(isLeader bool) {
+	if !isLeader {
+		t.flush(context.Background())
+		t.mu.Lock()
+		t.leader = false
+		clear(t.open)
+		t.mu.Unlock()
+		return
+	}
+	var sessions []*LiveSessionRecord
+	db := t.centerCtx.DB.DBWithCtx(t.centerCtx.Context)
+	if err := db.Where("end_time IS NULL").Find(&sessions).Error; err != nil {
+		klog.Errorf("[Session]failed to restore open sessions: %s", err.Error())
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leader = true
+	for _, session := range sessions {
+		if session.LastEventTime.IsZero() {
+			// saved before last event time tracked
+			session.LastEventTime = session.UpdatedAt
+		}
+		current, ok := t.open[session.RoomID]
+		if !ok {
+			t.open[session.RoomID] = session
+			continue
+		}
+		if current.ID != session.ID {
+			// another live started before restored
+			t.end(session, current.StartTime, SessionEndRestart)
+			t.open[session.RoomID] = current
+		}
+	}
+	klog.Infof("[Session]%d open sessions restored", len(sessions))
+}
+
+func applyRoomInfo(session *LiveSessionRecord, info *agent.RoomChange) {
+	session.Title = info.Title
+	session.AreaID = info.AreaID
+	session.AreaName = info.AreaName
+	session.ParentAreaID = info.ParentAreaID
+	session.ParentAreaName = info.ParentAreaName
+}
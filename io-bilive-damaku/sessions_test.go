@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestSessionTracker() *SessionTracker {
+	return &SessionTracker{
+		open:  make(map[uint64]*LiveSessionRecord),
+		info:  make(map[uint64]*agent.RoomChange),
+		dirty: make(map[string]*LiveSessionRecord),
+	}
+}
+
+func TestSessionTrackerHandle(t *testing.T) {
+	tracker := newTestSessionTracker()
+	room := uint64(1)
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Handle(&agent.LiveStatus{Meta: testMeta(room, start.Add(time.Second)), Live: true, LiveTime: uint64(start.UnixMilli()), LiveKey: "key"})
+	session := tracker.open[room]
+	if session == nil || session.ID != "key" || !session.StartTime.Equal(start) {
+		t.Fatalf("unexpected session: %+v", session)
+	}
+
+	// delayed events of the previous live
+	before := &agent.Damaku{Meta: testMeta(room, start.Add(-time.Second))}
+	tracker.Handle(before)
+	tracker.Handle(&agent.LiveStatus{Meta: testMeta(room, start.Add(-time.Second)), Live: false})
+	if before.Meta.Session != "" || session.Damaku != 0 {
+		t.Fatalf("event before session start stamped: %s, %d", before.Meta.Session, session.Damaku)
+	}
+	if tracker.open[room] != session || session.EndTime != nil {
+		t.Fatal("session ended by PREPARING before its start")
+	}
+
+	damaku := &agent.Damaku{Meta: testMeta(room, start.Add(time.Minute))}
+	tracker.Handle(damaku)
+	tracker.Handle(&agent.Gift{Meta: testMeta(room, start.Add(time.Minute)), Count: 1, Info: &agent.Gift_GiftInfo{Price: 100}, Coin: agent.Gift_Gold})
+	tracker.Handle(&agent.Gift{Meta: testMeta(room, start.Add(time.Minute)), Count: 1, Info: &agent.Gift_GiftInfo{Price: 100}, Coin: agent.Gift_Silver})
+	if damaku.Meta.Session != "key" || session.Damaku != 1 || session.GiftRevenue != 100 {
+		t.Fatalf("unexpected session counters: %s, %+v", damaku.Meta.Session, session)
+	}
+
+	end := start.Add(time.Hour)
+	tracker.Handle(&agent.LiveStatus{Meta: testMeta(room, end), Live: false})
+	if _, ok := tracker.open[room]; ok || session.EndTime == nil || !session.EndTime.Equal(end) || session.EndReason != SessionEndPreparing {
+		t.Fatalf("unexpected ended session: %+v", session)
+	}
+}
+
+func TestSessionTrackerExpire(t *testing.T) {
+	tracker := newTestSessionTracker()
+	tracker.config = &SessionConfig{Timeout: time.Minute * 10}
+	tracker.leader = true
+	tracker.mOpen = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_live_sessions"})
+	room := uint64(1)
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Handle(&agent.LiveStatus{Meta: testMeta(room, start), Live: true, LiveKey: "key"})
+	last := start.Add(time.Minute)
+	tracker.Handle(&agent.Damaku{Meta: testMeta(room, last)})
+	tracker.Handle(&agent.Damaku{Meta: testMeta(room, start.Add(time.Second))}) // delivered late
+	session := tracker.open[room]
+
+	// replayed events are still being received
+	tracker.expire(time.Now())
+	if session.EndTime != nil {
+		t.Fatalf("session of replayed events expired: %+v", session)
+	}
+	tracker.expire(time.Now().Add(time.Minute * 11))
+	if _, ok := tracker.open[room]; ok || session.EndTime == nil || !session.EndTime.Equal(last) || session.EndReason != SessionEndTimeout {
+		t.Fatalf("unexpected expired session: %+v", session)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"k8s.io/klog/v2"
+)
+
+// route stream msg to aggregate window worker by room, so msg from the same room keep in order
+func (c *DamakuController) dispatcher() {
+	klog.InfoS("stream dispatcher start", "workers", len(c.shardChan))
+	for {
+		select {
+		case msg := <-c.streamChan:
+			subject := strings.Split(msg.Subject, ".")
+			key := streamShardKey(subject[len(subject)-1], msg.Data)
+			shard := c.shardChan[key%uint64(len(c.shardChan))]
+			select {
+			case shard <- msg:
+			default:
+				c.metrics.Saturated("shard")
+				shard <- msg
+			}
+		case <-c.centerCtx.Context.Done():
+			klog.Info("stream dispatcher stopped")
+			return
+		}
+	}
+}
+
+// push event to eventChan, count saturation when processor is falling behind.
+// Event is dropped if processor already stopped at shutdown
+func (c *DamakuController) pushEvent(event any) {
+	select {
+	case c.eventChan <- event:
+		return
+	default:
+		c.metrics.Saturated("event")
+	}
+	select {
+	case c.eventChan <- event:
+	case <-c.processor.Stopped():
+		klog.Warningf("processor stopped, %T dropped", event)
+	}
+}
+
+// streamShardKey peek the routing key from raw proto msg without unmarshal:
+// UID for meta msg (it is the meta cache key), BasicMsgMeta.RoomID for stream msg
+func streamShardKey(eventType string, data []byte) uint64 {
+	switch eventType {
+	case EventFansMedal, EventUserInfo:
+		return protoVarintField(data, 1) // UID
+	default:
+		return protoVarintField(protoBytesField(data, 1), 3) // Meta.RoomID
+	}
+}
+
+func protoVarintField(data []byte, field protowire.Number) uint64 {
+	var value uint64
+	scanProtoField(data, field, protowire.VarintType, func(b []byte) {
+		value, _ = protowire.ConsumeVarint(b)
+	})
+	return value
+}
+
+func protoBytesField(data []byte, field protowire.Number) []byte {
+	var value []byte
+	scanProtoField(data, field, protowire.BytesType, func(b []byte) {
+		value, _ = protowire.ConsumeBytes(b)
+	})
+	return value
+}
+
+// call fn with the remaining data at the first matched field, broken data is ignored
+func scanProtoField(data []byte, field protowire.Number, wireType protowire.Type, fn func([]byte)) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return
+		}
+		data = data[n:]
+		if num == field && typ == wireType {
+			fn(data)
+			return
+		}
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return
+		}
+		data = data[n:]
+	}
+}
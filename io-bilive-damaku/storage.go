@@ -1,4 +1,381 @@
 package main
 
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// StorageController batch insert deduplicated events into time-partitioned hypertables,
+// it is a sink of MessageProcessor
 type StorageController struct {
+	centerCtx  *CenterContext
+	config     *StorageConfig
+	metrics    *MetricsService
+	recordChan chan any
+	upstream   <-chan struct{} // closed when no more event will be handled, writer keeps receiving until then at shutdown
+	stopped    chan struct{}   // closed after writer stopped
+
+	// pending records, flush at batch size or flush interval
+	pending    int
+	damaku     []*DamakuRecord
+	gift       []*GiftRecord
+	guard      []*GuardRecord
+	superChat  []*SuperChatRecord
+	online     []*OnlineRankCountRecord
+	onlineV2   []*OnlineRankV2Record
+	interact   []*InteractRecord
+	like       []*LikeCountRecord
+	roomChange []*RoomChangeRecord
+	liveStatus []*LiveStatusRecord
+	watched    []*WatchedCountRecord
+	fans       []*FansCountRecord
+	gap        []*StreamGapRecord
+
+	// pending dimension changes, latest state per key and full change history
+	users        map[uint64]*UserRecord
+	medals       map[fansMedalKey]*FansMedalRecord
+	userHistory  []*UserHistoryRecord
+	medalHistory []*FansMedalHistoryRecord
+}
+
+func (s *StorageController) Init(ctx *CenterContext, metrics *MetricsService, upstream <-chan struct{}) error {
+	s.centerCtx = ctx
+	s.config = &ctx.Config.Storage
+	s.metrics = metrics
+	s.recordChan = make(chan any, s.config.Buffer)
+	s.upstream = upstream
+	s.stopped = make(chan struct{})
+	metrics.WatchChannel("storage", func() (int, int) { return len(s.recordChan), cap(s.recordChan) })
+	s.users = make(map[uint64]*UserRecord)
+	s.medals = make(map[fansMedalKey]*FansMedalRecord)
+	if s.config.Migrate {
+		if err := s.migrate(); err != nil {
+			return fmt.Errorf("failed to migrate storage: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *StorageController) Start() {
+	klog.Infof("starting storage controller")
+	s.centerCtx.Worker.Go(s.writer)
+}
+
+// create tables and convert them to hypertables, need TimescaleDB extension
+func (s *StorageController) migrate() error {
+	db := s.centerCtx.DB.DBWithCtx(s.centerCtx.Context)
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS timescaledb").Error; err != nil {
+		return fmt.Errorf("failed to create timescaledb extension: %s", err.Error())
+	}
+	if err := db.AutoMigrate(dimensionTables...); err != nil {
+		return fmt.Errorf("failed to auto migrate dimension tables: %s", err.Error())
+	}
+	if err := db.AutoMigrate(hypertables...); err != nil {
+		return fmt.Errorf("failed to auto migrate: %s", err.Error())
+	}
+	for _, model := range hypertables {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse model %T: %s", model, err.Error())
+		}
+		if err := db.Exec(
+			"SELECT create_hypertable(?, 'time', chunk_time_interval => make_interval(secs => ?), if_not_exists => TRUE, migrate_data => TRUE)",
+			stmt.Schema.Table, s.config.ChunkInterval.Seconds()).Error; err != nil {
+			return fmt.Errorf("failed to create hypertable %s: %s", stmt.Schema.Table, err.Error())
+		}
+		klog.Infof("[Storage]hypertable ready: %s", stmt.Schema.Table)
+	}
+	return nil
+}
+
+// Handle convert event to record, the event will be recycled by processor after that.
+// A full buffer blocks the processor as backpressure of a slow database, counted as saturation of storage channel
+func (s *StorageController) Handle(event any) bool {
+	record := s.record(event)
+	if record == nil {
+		return true
+	}
+	select {
+	case s.recordChan <- record:
+		return true
+	default:
+		s.metrics.Saturated("storage")
+	}
+	select {
+	case s.recordChan <- record:
+	case <-s.stopped:
+		klog.Warningf("[Storage]writer stopped, %T dropped", record)
+	}
+	return true
+}
+
+// receive records and insert them in batch
+func (s *StorageController) writer() {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	klog.Info("[Storage]writer start")
+	defer close(s.stopped)
+	for {
+		select {
+		case record := <-s.recordChan:
+			s.buffer(record)
+			if s.pending >= s.config.BatchSize {
+				s.flush(s.centerCtx.Context)
+			}
+		case <-ticker.C:
+			s.flush(s.centerCtx.Context)
+		case <-s.centerCtx.Context.Done():
+			// keep receiving records until processor drained, context of controller is cancelled
+			s.drain()
+			ctx, cancel := context.WithTimeout(context.Background(), s.centerCtx.Config.Controller.ShutdownTimeout)
+			s.flush(ctx)
+			cancel()
+			klog.Info("[Storage]writer stopped")
+			return
+		}
+	}
+}
+
+// drain buffer records until upstream stopped, then the records left in recordChan
+func (s *StorageController) drain() {
+	for {
+		select {
+		case record := <-s.recordChan:
+			s.buffer(record)
+		case <-s.upstream:
+			for len(s.recordChan) > 0 {
+				s.buffer(<-s.recordChan)
+			}
+			return
+		}
+	}
+}
+
+// copy event data into record, never keep pointer of event
+func (s *StorageController) record(event any) any {
+	switch e := event.(type) {
+	case *agent.Damaku:
+		return &DamakuRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			UID:       e.UID,
+			Content:   e.Content,
+			Medal:     e.Medal,
+		}
+	case *agent.Gift:
+		record := &GiftRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			TID:       e.TID,
+			UID:       e.UID,
+			Count:     e.Count,
+			Medal:     e.Medal,
+		}
+		if e.Info != nil {
+			record.GiftID = e.Info.ID
+			record.GiftName = e.Info.Name
+			record.Price = e.Info.Price
+		}
+		if e.OriginalInfo != nil {
+			record.OriginalGiftID = e.OriginalInfo.ID
+			record.OriginalGiftName = e.OriginalInfo.Name
+			record.OriginalPrice = e.OriginalInfo.Price
+		}
+		return record
+	case *agent.Guard:
+		return &GuardRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			UID:       e.UID,
+			Price:     e.Price,
+			GiftType:  int32(e.GiftType),
+		}
+	case *agent.SuperChat:
+		return &SuperChatRecord{
+			Time:         eventTime(e.Meta),
+			RoomID:       e.Meta.GetRoomID(),
+			SessionID:    e.Meta.Session,
+			ID:           e.ID,
+			UID:          e.UID,
+			Message:      e.Message,
+			MessageTrans: e.MessageTrans,
+			Price:        e.Price,
+			Medal:        e.Medal,
+		}
+	case *agent.OnlineRankCount:
+		return &OnlineRankCountRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			Count:     e.Count,
+			Online:    e.Online,
+		}
+	case *agent.OnlineRankV2:
+		ts := eventTime(e.Meta)
+		records := make([]*OnlineRankV2Record, 0, len(e.List))
+		for _, rank := range e.List {
+			records = append(records, &OnlineRankV2Record{
+				Time:       ts,
+				RoomID:     e.Meta.GetRoomID(),
+				SessionID:  e.Meta.Session,
+				Rank:       rank.Rank,
+				Score:      rank.Score,
+				UID:        rank.UID,
+				GuardLevel: int32(rank.GuardLevel),
+			})
+		}
+		return records
+	case *agent.Interact:
+		return &InteractRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			UID:       e.UID,
+			Type:      int32(e.Type),
+			Medal:     e.Medal,
+		}
+	case *agent.LikeCount:
+		return &LikeCountRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			Count:     e.Count,
+		}
+	case *agent.RoomChange:
+		return &RoomChangeRecord{
+			Time:           eventTime(e.Meta),
+			RoomID:         e.Meta.GetRoomID(),
+			SessionID:      e.Meta.Session,
+			Title:          e.Title,
+			AreaID:         e.AreaID,
+			AreaName:       e.AreaName,
+			ParentAreaID:   e.ParentAreaID,
+			ParentAreaName: e.ParentAreaName,
+		}
+	case *agent.LiveStatus:
+		record := &LiveStatusRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			Live:      e.Live,
+			LiveKey:   e.LiveKey,
+		}
+		if e.LiveTime > 0 {
+			liveTime := time.UnixMilli(int64(e.LiveTime))
+			record.LiveTime = &liveTime
+		}
+		return record
+	case *agent.WatchedCount:
+		return &WatchedCountRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			Count:     e.Count,
+		}
+	case *agent.FansCount:
+		return &FansCountRecord{
+			Time:      eventTime(e.Meta),
+			RoomID:    e.Meta.GetRoomID(),
+			SessionID: e.Meta.Session,
+			Fans:      e.Fans,
+			FansClub:  e.FansClub,
+		}
+	case *agent.StreamGap:
+		return &StreamGapRecord{
+			Time:          eventTime(e.Meta),
+			RoomID:        e.Meta.GetRoomID(),
+			Agent:         e.Meta.Agent,
+			PreviousAgent: e.PreviousAgent,
+			LastSeen:      time.UnixMilli(int64(e.LastSeen)),
+		}
+	case *agent.UserInfoMeta:
+		return userHistoryRecord(e)
+	case *agent.FansMedalMeta:
+		return medalHistoryRecord(e)
+	}
+	return nil
+}
+
+// add record to pending records
+func (s *StorageController) buffer(record any) {
+	switch r := record.(type) {
+	case *DamakuRecord:
+		s.damaku = append(s.damaku, r)
+	case *GiftRecord:
+		s.gift = append(s.gift, r)
+	case *GuardRecord:
+		s.guard = append(s.guard, r)
+	case *SuperChatRecord:
+		s.superChat = append(s.superChat, r)
+	case *OnlineRankCountRecord:
+		s.online = append(s.online, r)
+	case []*OnlineRankV2Record:
+		s.onlineV2 = append(s.onlineV2, r...)
+	case *InteractRecord:
+		s.interact = append(s.interact, r)
+	case *LikeCountRecord:
+		s.like = append(s.like, r)
+	case *RoomChangeRecord:
+		s.roomChange = append(s.roomChange, r)
+	case *LiveStatusRecord:
+		s.liveStatus = append(s.liveStatus, r)
+	case *WatchedCountRecord:
+		s.watched = append(s.watched, r)
+	case *FansCountRecord:
+		s.fans = append(s.fans, r)
+	case *StreamGapRecord:
+		s.gap = append(s.gap, r)
+	case *UserHistoryRecord:
+		s.bufferUser(r)
+	case *FansMedalHistoryRecord:
+		s.bufferMedal(r)
+	default:
+		klog.Warningf("[Storage]unknown record type: %T", record)
+		return
+	}
+	s.pending++
+}
+
+// flush all pending records, failed batch will be dropped
+func (s *StorageController) flush(ctx context.Context) {
+	if s.pending == 0 {
+		return
+	}
+	db := s.centerCtx.DB.DBWithCtx(ctx)
+	insertBatch(db, "damaku", &s.damaku, s.config.BatchSize)
+	insertBatch(db, "gift", &s.gift, s.config.BatchSize)
+	insertBatch(db, "guard", &s.guard, s.config.BatchSize)
+	insertBatch(db, "superChat", &s.superChat, s.config.BatchSize)
+	insertBatch(db, "online", &s.online, s.config.BatchSize)
+	insertBatch(db, "onlineV2", &s.onlineV2, s.config.BatchSize)
+	insertBatch(db, "interact", &s.interact, s.config.BatchSize)
+	insertBatch(db, "like", &s.like, s.config.BatchSize)
+	insertBatch(db, "roomChange", &s.roomChange, s.config.BatchSize)
+	insertBatch(db, "liveStatus", &s.liveStatus, s.config.BatchSize)
+	insertBatch(db, "watched", &s.watched, s.config.BatchSize)
+	insertBatch(db, "fans", &s.fans, s.config.BatchSize)
+	insertBatch(db, "streamGap", &s.gap, s.config.BatchSize)
+	s.flushDimension(db)
+	s.pending = 0
+}
+
+func insertBatch[T any](db *gorm.DB, category string, records *[]*T, batchSize int) {
+	if len(*records) == 0 {
+		return
+	}
+	start := time.Now()
+	if err := db.CreateInBatches(*records, batchSize).Error; err != nil {
+		klog.Errorf("[Storage]failed to insert %d %s records: %s", len(*records), category, err.Error())
+	} else {
+		klog.V(3).Infof("[Storage]%d %s records inserted in %s", len(*records), category, time.Since(start))
+	}
+	*records = (*records)[:0]
 }
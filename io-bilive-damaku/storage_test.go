@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+)
+
+func TestEventTime(t *testing.T) {
+	millis := uint64(time.Date(2024, 7, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC).UnixMilli())
+	cases := []struct {
+		name string
+		ts   uint64
+		want uint64
+	}{
+		{"millis", millis, millis},
+		{"seconds", millis / 1000, millis / 1000 * 1000},
+		{"zero", 0, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := eventTime(&agent.BasicMsgMeta{TimeStamp: c.ts})
+			if uint64(got.UnixMilli()) != c.want {
+				t.Fatalf("unexpected time: %d, want %d", got.UnixMilli(), c.want)
+			}
+		})
+	}
+}
+
+func TestRecordSuperChatTime(t *testing.T) {
+	room := uint64(1)
+	ts := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := &StorageController{}
+	for _, stamp := range []uint64{uint64(ts.Unix()), uint64(ts.UnixMilli())} {
+		record, ok := s.record(&agent.SuperChat{
+			Meta:  &agent.BasicMsgMeta{RoomID: &room, TimeStamp: stamp},
+			Price: 30,
+		}).(*SuperChatRecord)
+		if !ok {
+			t.Fatal("not a superChat record")
+		}
+		if !record.Time.Equal(ts) {
+			t.Fatalf("unexpected time of %d: %s", stamp, record.Time)
+		}
+	}
+}
+
+func TestDimensionHistoryTime(t *testing.T) {
+	ts := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := &StorageController{users: make(map[uint64]*UserRecord), medals: make(map[fansMedalKey]*FansMedalRecord)}
+	s.bufferUser(userHistoryRecord(&agent.UserInfoMeta{UID: 1, UserName: "new", TimeStamp: uint64(ts.UnixMilli())}))
+	s.bufferUser(userHistoryRecord(&agent.UserInfoMeta{UID: 1, UserName: "old", TimeStamp: uint64(ts.Add(-time.Minute).Unix())}))
+	if len(s.userHistory) != 2 || !s.userHistory[0].Time.Equal(ts) || !s.userHistory[1].Time.Equal(ts.Add(-time.Minute)) {
+		t.Fatalf("unexpected user history: %+v", s.userHistory)
+	}
+	if user := s.users[1]; user.UserName != "new" || !user.UpdatedAt.Equal(ts) {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+
+	s.bufferMedal(medalHistoryRecord(&agent.FansMedalMeta{UID: 1, RoomUID: 2, Level: 2, TimeStamp: uint64(ts.UnixMilli())}))
+	s.bufferMedal(medalHistoryRecord(&agent.FansMedalMeta{UID: 1, RoomUID: 2, Level: 1, TimeStamp: uint64(ts.Add(-time.Minute).UnixMilli())}))
+	if medal := s.medals[fansMedalKey{UID: 1, RoomUID: 2}]; medal.Level != 2 || !medal.UpdatedAt.Equal(ts) {
+		t.Fatalf("unexpected medal: %+v", medal)
+	}
+
+	// fallback to now for old agents
+	before := time.Now()
+	if record := userHistoryRecord(&agent.UserInfoMeta{UID: 1}); record.Time.Before(before) {
+		t.Fatalf("unexpected fallback time: %s", record.Time)
+	}
+}
+
+func TestStorageDrain(t *testing.T) {
+	upstream := make(chan struct{})
+	s := &StorageController{recordChan: make(chan any, 1), upstream: upstream}
+	room := uint64(1)
+	done := make(chan struct{})
+	go func() {
+		s.drain()
+		close(done)
+	}()
+	// records are received until upstream stopped
+	for i := 0; i < 3; i++ {
+		s.recordChan <- s.record(&agent.Damaku{Meta: &agent.BasicMsgMeta{RoomID: &room}})
+	}
+	close(upstream)
+	<-done
+	if len(s.damaku) != 3 || s.pending != 3 {
+		t.Fatalf("unexpected buffered: %d, pending: %d", len(s.damaku), s.pending)
+	}
+}
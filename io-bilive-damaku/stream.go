@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"k8s.io/klog/v2"
+)
+
+// create stream and durable consumer for JetStream mode,
+// consumer is created explicitly so that it will not be deleted on unsubscribe
+func (c *DamakuController) setupStream() error {
+	jsConfig := &c.centerCtx.Config.Controller.JetStream
+	if !jsConfig.Enable {
+		return nil
+	}
+	prefix := c.centerCtx.Config.Global.Prefix
+	if jsConfig.Stream == "" {
+		jsConfig.Stream = fmt.Sprintf("%s_stream", prefix)
+	}
+	streamConfig := &nats.StreamConfig{
+		Name:     jsConfig.Stream,
+		Subjects: []string{fmt.Sprintf("%s.stream.>", prefix)},
+		MaxAge:   jsConfig.MaxAge,
+	}
+	if _, err := c.centerCtx.MQ.Js.AddStream(streamConfig); err != nil {
+		if !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+			return fmt.Errorf("failed to add stream %s: %s", streamConfig.Name, err.Error())
+		}
+		if _, err := c.centerCtx.MQ.Js.UpdateStream(streamConfig); err != nil {
+			return fmt.Errorf("failed to update stream %s: %s", streamConfig.Name, err.Error())
+		}
+	}
+	consumerConfig := &nats.ConsumerConfig{
+		Durable:        jsConfig.Durable,
+		DeliverSubject: fmt.Sprintf("%s.deliver.%s", prefix, jsConfig.Durable),
+		DeliverPolicy:  nats.DeliverAllPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        jsConfig.AckWait,
+		MaxAckPending:  jsConfig.MaxAckPending,
+		MaxDeliver:     jsConfig.MaxDeliver,
+		FilterSubject:  fmt.Sprintf("%s.stream.*", prefix),
+	}
+	if _, err := c.centerCtx.MQ.Js.AddConsumer(jsConfig.Stream, consumerConfig); err != nil {
+		if !errors.Is(err, nats.ErrConsumerNameAlreadyInUse) {
+			return fmt.Errorf("failed to add consumer %s: %s", consumerConfig.Durable, err.Error())
+		}
+		if _, err := c.centerCtx.MQ.Js.UpdateConsumer(jsConfig.Stream, consumerConfig); err != nil {
+			return fmt.Errorf("failed to update consumer %s: %s", consumerConfig.Durable, err.Error())
+		}
+	}
+	klog.Infof("using JetStream stream(%s) with durable consumer(%s)", jsConfig.Stream, jsConfig.Durable)
+	return nil
+}
+
+// subscribe or unsubscribe stream msg from agents when leadership changed,
+// with JetStream the durable consumer resume from the last acked msg
+func (c *DamakuController) followStream(isLeader bool) {
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+	if !isLeader {
+		if c.streamSub == nil {
+			return
+		}
+		if err := c.streamSub.Unsubscribe(); err != nil {
+			klog.Errorf("failed to unsubscribe stream msg: %s", err.Error())
+		}
+		c.streamSub = nil
+		klog.Info("stream msg unsubscribed")
+		return
+	}
+	if err := c.subscribeStream(); err != nil {
+		klog.Errorf("failed to subscribe stream msg, will retry: %s", err.Error())
+	}
+}
+
+// subscribeStream subscribe stream msg if not subscribed yet, streamMu must be held
+func (c *DamakuController) subscribeStream() error {
+	if c.streamSub != nil {
+		return nil
+	}
+	var err error
+	subject := fmt.Sprintf("%s.stream.*", c.centerCtx.Config.Global.Prefix)
+	if jsConfig := &c.centerCtx.Config.Controller.JetStream; jsConfig.Enable {
+		c.streamSub, err = c.centerCtx.MQ.Js.ChanSubscribe(subject, c.streamChan,
+			nats.Bind(jsConfig.Stream, jsConfig.Durable), nats.ManualAck())
+	} else {
+		c.streamSub, err = c.centerCtx.MQ.Nc.ChanSubscribe(subject, c.streamChan)
+	}
+	if err != nil {
+		c.streamSub = nil
+		return err
+	}
+	klog.Info("stream msg subscribed")
+	return nil
+}
+
+// keepStream retry subscribing with backoff while leader has no stream subscription,
+// leadership hook only fires on change, so a failed subscription would never be retried
+func (c *DamakuController) keepStream() {
+	backoff := time.Second
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			c.streamMu.Lock()
+			if c.leader.IsLeader() && c.streamSub == nil {
+				if err := c.subscribeStream(); err != nil {
+					backoff = min(backoff*2, time.Second*30)
+					klog.Errorf("failed to subscribe stream msg, retry in %s: %s", backoff, err.Error())
+				} else {
+					backoff = time.Second
+				}
+			}
+			c.streamMu.Unlock()
+			timer.Reset(backoff)
+		case <-c.centerCtx.Context.Done():
+			return
+		}
+	}
+}
+
+// ackStream ack the JetStream msg after aggregated, msg not handled will be redelivered later.
+// Acked events are still handled at graceful shutdown since processor and storage drain in pipeline order,
+// but records are lost if storage fails to flush them, they are not redelivered
+func (c *DamakuController) ackStream(msg *nats.Msg, handled bool) {
+	if !c.centerCtx.Config.Controller.JetStream.Enable {
+		return
+	}
+	var err error
+	if handled {
+		err = msg.Ack()
+	} else {
+		err = msg.NakWithDelay(time.Second)
+	}
+	if err != nil {
+		klog.Errorf("failed to ack stream msg(%s): %s", msg.Subject, err.Error())
+	}
+}
@@ -32,15 +32,25 @@ type RoomProvider interface {
 	// Provide a live room for watch,
 	// if room already added from another provider, will set a provide flag for this provide
 	Provide(chan<- *ProvidedRoom)
-	// Revoke a provided room.
+	// Revoke a provided room, ProvidedRoom.ProviderName must be the same as provided.
 	// Will unset this provider flag, if all providers unset this room, room will stop watching
-	Revoke(chan<- uint64)
+	Revoke(chan<- *ProvidedRoom)
+}
+
+// DedupStore remember unique keys of msg in duplicate window, with masks of agents that delivered it
+type DedupStore interface {
+	// Init store, release is called with key and appended masks when key expired
+	Init(ctx *CenterContext, release func(key string, entry []byte)) error
+	// Seen append agent mask to key, return true if key was seen before
+	Seen(key string, mask []byte) (bool, error)
+	// Exists check key without changing it
+	Exists(key string) (bool, error)
 }
 
 type ProvidedRoom struct {
 	ProviderName string `json:"provider_name"`
 	RoomID       uint64 `json:"room_id"`
-	// TODO: support custom UID/Cookie/UA/Headers etc.
+	Credential   string `json:"credential"` // name of Config.Credentials, only static provider can attach one, using global account if empty
 }
 
 type AgentStatus struct {
@@ -50,6 +60,12 @@ type AgentStatus struct {
 	UpdateTime   time.Time          `json:"update_time"`
 	CachedStatus *agent.AgentStatus `json:"cached_status"`
 	HitStatus    map[string]uint32  `json:"hit_status"`
+	Draining     bool               `json:"draining"`     // all rooms will be moved off from draining agent
+	Paused       bool               `json:"paused"`       // agent drops events, not eligible as master
+	Stopped      bool               `json:"stopped"`      // final status received, not initialized again until its next info
+	Info         *agent.AgentInfo   `json:"info"`         // nil for agent adopted from status
+	Incompatible string             `json:"incompatible"` // reason of refusing init, empty if compatible
+	Credentials  map[uint64]string  `json:"credentials"`  // room:credential profile the room was added with
 	mu           sync.RWMutex
 }
 
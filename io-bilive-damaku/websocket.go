@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TiyaAnlite/FocotServices/io-bilive-damaku/pb/agent"
+	"github.com/TiyaAnlite/FocotServicesCommon/echox"
+	"github.com/labstack/echo/v4"
+	"github.com/lxzan/gws"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+// WebSocketHub fan out processed events to websocket clients, it is a sink of MessageProcessor.
+// Every client has its own buffer, events are dropped for the client when its buffer is full,
+// so a slow client never blocks the processor
+type WebSocketHub struct {
+	gws.BuiltinEventHandler
+	centerCtx *CenterContext
+	config    *WebSocketConfig
+	upgrader  *gws.Upgrader
+	clients   sync.Map     // *gws.Conn:*wsClient
+	count     atomic.Int32 // connected clients and slots reserved by upgrading
+	auth      func(ctx echo.Context) bool
+
+	mSent    *prometheus.CounterVec
+	mDropped *prometheus.CounterVec
+}
+
+// WebSocketSubscription is sent by client as text message to replace its subscription, empty for all
+type WebSocketSubscription struct {
+	Rooms []uint64 `json:"rooms"`
+	Types []string `json:"types"`
+}
+
+func (s *WebSocketSubscription) match(room uint64, eventType string) bool {
+	if len(s.Types) > 0 && !slices.Contains(s.Types, eventType) {
+		return false
+	}
+	// meta events have no room
+	return len(s.Rooms) == 0 || room == 0 || slices.Contains(s.Rooms, room)
+}
+
+type wsClient struct {
+	conn         *gws.Conn
+	send         chan []byte // framed events
+	subscription atomic.Pointer[WebSocketSubscription]
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+func (c *wsClient) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+func (h *WebSocketHub) Init(ctx *CenterContext) {
+	h.centerCtx = ctx
+	h.config = &ctx.Config.WebSocket
+	h.upgrader = gws.NewUpgrader(h, &gws.ServerOption{})
+	clients := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{Name: "blive_damaku_ws_clients", Help: "connected websocket clients"},
+		func() float64 { return float64(h.count.Load()) })
+	h.mSent = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "blive_damaku_ws_sent_total", Help: "events sent to websocket clients"}, []string{"type"})
+	h.mDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "blive_damaku_ws_dropped_total", Help: "events dropped by full client buffer"}, []string{"type"})
+	ctx.Registry.MustRegister(clients, h.mSent, h.mDropped)
+	if token := h.config.Token; token != "" {
+		h.OnAuth(func(ctx echo.Context) bool {
+			given := ctx.QueryParam("token")
+			if bearer, ok := strings.CutPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+				given = bearer
+			}
+			return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+		})
+	}
+}
+
+// OnAuth set the hook authorizing request before upgrading, replace the token auth of config
+func (h *WebSocketHub) OnAuth(hook func(ctx echo.Context) bool) {
+	h.auth = hook
+}
+
+// ServeWebSocket live fan-out of processed events, see WebSocketHub
+func (c *DamakuController) ServeWebSocket(ctx echo.Context) error {
+	if !c.started.Load() || c.hub == nil {
+		return echox.NormalErrorResponse(ctx, http.StatusServiceUnavailable, http.StatusServiceUnavailable, "controller not started")
+	}
+	return c.hub.Serve(ctx)
+}
+
+// Handle marshal event once and push it to buffer of subscribed clients without blocking
+func (h *WebSocketHub) Handle(event any) bool {
+	if h.count.Load() == 0 {
+		return true
+	}
+	msg, ok := event.(proto.Message)
+	if !ok {
+		return true
+	}
+	eventType := EventTypeOf(event)
+	room := eventMeta(event).GetRoomID()
+	var frame []byte
+	h.clients.Range(func(_, value any) bool {
+		client := value.(*wsClient)
+		if !client.subscription.Load().match(room, eventType) {
+			return true
+		}
+		if frame == nil {
+			data, err := proto.Marshal(msg)
+			if err != nil {
+				klog.Errorf("[WebSocket]failed to marshal %s: %s", eventType, err.Error())
+				return false
+			}
+			if frame, err = proto.Marshal(&agent.EventFrame{Type: eventType, Data: data}); err != nil {
+				klog.Errorf("[WebSocket]failed to marshal frame: %s", err.Error())
+				return false
+			}
+		}
+		select {
+		case client.send <- frame:
+			h.mSent.WithLabelValues(eventType).Inc()
+		default:
+			h.mDropped.WithLabelValues(eventType).Inc()
+		}
+		return true
+	})
+	return true
+}
+
+// Serve upgrade request to websocket, initial subscription can be set by query: rooms=1,2&types=damaku,gift
+func (h *WebSocketHub) Serve(ctx echo.Context) error {
+	if h.auth != nil && !h.auth(ctx) {
+		return echox.NormalErrorResponse(ctx, http.StatusUnauthorized, http.StatusUnauthorized, "unauthorized")
+	}
+	// reserve a slot, released if not connected
+	if n := h.count.Add(1); h.config.MaxClients > 0 && int(n) > h.config.MaxClients {
+		h.count.Add(-1)
+		return echox.NormalErrorResponse(ctx, http.StatusServiceUnavailable, http.StatusServiceUnavailable, "too many clients")
+	}
+	connected := false
+	defer func() {
+		if !connected {
+			h.count.Add(-1)
+		}
+	}()
+	subscription := &WebSocketSubscription{}
+	for _, room := range strings.Split(ctx.QueryParam("rooms"), ",") {
+		if room == "" {
+			continue
+		}
+		roomId, err := strconv.ParseUint(room, 10, 64)
+		if err != nil {
+			return echox.NormalErrorResponse(ctx, http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		}
+		subscription.Rooms = append(subscription.Rooms, roomId)
+	}
+	if types := ctx.QueryParam("types"); types != "" {
+		subscription.Types = strings.Split(types, ",")
+	}
+	socket, err := h.upgrader.Upgrade(ctx.Response(), ctx.Request())
+	if err != nil {
+		klog.Warningf("[WebSocket]failed to upgrade: %s", err.Error())
+		return nil // response already written
+	}
+	client := &wsClient{
+		conn: socket,
+		send: make(chan []byte, h.config.ClientBuffer),
+		done: make(chan struct{}),
+	}
+	client.subscription.Store(subscription)
+	h.clients.Store(socket, client)
+	connected = true
+	klog.Infof("[WebSocket]client connected: %s, rooms: %v, types: %v", socket.RemoteAddr(), subscription.Rooms, subscription.Types)
+	go h.writer(client)
+	go socket.ReadLoop()
+	return nil
+}
+
+func (h *WebSocketHub) OnClose(socket *gws.Conn, err error) {
+	if v, ok := h.clients.LoadAndDelete(socket); ok {
+		v.(*wsClient).close()
+		h.count.Add(-1)
+		klog.Infof("[WebSocket]client disconnected: %s, %v", socket.RemoteAddr(), err)
+	}
+}
+
+// OnMessage replace subscription of client
+func (h *WebSocketHub) OnMessage(socket *gws.Conn, message *gws.Message) {
+	defer message.Close()
+	v, ok := h.clients.Load(socket)
+	if !ok {
+		return
+	}
+	subscription := &WebSocketSubscription{}
+	if err := json.Unmarshal(message.Bytes(), subscription); err != nil {
+		klog.Warningf("[WebSocket]illegal subscription from %s: %s", socket.RemoteAddr(), err.Error())
+		return
+	}
+	v.(*wsClient).subscription.Store(subscription)
+	klog.V(3).Infof("[WebSocket]client %s subscribed rooms: %v, types: %v", socket.RemoteAddr(), subscription.Rooms, subscription.Types)
+}
+
+// writer send buffered frames of client in batch, batch is uvarint length-prefixed frames
+func (h *WebSocketHub) writer(client *wsClient) {
+	ticker := time.NewTicker(h.config.FlushInterval)
+	defer ticker.Stop()
+	var batch []byte
+	frames := 0
+	flush := func() bool {
+		if frames == 0 {
+			return true
+		}
+		_ = client.conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout))
+		if err := client.conn.WriteMessage(gws.OpcodeBinary, batch); err != nil {
+			klog.Warningf("[WebSocket]failed to write client %s: %s", client.conn.RemoteAddr(), err.Error())
+			_ = client.conn.NetConn().Close() // ReadLoop will emit OnClose
+			return false
+		}
+		batch = batch[:0]
+		frames = 0
+		return true
+	}
+	add := func(frame []byte) {
+		batch = protowire.AppendVarint(batch, uint64(len(frame)))
+		batch = append(batch, frame...)
+		frames++
+	}
+	for {
+		select {
+		case frame := <-client.send:
+			add(frame)
+			if frames >= h.config.BatchSize && !flush() {
+				return
+			}
+		case <-ticker.C:
+			if !flush() {
+				return
+			}
+		case <-client.done:
+			return
+		case <-h.centerCtx.Context.Done():
+			// send buffered frames before going away
+			for drained := false; !drained; {
+				select {
+				case frame := <-client.send:
+					add(frame)
+					if frames >= h.config.BatchSize && !flush() {
+						return
+					}
+				default:
+					drained = true
+				}
+			}
+			if flush() {
+				client.conn.WriteClose(1001, nil)
+			}
+			return
+		}
+	}
+}
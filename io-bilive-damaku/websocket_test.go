@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWebSocketServeRefused(t *testing.T) {
+	cases := []struct {
+		name      string
+		token     string
+		query     string
+		connected int32
+		want      int // status, response of failed upgrading is written by upgrader
+	}{
+		{"no token", "secret", "", 0, http.StatusUnauthorized},
+		{"wrong token", "secret", "?token=wrong", 0, http.StatusUnauthorized},
+		{"too many clients", "secret", "?token=secret", 1, http.StatusServiceUnavailable},
+		{"not websocket", "", "", 0, 0}, // slot released after upgrading failed
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := NewConfig()
+			config.WebSocket.MaxClients = 1
+			config.WebSocket.Token = c.token
+			hub := &WebSocketHub{}
+			hub.Init(&CenterContext{Config: config, Registry: prometheus.NewRegistry()})
+			hub.count.Store(c.connected)
+			e := echo.New()
+			e.GET("/ws", hub.Serve)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws"+c.query, nil))
+			if c.want != 0 && rec.Code != c.want {
+				t.Fatalf("unexpected status: %d, want %d", rec.Code, c.want)
+			}
+			if got := hub.count.Load(); got != c.connected {
+				t.Fatalf("slot not released: %d, want %d", got, c.connected)
+			}
+		})
+	}
+}